@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"eve.evalgo.org/semantic"
+	"github.com/labstack/echo/v4"
+)
+
+// Error codes reported when an action stops because its context ended
+const (
+	errorCodeTimeout  = "ActionTimeout"
+	errorCodeCanceled = "ActionCanceled"
+)
+
+// defaultActionTimeout bounds actions that do not set their own timeout (S3_ACTION_TIMEOUT)
+var defaultActionTimeout = 30 * time.Minute
+
+// maxActionTimeout caps the timeout an action may request
+const maxActionTimeout = 24 * time.Hour
+
+// loadActionTimeout reads the server default timeout from S3_ACTION_TIMEOUT
+func loadActionTimeout() error {
+	value := os.Getenv("S3_ACTION_TIMEOUT")
+	if value == "" {
+		return nil
+	}
+	timeout, err := parseTimeout(value)
+	if err != nil {
+		return fmt.Errorf("invalid S3_ACTION_TIMEOUT: %w", err)
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("S3_ACTION_TIMEOUT must be positive")
+	}
+	defaultActionTimeout = timeout
+	return nil
+}
+
+// actionContext derives the context for an action's S3 calls from the HTTP request,
+// so a disconnecting client cancels the work, bounded by the action's "timeout"
+// option or the server default.
+func actionContext(c echo.Context, action *semantic.SemanticAction) (context.Context, context.CancelFunc, error) {
+	value, _ := actionOption(action, "timeout")
+	timeout, err := timeoutOption(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+	return ctx, cancel, nil
+}
+
+// timeoutOption reads a timeout option (seconds or a Go duration such as "90s"),
+// capped at maxActionTimeout. Unset means the server default. Every action gets a
+// deadline, so a timeout that is not positive is rejected.
+func timeoutOption(value interface{}) (time.Duration, error) {
+	if value == nil {
+		return defaultActionTimeout, nil
+	}
+	timeout, err := parseTimeout(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout: %w", err)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be positive")
+	}
+	if timeout > maxActionTimeout {
+		timeout = maxActionTimeout
+	}
+	return timeout, nil
+}
+
+// parseTimeout accepts a number of seconds or a Go duration string
+func parseTimeout(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case string:
+		text := strings.TrimSpace(v)
+		if seconds, err := strconv.ParseFloat(text, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second)), nil
+		}
+		return time.ParseDuration(text)
+	default:
+		return 0, fmt.Errorf("unsupported timeout %v", value)
+	}
+}
+
+// contextErrorCode classifies errors caused by a deadline or cancellation
+func contextErrorCode(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return errorCodeTimeout
+	case errors.Is(err, context.Canceled):
+		return errorCodeCanceled
+	default:
+		return ""
+	}
+}
+
+// returnActionError reports a failed action. Failures caused by the action's context
+// ending carry a distinct errorCode so callers can tell timeouts from S3 errors.
+func returnActionError(c echo.Context, action *semantic.SemanticAction, message string, err error) error {
+	if code := contextErrorCode(err); code != "" {
+		if action.Properties == nil {
+			action.Properties = map[string]interface{}{}
+		}
+		action.Properties["errorCode"] = code
+		message = fmt.Sprintf("%s (%s)", message, code)
+	}
+	return semantic.ReturnActionError(c, action, message, err)
+}
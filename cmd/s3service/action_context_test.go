@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: float64(300), want: 5 * time.Minute},
+		{value: "45", want: 45 * time.Second},
+		{value: "1.5", want: 1500 * time.Millisecond},
+		{value: "90s", want: 90 * time.Second},
+		{value: "2h", want: 2 * time.Hour},
+		{value: "soon", wantErr: true},
+		{value: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimeout(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseTimeout(%v): expected error, got %v", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseTimeout(%v) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestTimeoutOption(t *testing.T) {
+	if got, err := timeoutOption(nil); err != nil || got != defaultActionTimeout {
+		t.Errorf("expected the server default, got %v (%v)", got, err)
+	}
+	if got, err := timeoutOption("48h"); err != nil || got != maxActionTimeout {
+		t.Errorf("expected the timeout to be capped, got %v (%v)", got, err)
+	}
+	for _, value := range []interface{}{float64(0), "-5s"} {
+		if got, err := timeoutOption(value); err == nil {
+			t.Errorf("expected timeout %v to be rejected, got %v", value, got)
+		}
+	}
+}
+
+func TestContextErrorCode(t *testing.T) {
+	wrapped := fmt.Errorf("operation error S3: GetObject: %w", context.DeadlineExceeded)
+	if got := contextErrorCode(wrapped); got != errorCodeTimeout {
+		t.Errorf("expected %s, got %q", errorCodeTimeout, got)
+	}
+	if got := contextErrorCode(context.Canceled); got != errorCodeCanceled {
+		t.Errorf("expected %s, got %q", errorCodeCanceled, got)
+	}
+	if got := contextErrorCode(errors.New("AccessDenied")); got != "" {
+		t.Errorf("expected no code, got %q", got)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"eve.evalgo.org/semantic"
+)
+
+// ============================================================================
+// JSON-LD Property Helpers
+// ============================================================================
+
+// actionDocument returns the action as a generic JSON-LD document so handlers
+// can read Schema.org properties that have no typed accessor in the semantic package
+func actionDocument(action *semantic.SemanticAction) map[string]interface{} {
+	doc := map[string]interface{}{}
+	data, err := json.Marshal(action)
+	if err != nil {
+		return doc
+	}
+	_ = json.Unmarshal(data, &doc)
+	return doc
+}
+
+// actionNode returns a nested JSON-LD node (e.g. "object", "target") of the action
+func actionNode(action *semantic.SemanticAction, name string) map[string]interface{} {
+	node, _ := actionDocument(action)[name].(map[string]interface{})
+	return node
+}
+
+// lookupProperty finds a property on a JSON-LD node, falling back to its
+// additionalProperty, which may be a plain map or a list of PropertyValue entries
+func lookupProperty(node map[string]interface{}, name string) (interface{}, bool) {
+	if node == nil {
+		return nil, false
+	}
+	if value, ok := node[name]; ok && value != nil {
+		return value, true
+	}
+
+	switch props := node["additionalProperty"].(type) {
+	case map[string]interface{}:
+		if value, ok := props[name]; ok && value != nil {
+			return value, true
+		}
+	case []interface{}:
+		for _, entry := range props {
+			pv, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if pvName, _ := pv["name"].(string); pvName == name {
+				return pv["value"], true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// actionOption looks up an option on the action itself, then on its object
+func actionOption(action *semantic.SemanticAction, name string) (interface{}, bool) {
+	doc := actionDocument(action)
+	if value, ok := lookupProperty(doc, name); ok {
+		return value, true
+	}
+	object, _ := doc["object"].(map[string]interface{})
+	return lookupProperty(object, name)
+}
+
+// stringOption returns an action option as a string
+func stringOption(action *semantic.SemanticAction, name string) string {
+	value, ok := actionOption(action, name)
+	if !ok {
+		return ""
+	}
+	return asString(value)
+}
+
+// boolOption returns an action option as a bool ("true", "1", true)
+func boolOption(action *semantic.SemanticAction, name string) bool {
+	value, ok := actionOption(action, name)
+	if !ok {
+		return false
+	}
+	return asBool(value)
+}
+
+// intOption returns an action option as an int64, or def when unset
+func intOption(action *semantic.SemanticAction, name string, def int64) (int64, error) {
+	value, ok := actionOption(action, name)
+	if !ok {
+		return def, nil
+	}
+	n, err := asInt(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return n, nil
+}
+
+// asString converts a decoded JSON value to a string
+func asString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// asBool converts a decoded JSON value to a bool
+func asBool(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(strings.TrimSpace(v))
+		return b
+	case float64:
+		return v != 0
+	default:
+		return false
+	}
+}
+
+// asInt converts a decoded JSON value to an int64
+func asInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	default:
+		return 0, fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// stringMapOption returns an option holding string pairs, given either as a JSON object
+// or as a list of PropertyValue entries ({"name": ..., "value": ...})
+func stringMapOption(action *semantic.SemanticAction, name string) (map[string]string, bool, error) {
+	value, ok := actionOption(action, name)
+	if !ok {
+		return nil, false, nil
+	}
+
+	pairs := map[string]string{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			pairs[key] = asString(item)
+		}
+	case []interface{}:
+		for _, entry := range v {
+			pv, ok := entry.(map[string]interface{})
+			if !ok || asString(pv["name"]) == "" {
+				return nil, true, fmt.Errorf("invalid %s entry %v", name, entry)
+			}
+			pairs[asString(pv["name"])] = asString(pv["value"])
+		}
+	default:
+		return nil, true, fmt.Errorf("%s must be an object or a list of PropertyValue entries", name)
+	}
+	return pairs, true, nil
+}
+
+// stringListOption returns an option holding strings, given either as a JSON list or
+// as a comma-separated string
+func stringListOption(action *semantic.SemanticAction, name string) ([]string, error) {
+	value, ok := actionOption(action, name)
+	if !ok {
+		return nil, nil
+	}
+
+	var items []string
+	switch v := value.(type) {
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	case []interface{}:
+		for _, entry := range v {
+			item, ok := entry.(string)
+			if !ok || item == "" {
+				return nil, fmt.Errorf("invalid %s entry %v", name, entry)
+			}
+			items = append(items, item)
+		}
+	default:
+		return nil, fmt.Errorf("%s must be a list or a comma-separated string", name)
+	}
+	return items, nil
+}
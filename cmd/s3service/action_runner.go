@@ -0,0 +1,697 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"eve.evalgo.org/semantic"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// ============================================================================
+// Action Execution
+// ============================================================================
+
+const (
+	defaultAsyncWorkers   = 4
+	defaultAsyncQueueSize = 100
+	maxCapturedResponse   = 1 << 20 // larger JSON responses are not kept as the result
+	maxSummaryString      = 256     // longer strings are left out of result summaries
+)
+
+// errAsyncQueueFull rejects async actions while every queue slot is taken
+var errAsyncQueueFull = errors.New("async action queue is full")
+
+// errActionCancelled ends an async action stopped through Cancel, as opposed to a
+// shutdown
+var errActionCancelled = errors.New("action cancelled")
+
+// asyncSettings reads S3_ASYNC_WORKERS (default 4) and S3_ASYNC_QUEUE_SIZE (default 100)
+func asyncSettings() (workers, queueSize int) {
+	workers, queueSize = defaultAsyncWorkers, defaultAsyncQueueSize
+	if value, err := strconv.Atoi(os.Getenv("S3_ASYNC_WORKERS")); err == nil && value > 0 {
+		workers = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("S3_ASYNC_QUEUE_SIZE")); err == nil && value > 0 {
+		queueSize = value
+	}
+	return workers, queueSize
+}
+
+// actionFunc runs an action against an echo context
+type actionFunc func(c echo.Context) error
+
+// asyncAction is an accepted action waiting for a worker
+type asyncAction struct {
+	tracked trackedAction
+	handle  actionFunc
+	job     *asyncJob // the stored job, when the runner has a job store
+}
+
+// actionRunner runs semantic actions and records each one as a state manager operation:
+// synchronous actions in the request, async ones in a bounded worker pool
+type actionRunner struct {
+	tracker *stateTracker
+	echo    *echo.Echo // builds the contexts async actions run in
+
+	// build rebuilds stored actions on start; buildAction unless a test sets it
+	build func(body []byte) (actionFunc, error)
+
+	mu      sync.Mutex
+	ctx     context.Context
+	queue   chan asyncAction
+	store   *jobStore
+	running map[string]context.CancelCauseFunc // by tracked identifier
+	wg      sync.WaitGroup
+}
+
+// actions is the service-wide action runner
+var actions = newActionRunner(trackedActions)
+
+func newActionRunner(tracker *stateTracker) *actionRunner {
+	return &actionRunner{
+		tracker: tracker,
+		echo:    echo.New(),
+		ctx:     context.Background(),
+		running: map[string]context.CancelCauseFunc{},
+	}
+}
+
+// buildAction rebuilds the handler of a stored action body: a semantic action or
+// a batch
+func buildAction(body []byte) (actionFunc, error) {
+	if list := batchList(body); list != nil {
+		items, opts, err := parseBatch(list)
+		if err != nil {
+			return nil, err
+		}
+		return asyncBatch(items, opts), nil
+	}
+	action, err := semantic.ParseSemanticAction(body)
+	if err != nil {
+		return nil, err
+	}
+	return func(c echo.Context) error { return semantic.Handle(c, action) }, nil
+}
+
+// UseStore keeps accepted async actions in store, so they survive a restart and
+// are deduplicated by their identifier
+func (r *actionRunner) UseStore(store *jobStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store = store
+}
+
+// Start runs workers that execute async actions until ctx ends. Actions still
+// queued then are failed, unless the job store keeps them for the next start.
+func (r *actionRunner) Start(ctx context.Context, workers, queueSize int) {
+	r.mu.Lock()
+	r.ctx = ctx
+	r.queue = make(chan asyncAction, queueSize)
+	queue := r.queue
+	r.mu.Unlock()
+
+	for i := 0; i < workers; i++ {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-queue:
+					if ctx.Err() != nil {
+						r.abandon(job)
+						continue
+					}
+					r.runAsync(ctx, job)
+				}
+			}
+		}()
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		<-ctx.Done()
+		for {
+			select {
+			case job := <-queue:
+				r.abandon(job)
+			default:
+				return
+			}
+		}
+	}()
+}
+
+// Wait blocks until the workers have stopped
+func (r *actionRunner) Wait() {
+	r.wg.Wait()
+}
+
+// abandon fails an action left in the queue at shutdown. Stored actions stay
+// active in the job store and run on the next start.
+func (r *actionRunner) abandon(job asyncAction) {
+	if job.job != nil {
+		return
+	}
+	job.tracked.ActionStatus, job.tracked.Error = actionStatusFailed, "service stopped before the action ran"
+	r.tracker.Record(job.tracked)
+}
+
+// newTrackedAction describes an action for the state manager, without recording it
+func newTrackedAction(action *semantic.SemanticAction) trackedAction {
+	tracked := trackedAction{Type: actionType(action), ActionStatus: actionStatusActive}
+	if object := actionNode(action, "object"); object != nil {
+		tracked.Object = redactCredentials(object)
+	}
+	if target := actionNode(action, "target"); target != nil {
+		tracked.Target = redactCredentials(target)
+	}
+	return tracked
+}
+
+// Begin records an action as active before it runs
+func (r *actionRunner) Begin(action *semantic.SemanticAction) trackedAction {
+	return r.tracker.Record(newTrackedAction(action))
+}
+
+// Submit queues an action begun with Begin for a worker
+func (r *actionRunner) Submit(tracked trackedAction, handle actionFunc) error {
+	if err := r.enqueue(asyncAction{tracked: tracked, handle: handle}); err != nil {
+		tracked.ActionStatus, tracked.Error = actionStatusFailed, err.Error()
+		r.tracker.Record(tracked)
+		return err
+	}
+	return nil
+}
+
+// enqueue hands an action to the workers without waiting for a queue slot
+func (r *actionRunner) enqueue(job asyncAction) error {
+	r.mu.Lock()
+	queue, ctx := r.queue, r.ctx
+	r.mu.Unlock()
+
+	if queue != nil && ctx.Err() == nil {
+		select {
+		case queue <- job:
+			return nil
+		default:
+		}
+	}
+	return errAsyncQueueFull
+}
+
+// Accept records and queues an async action described by tracked (see
+// newTrackedAction). With a job store the action body and retry policy are stored
+// first. An action whose identifier was already accepted does not run again: the
+// earlier action is returned with duplicate set.
+func (r *actionRunner) Accept(tracked trackedAction, body []byte, handle actionFunc, policy retryPolicy) (accepted trackedAction, duplicate bool, err error) {
+	r.mu.Lock()
+	store := r.store
+	r.mu.Unlock()
+	if store == nil {
+		tracked = r.tracker.Record(tracked)
+		return tracked, false, r.Submit(tracked, handle)
+	}
+
+	tracked.Identifier = uuid.NewString()
+	tracked.ActionStatus = actionStatusActive
+	tracked.StartTime = time.Now().UTC()
+	job := &asyncJob{Action: tracked, ActionID: bodyIdentifier(body), Retry: policy}
+	if !containsCredentials(body) {
+		// Inline credentials are never written to disk; such actions are not resumed
+		job.Body = body
+	}
+	existing, err := store.Create(job)
+	if err != nil {
+		return tracked, false, err
+	}
+	if existing != nil {
+		if current, ok := r.tracker.Get(existing.Action.Identifier); ok {
+			return current, true, nil
+		}
+		return existing.Action, true, nil
+	}
+
+	tracked = r.tracker.Record(tracked)
+	if err := r.enqueue(asyncAction{tracked: tracked, handle: handle, job: job}); err != nil {
+		// Not accepted after all: forget the job so the action can be sent again
+		_ = store.Delete(job)
+		tracked.ActionStatus, tracked.Error = actionStatusFailed, err.Error()
+		return r.tracker.Record(tracked), false, err
+	}
+	return tracked, false, nil
+}
+
+// Resume restores the stored jobs when the service starts. Finished jobs are
+// tracked again so their identifiers still answer; active ones, queued or cut off
+// by the last shutdown, are queued again when their retry policy allows another
+// attempt and failed otherwise. Finished jobs older than retention are dropped.
+func (r *actionRunner) Resume(retention time.Duration) (resumed, failed int, err error) {
+	r.mu.Lock()
+	store, ctx := r.store, r.ctx
+	r.mu.Unlock()
+	if store == nil {
+		return 0, 0, nil
+	}
+	build := r.build
+	if build == nil {
+		build = buildAction
+	}
+
+	if _, err := store.Prune(time.Now().Add(-retention)); err != nil {
+		return 0, 0, err
+	}
+	jobs, err := store.List()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, job := range jobs {
+		tracked := job.Action
+		if tracked.ActionStatus != actionStatusActive {
+			r.tracker.Record(tracked)
+			continue
+		}
+
+		var handle actionFunc
+		var reason string
+		switch {
+		case job.Body == nil:
+			reason = "inline credentials are not kept across restarts; send the action again"
+		case tracked.Attempts >= job.Retry.MaxAttempts:
+			reason = "interrupted by a restart during its last attempt"
+		default:
+			var buildErr error
+			if handle, buildErr = build(job.Body); buildErr != nil {
+				reason = fmt.Sprintf("cannot be resumed: %v", buildErr)
+			}
+		}
+		if reason != "" {
+			tracked.ActionStatus, tracked.Error = actionStatusFailed, reason
+			job.Action = r.tracker.Record(tracked)
+			_ = store.Save(job)
+			failed++
+			continue
+		}
+
+		tracked.Error = ""
+		job.Action = r.tracker.Record(tracked)
+		r.queueLater(ctx, asyncAction{tracked: job.Action, handle: handle, job: job}, time.Until(job.NextAttempt))
+		resumed++
+	}
+	return resumed, failed, nil
+}
+
+// queueLater queues a stored action after delay, waiting for a queue slot, unless
+// the runner stops first; the job store then keeps it for the next start
+func (r *actionRunner) queueLater(ctx context.Context, job asyncAction, delay time.Duration) {
+	r.mu.Lock()
+	queue := r.queue
+	r.mu.Unlock()
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		select {
+		case queue <- job:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// Cancel stops a running async action. It fails and is neither retried nor
+// resumed.
+func (r *actionRunner) Cancel(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cancel, ok := r.running[id]
+	if ok {
+		cancel(errActionCancelled)
+	}
+	return ok
+}
+
+// runAsync runs a queued action with a request of its own, since the one that
+// submitted it has long been answered
+func (r *actionRunner) runAsync(ctx context.Context, job asyncAction) {
+	tracked := job.tracked
+	if job.job != nil {
+		tracked.Attempts++
+		tracked.Error, tracked.Result = "", nil
+		job.job.Action = r.tracker.Record(tracked)
+		r.save(job.job)
+	}
+
+	actionCtx, cancel := context.WithCancelCause(withCheckpoint(ctx, r, job.job))
+	r.mu.Lock()
+	r.running[tracked.Identifier] = cancel
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.running, tracked.Identifier)
+		r.mu.Unlock()
+		cancel(nil)
+	}()
+
+	request, err := http.NewRequestWithContext(actionCtx, http.MethodPost, "/v1/api/semantic/action", http.NoBody)
+	if err != nil {
+		tracked.ActionStatus, tracked.Error = actionStatusFailed, err.Error()
+	} else {
+		c := r.echo.NewContext(request, &discardResponse{header: http.Header{}})
+		tracked, _ = r.execute(c, tracked, job.handle)
+	}
+	cancelled := errors.Is(context.Cause(actionCtx), errActionCancelled)
+	if cancelled && tracked.ActionStatus == actionStatusFailed {
+		tracked.Error = errActionCancelled.Error()
+	}
+	if job.job == nil {
+		r.tracker.Record(tracked)
+		return
+	}
+	r.finish(ctx, job, tracked, cancelled)
+}
+
+// finish records the outcome of a stored action. A failed attempt is retried after
+// the policy's backoff while attempts remain, unless it was cancelled; an attempt
+// cut off by shutdown stays active in the store, isn't counted against the policy,
+// and resumes on the next start.
+func (r *actionRunner) finish(ctx context.Context, job asyncAction, tracked trackedAction, cancelled bool) {
+	retry := false
+	switch {
+	case cancelled:
+	case ctx.Err() != nil && tracked.ActionStatus == actionStatusFailed:
+		tracked.ActionStatus, tracked.EndTime = actionStatusActive, nil
+		tracked.Attempts--
+		tracked.Error = "interrupted by shutdown; resumes on the next start"
+	case tracked.ActionStatus == actionStatusFailed && tracked.Attempts < job.job.Retry.MaxAttempts:
+		delay := job.job.Retry.delay(tracked.Attempts)
+		job.job.NextAttempt = time.Now().Add(delay)
+		tracked.ActionStatus, tracked.EndTime = actionStatusActive, nil
+		tracked.Error = fmt.Sprintf("attempt %d of %d failed: %s; retrying in %s", tracked.Attempts, job.job.Retry.MaxAttempts, tracked.Error, delay)
+		retry = true
+	}
+
+	job.tracked = r.tracker.Record(tracked)
+	job.job.Action = job.tracked
+	r.save(job.job)
+	if retry {
+		r.queueLater(ctx, job, time.Until(job.job.NextAttempt))
+	}
+}
+
+// save writes a job back to the store. A failed write doesn't stop the action; it
+// only won't be resumed correctly after a restart.
+func (r *actionRunner) save(job *asyncJob) {
+	r.mu.Lock()
+	store := r.store
+	r.mu.Unlock()
+	if store != nil {
+		_ = store.Save(job)
+	}
+}
+
+// checkpointKey carries the stored job an async action keeps its checkpoint in
+type checkpointKey struct{}
+
+type checkpointTarget struct {
+	runner *actionRunner
+	job    *asyncJob
+}
+
+// withCheckpoint makes loadCheckpoint and saveCheckpoint calls under ctx use job,
+// if the action has one
+func withCheckpoint(ctx context.Context, runner *actionRunner, job *asyncJob) context.Context {
+	if job == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, checkpointKey{}, checkpointTarget{runner: runner, job: job})
+}
+
+// loadCheckpoint reads the checkpoint an earlier attempt of the action under ctx
+// saved into v. It reports false when there is none.
+func loadCheckpoint(ctx context.Context, v interface{}) (bool, error) {
+	target, ok := ctx.Value(checkpointKey{}).(checkpointTarget)
+	if !ok || len(target.job.Checkpoint) == 0 {
+		return false, nil
+	}
+	if err := json.Unmarshal(target.job.Checkpoint, v); err != nil {
+		return false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	return true, nil
+}
+
+// saveCheckpoint keeps v in the job store with the action under ctx, so a retry or
+// the next start continues from it. Actions that are not stored keep nothing.
+func saveCheckpoint(ctx context.Context, v interface{}) error {
+	target, ok := ctx.Value(checkpointKey{}).(checkpointTarget)
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	target.job.Checkpoint = data
+
+	target.runner.mu.Lock()
+	store := target.runner.store
+	target.runner.mu.Unlock()
+	if store == nil {
+		return nil
+	}
+	if err := store.Save(target.job); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Run executes an action begun with Begin, reporting progress while it runs and its
+// status, error and a summary of its result when it ends. The caller already has the
+// full result; only async actions keep it in the state manager. The handler's error is
+// returned unchanged.
+func (r *actionRunner) Run(c echo.Context, tracked trackedAction, handle actionFunc) error {
+	tracked, err := r.execute(c, tracked, handle)
+	tracked.Result = resultSummary(tracked.Result, 2)
+	r.tracker.Record(tracked)
+	return err
+}
+
+// resultSummary keeps the scalar fields of a result (type, counts, sizes, keys) and
+// of documents nested up to depth levels, dropping lists such as object listings
+func resultSummary(result interface{}, depth int) interface{} {
+	switch v := result.(type) {
+	case float64, bool:
+		return v
+	case string:
+		if len(v) > maxSummaryString {
+			return nil
+		}
+		return v
+	case map[string]interface{}:
+		summary := map[string]interface{}{}
+		for name, value := range v {
+			if _, nested := value.(map[string]interface{}); nested && depth == 0 {
+				continue
+			}
+			if kept := resultSummary(value, depth-1); kept != nil {
+				summary[name] = kept
+			}
+		}
+		return summary
+	default:
+		return nil
+	}
+}
+
+// execute runs an action with progress reporting and returns its final state
+func (r *actionRunner) execute(c echo.Context, tracked trackedAction, handle actionFunc) (trackedAction, error) {
+	capture := &responseCapture{ResponseWriter: c.Response().Writer}
+	c.Response().Writer = capture
+	c.SetRequest(c.Request().WithContext(withProgress(c.Request().Context(), r.tracker, tracked.Identifier)))
+
+	err := handle(c)
+
+	// Progress reported while it ran; a resumed action started with some already
+	if current, ok := r.tracker.Get(tracked.Identifier); ok {
+		tracked.BytesTransferred, tracked.ItemsProcessed = current.BytesTransferred, current.ItemsProcessed
+	}
+	tracked.ActionStatus = actionStatusCompleted
+	if response := capture.document(); response != nil {
+		tracked.Result = response["result"]
+		tracked.Error = responseError(response["error"])
+		if asString(response["actionStatus"]) == actionStatusFailed {
+			tracked.ActionStatus = actionStatusFailed
+		}
+	}
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &httpErr):
+		tracked.ActionStatus, tracked.Error = actionStatusFailed, fmt.Sprint(httpErr.Message)
+	case err != nil:
+		tracked.ActionStatus, tracked.Error = actionStatusFailed, err.Error()
+	case c.Response().Status >= http.StatusBadRequest:
+		tracked.ActionStatus = actionStatusFailed
+		if tracked.Error == "" {
+			tracked.Error = http.StatusText(c.Response().Status)
+		}
+	}
+	return tracked, err
+}
+
+// responseError reads the error of an action response: a message, or an object
+// with a message or description
+func responseError(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		for _, name := range []string{"message", "description", "name"} {
+			if text := asString(v[name]); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// credentialProperties are never kept in tracked actions
+var credentialProperties = map[string]bool{"accesskey": true, "secretkey": true, "sessiontoken": true, "password": true}
+
+// bodyIdentifier returns the identifier of an action body, if it has one
+func bodyIdentifier(body []byte) string {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return ""
+	}
+	return asString(doc["identifier"])
+}
+
+// containsCredentials reports whether an action body holds inline credentials
+func containsCredentials(body []byte) bool {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return false
+	}
+	var walk func(value interface{}) bool
+	walk = func(value interface{}) bool {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if credentialProperties[strings.ToLower(asString(v["name"]))] && asString(v["value"]) != "" {
+				return true
+			}
+			for name, item := range v {
+				if credentialProperties[strings.ToLower(name)] && asString(item) != "" || walk(item) {
+					return true
+				}
+			}
+		case []interface{}:
+			for _, item := range v {
+				if walk(item) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return walk(doc)
+}
+
+// redactCredentials copies a JSON-LD node with credentials masked and inline
+// content (object.text) replaced by its length
+func redactCredentials(node map[string]interface{}) map[string]interface{} {
+	redacted, _ := redactValue(node).(map[string]interface{})
+	return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		// PropertyValue entries name the credential: {"name": "secretKey", "value": "..."}
+		sensitive := credentialProperties[strings.ToLower(asString(v["name"]))]
+		for name, item := range v {
+			switch {
+			case credentialProperties[strings.ToLower(name)] || (sensitive && name == "value"):
+				out[name] = "REDACTED"
+			case name == "text":
+				out[name] = fmt.Sprintf("(%d characters)", len(asString(item)))
+			default:
+				out[name] = redactValue(item)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = redactValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// responseCapture passes a response through while keeping a copy of JSON bodies
+type responseCapture struct {
+	http.ResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *responseCapture) Write(p []byte) (int, error) {
+	if !w.truncated && strings.Contains(w.Header().Get(echo.HeaderContentType), "json") {
+		if w.body.Len()+len(p) > maxCapturedResponse {
+			w.truncated = true
+			w.body.Reset()
+		} else {
+			w.body.Write(p)
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer for flushing
+func (w *responseCapture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// document returns the captured JSON object, if the response was one
+func (w *responseCapture) document() map[string]interface{} {
+	if w.truncated || w.body.Len() == 0 {
+		return nil
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.body.Bytes(), &doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+// discardResponse is the response writer of an async action, whose caller has gone
+type discardResponse struct {
+	header http.Header
+}
+
+func (w *discardResponse) Header() http.Header         { return w.header }
+func (w *discardResponse) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardResponse) WriteHeader(int)             {}
+func (w *discardResponse) Flush()                      {}
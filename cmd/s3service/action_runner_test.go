@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// waitForStatus polls the state manager until an action leaves ActiveActionStatus
+func waitForStatus(t *testing.T, tracker *stateTracker, id string) trackedAction {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if action, ok := tracker.Get(id); ok && action.ActionStatus != actionStatusActive {
+			return action
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("action %s did not finish", id)
+	return trackedAction{}
+}
+
+func TestActionRunner_RecordsSynchronousActions(t *testing.T) {
+	tracker := newTestStateTracker()
+	runner := newActionRunner(tracker)
+	e := echo.New()
+
+	run := func(handle actionFunc) trackedAction {
+		tracked := tracker.Record(trackedAction{Type: "CreateAction", ActionStatus: actionStatusActive})
+		c := e.NewContext(httptest.NewRequest(http.MethodPost, "/v1/api/semantic/action", nil), httptest.NewRecorder())
+		_ = runner.Run(c, tracked, handle)
+		action, _ := tracker.Get(tracked.Identifier)
+		return action
+	}
+
+	completed := run(func(c echo.Context) error {
+		reportProgress(c.Request().Context(), 2048, 1)
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"actionStatus": actionStatusCompleted,
+			"result": map[string]interface{}{
+				"@type":       "DigitalDocument",
+				"contentSize": 2048,
+				"hasPart":     []interface{}{map[string]interface{}{"identifier": "a.txt"}},
+			},
+		})
+	})
+	if completed.ActionStatus != actionStatusCompleted || completed.EndTime == nil {
+		t.Errorf("expected a completed action, got %+v", completed)
+	}
+	if result, _ := completed.Result.(map[string]interface{}); result["@type"] != "DigitalDocument" || result["contentSize"] != 2048.0 {
+		t.Errorf("expected a summary of the action result, got %v", completed.Result)
+	} else if _, ok := result["hasPart"]; ok {
+		t.Errorf("expected lists to be left out of synchronous results, got %v", result)
+	}
+	if completed.BytesTransferred != 2048 || completed.ItemsProcessed != 1 {
+		t.Errorf("expected the reported progress, got %d bytes and %d items", completed.BytesTransferred, completed.ItemsProcessed)
+	}
+
+	failed := run(func(c echo.Context) error {
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"actionStatus": actionStatusFailed,
+			"error":        map[string]interface{}{"@type": "PropertyValue", "name": "Error", "message": "bucket not found"},
+		})
+	})
+	if failed.ActionStatus != actionStatusFailed || failed.Error != "bucket not found" {
+		t.Errorf("expected the action error, got %+v", failed)
+	}
+
+	rejected := run(func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action type")
+	})
+	if rejected.ActionStatus != actionStatusFailed || rejected.Error != "Invalid action type" {
+		t.Errorf("expected the handler error, got %+v", rejected)
+	}
+}
+
+func TestActionRunner_RunsAsyncActionsInBoundedPool(t *testing.T) {
+	tracker := newTestStateTracker()
+	runner := newActionRunner(tracker)
+	ctx, cancel := context.WithCancel(context.Background())
+	runner.Start(ctx, 1, 1)
+
+	release := make(chan struct{})
+	blocking := func(c echo.Context) error {
+		select {
+		case <-release:
+		case <-c.Request().Context().Done():
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "cancelled"})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{"result": "done"})
+	}
+
+	first := tracker.Record(trackedAction{Type: "DownloadAction", ActionStatus: actionStatusActive})
+	if err := runner.Submit(first, blocking); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	// Wait for the worker to take the first action, then fill the one queue slot
+	deadline := time.Now().Add(5 * time.Second)
+	for len(runner.queue) != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	second := tracker.Record(trackedAction{Type: "DownloadAction", ActionStatus: actionStatusActive})
+	if err := runner.Submit(second, blocking); err != nil {
+		t.Fatalf("submit failed: %v", err)
+	}
+	third := tracker.Record(trackedAction{Type: "DownloadAction", ActionStatus: actionStatusActive})
+	if err := runner.Submit(third, blocking); err != errAsyncQueueFull {
+		t.Fatalf("expected a full queue, got %v", err)
+	}
+	if action, _ := tracker.Get(third.Identifier); action.ActionStatus != actionStatusFailed {
+		t.Errorf("a rejected action is recorded as failed, got %+v", action)
+	}
+
+	close(release)
+	for _, id := range []string{first.Identifier, second.Identifier} {
+		if action := waitForStatus(t, tracker, id); action.ActionStatus != actionStatusCompleted || action.Result != "done" {
+			t.Errorf("expected %s to complete, got %+v", id, action)
+		}
+	}
+
+	cancel()
+	runner.Wait()
+	late := tracker.Record(trackedAction{Type: "DownloadAction", ActionStatus: actionStatusActive})
+	if err := runner.Submit(late, blocking); err == nil {
+		t.Error("expected actions submitted after shutdown to be rejected")
+	}
+}
+
+func TestRedactCredentials(t *testing.T) {
+	redacted := redactCredentials(map[string]interface{}{
+		"@type":     "DataCatalog",
+		"url":       "https://s3.example.com",
+		"accessKey": "AKIA",
+		"additionalProperty": []interface{}{
+			map[string]interface{}{"@type": "PropertyValue", "name": "secretKey", "value": "s3cr3t"},
+			map[string]interface{}{"@type": "PropertyValue", "name": "region", "value": "fsn1"},
+		},
+		"text": "inline content",
+	})
+
+	properties := redacted["additionalProperty"].([]interface{})
+	if redacted["accessKey"] != "REDACTED" || properties[0].(map[string]interface{})["value"] != "REDACTED" {
+		t.Errorf("expected credentials to be masked, got %v", redacted)
+	}
+	if properties[1].(map[string]interface{})["value"] != "fsn1" || redacted["url"] != "https://s3.example.com" {
+		t.Errorf("expected other properties to be kept, got %v", redacted)
+	}
+	if redacted["text"] != "(14 characters)" {
+		t.Errorf("expected inline content to be replaced, got %v", redacted["text"])
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"eve.evalgo.org/semantic"
+	"github.com/labstack/echo/v4"
+)
+
+// ============================================================================
+// Batch Actions
+// ============================================================================
+
+const (
+	defaultBatchConcurrency = 4
+	maxBatchItems           = 1000
+)
+
+// actionStatusPotential marks batch items that never ran
+const actionStatusPotential = "PotentialActionStatus"
+
+// batchItem is one action of an ItemList
+type batchItem struct {
+	Position   int
+	Identifier string
+	Document   map[string]interface{}
+	DependsOn  []int // indexes of the items that must complete first
+}
+
+// batchOptions controls how the items of an ItemList run
+type batchOptions struct {
+	Concurrency int
+	Ordered     bool // start items one after another in list order
+	StopOnError bool // skip the items not yet started after a failure
+}
+
+// parseBatch reads an ItemList of actions. Items are actions or ListItems wrapping
+// one. An item's dependsOn option lists the identifiers (or 1-based positions) of
+// items that must complete before it runs.
+func parseBatch(list map[string]interface{}) ([]*batchItem, batchOptions, error) {
+	opts := batchOptions{Concurrency: defaultBatchConcurrency}
+	if value, ok := lookupProperty(list, "concurrency"); ok {
+		concurrency, err := asInt(value)
+		if err != nil || concurrency < 1 || concurrency > maxPartConcurrency {
+			return nil, opts, fmt.Errorf("concurrency must be between 1 and %d", maxPartConcurrency)
+		}
+		opts.Concurrency = int(concurrency)
+	}
+	if value, ok := lookupProperty(list, "ordered"); ok {
+		opts.Ordered = asBool(value)
+	}
+	if value, ok := lookupProperty(list, "onError"); ok {
+		switch strings.ToLower(asString(value)) {
+		case "stop":
+			opts.StopOnError = true
+		case "continue", "":
+		default:
+			return nil, opts, fmt.Errorf("onError must be stop or continue")
+		}
+	}
+
+	elements, _ := list["itemListElement"].([]interface{})
+	if len(elements) == 0 {
+		return nil, opts, fmt.Errorf("itemListElement must list at least one action")
+	}
+	if len(elements) > maxBatchItems {
+		return nil, opts, fmt.Errorf("a batch holds at most %d actions", maxBatchItems)
+	}
+
+	items := make([]*batchItem, len(elements))
+	byIdentifier := map[string]int{}
+	for i, element := range elements {
+		doc, _ := element.(map[string]interface{})
+		if asString(doc["@type"]) == "ListItem" {
+			doc, _ = doc["item"].(map[string]interface{})
+		}
+		if asString(doc["@type"]) == "" {
+			return nil, opts, fmt.Errorf("item %d is not an action", i+1)
+		}
+		if err := checkBatchItem(doc); err != nil {
+			return nil, opts, fmt.Errorf("item %d %w", i+1, err)
+		}
+		items[i] = &batchItem{Position: i + 1, Identifier: asString(doc["identifier"]), Document: doc}
+		if id := items[i].Identifier; id != "" {
+			if _, duplicate := byIdentifier[id]; duplicate {
+				return nil, opts, fmt.Errorf("identifier %q is used by more than one item", id)
+			}
+			byIdentifier[id] = i
+		}
+	}
+
+	for i, item := range items {
+		value, ok := lookupProperty(item.Document, "dependsOn")
+		if !ok {
+			continue
+		}
+		refs, isList := value.([]interface{})
+		if !isList {
+			refs = []interface{}{value}
+		}
+		for _, ref := range refs {
+			dep, found := byIdentifier[asString(ref)]
+			if !found {
+				position, err := asInt(ref)
+				if err != nil || position < 1 || int(position) > len(items) {
+					return nil, opts, fmt.Errorf("item %d depends on unknown item %v", i+1, ref)
+				}
+				dep = int(position) - 1
+			}
+			if dep == i {
+				return nil, opts, fmt.Errorf("item %d depends on itself", i+1)
+			}
+			item.DependsOn = append(item.DependsOn, dep)
+		}
+	}
+	if err := checkBatchCycles(items, opts.Ordered); err != nil {
+		return nil, opts, err
+	}
+	return items, opts, nil
+}
+
+// checkBatchItem rejects actions a batch cannot run: item responses are collected
+// as documents, so a streamed download would be fetched only to be thrown away, and
+// items run inside the batch, so they can be neither async, migrations nor batches
+// themselves
+func checkBatchItem(doc map[string]interface{}) error {
+	option := func(name string) bool {
+		if value, ok := lookupProperty(doc, name); ok {
+			return asBool(value)
+		}
+		object, _ := doc["object"].(map[string]interface{})
+		value, _ := lookupProperty(object, name)
+		return asBool(value)
+	}
+	switch {
+	case asString(doc["@type"]) == "ItemList":
+		return fmt.Errorf("is a batch; batches cannot be nested")
+	case option("async"):
+		return fmt.Errorf("sets async; put async on the batch instead")
+	case asString(doc["@type"]) == "TransferAction" && option("migrate"):
+		return fmt.Errorf("is a migration; migrations run as async actions of their own")
+	case asString(doc["@type"]) == "DownloadAction" && option("stream"):
+		return fmt.Errorf("is a streamed download; set object.contentUrl to download to a file instead")
+	}
+	return nil
+}
+
+// checkBatchCycles rejects dependencies that can never be satisfied. In an ordered
+// batch every item also waits for the one before it, so a dependency on a later
+// item is a cycle too.
+func checkBatchCycles(items []*batchItem, ordered bool) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(items))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("item %d is part of a dependency cycle", i+1)
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		deps := items[i].DependsOn
+		if ordered && i > 0 {
+			deps = append([]int{i - 1}, deps...)
+		}
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		return nil
+	}
+	for i := range items {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchOutcome is the response of one item
+type batchOutcome struct {
+	Status   string
+	Document map[string]interface{}
+}
+
+// runBatch runs the items with run and returns their outcomes in list order. An
+// item waits for its dependencies and, when ordered, for the item before it. Items
+// whose dependencies failed, or that had not started when another failed with
+// StopOnError, are skipped.
+func runBatch(ctx context.Context, items []*batchItem, opts batchOptions, run func(*batchItem) batchOutcome) []batchOutcome {
+	outcomes := make([]batchOutcome, len(items))
+	done := make([]chan struct{}, len(items))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	concurrency := opts.Concurrency
+	if opts.Ordered {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+
+	var mu sync.Mutex
+	stopped := false
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func(i int, item *batchItem) {
+			defer wg.Done()
+			defer close(done[i])
+
+			if opts.Ordered && i > 0 {
+				<-done[i-1]
+			}
+			for _, dep := range item.DependsOn {
+				<-done[dep]
+				if outcomes[dep].Status != actionStatusCompleted {
+					outcomes[i] = skippedBatchItem(item, fmt.Sprintf("dependency %d did not complete", dep+1))
+					return
+				}
+			}
+
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			mu.Lock()
+			skip := stopped
+			mu.Unlock()
+			if skip {
+				outcomes[i] = skippedBatchItem(item, "an earlier action failed")
+				return
+			}
+
+			outcomes[i] = run(item)
+			reportProgress(ctx, 0, 1)
+			if outcomes[i].Status == actionStatusFailed && opts.StopOnError {
+				mu.Lock()
+				stopped = true
+				mu.Unlock()
+			}
+		}(i, item)
+	}
+	wg.Wait()
+	return outcomes
+}
+
+// runBatchItem runs one item as its own tracked action, with a request of its own,
+// and captures its response
+func runBatchItem(c echo.Context, item *batchItem) batchOutcome {
+	failed := func(err error) batchOutcome {
+		doc := map[string]interface{}{
+			"@type":        item.Document["@type"],
+			"actionStatus": actionStatusFailed,
+			"error":        map[string]interface{}{"message": err.Error()},
+		}
+		if item.Identifier != "" {
+			doc["identifier"] = item.Identifier
+		}
+		return batchOutcome{Status: actionStatusFailed, Document: doc}
+	}
+
+	data, err := json.Marshal(item.Document)
+	if err != nil {
+		return failed(err)
+	}
+	action, err := semantic.ParseSemanticAction(data)
+	if err != nil {
+		return failed(fmt.Errorf("failed to parse action: %w", err))
+	}
+	request, err := http.NewRequestWithContext(c.Request().Context(), http.MethodPost, c.Request().URL.Path, http.NoBody)
+	if err != nil {
+		return failed(err)
+	}
+	capture := &responseCapture{ResponseWriter: &discardResponse{header: http.Header{}}}
+	itemCtx := c.Echo().NewContext(request, capture)
+
+	tracked := actions.Begin(action)
+	_ = actions.Run(itemCtx, tracked, func(c echo.Context) error { return semantic.Handle(c, action) })
+	result, _ := actions.tracker.Get(tracked.Identifier)
+
+	doc := capture.document()
+	if doc == nil || itemCtx.Response().Status >= http.StatusBadRequest && doc["actionStatus"] == nil {
+		// Not an action response (a streamed download, a rejected request): report
+		// the tracked outcome instead
+		doc = map[string]interface{}{"@type": item.Document["@type"], "actionStatus": result.ActionStatus}
+		if result.Error != "" {
+			doc["error"] = map[string]interface{}{"message": result.Error}
+		}
+	}
+	doc["actionStatus"] = result.ActionStatus
+	doc["trackedAction"] = tracked.Identifier
+	return batchOutcome{Status: result.ActionStatus, Document: doc}
+}
+
+func skippedBatchItem(item *batchItem, reason string) batchOutcome {
+	doc := map[string]interface{}{
+		"@type":        item.Document["@type"],
+		"actionStatus": actionStatusPotential,
+		"error":        map[string]interface{}{"message": "not run: " + reason},
+	}
+	if item.Identifier != "" {
+		doc["identifier"] = item.Identifier
+	}
+	return batchOutcome{Status: actionStatusPotential, Document: doc}
+}
+
+// batchResult builds the ItemList answered for a batch
+func batchResult(items []*batchItem, outcomes []batchOutcome) map[string]interface{} {
+	elements := make([]interface{}, len(outcomes))
+	counts := map[string]int{}
+	for i, outcome := range outcomes {
+		counts[outcome.Status]++
+		elements[i] = map[string]interface{}{
+			"@type":    "ListItem",
+			"position": items[i].Position,
+			"item":     outcome.Document,
+		}
+	}
+	status := actionStatusCompleted
+	if counts[actionStatusCompleted] != len(outcomes) {
+		status = actionStatusFailed
+	}
+	return map[string]interface{}{
+		"@type":           "ItemList",
+		"actionStatus":    status,
+		"numberOfItems":   len(outcomes),
+		"itemListElement": elements,
+		"completedCount":  counts[actionStatusCompleted],
+		"failedCount":     counts[actionStatusFailed],
+		"skippedCount":    counts[actionStatusPotential],
+	}
+}
+
+// handleBatchAction runs an ItemList of actions posted to /v1/api/semantic/action.
+// The list answers 200 with every item's own status and result; with the list's
+// async option the whole batch runs in the worker pool as one tracked action.
+func handleBatchAction(c echo.Context, body []byte, list map[string]interface{}) error {
+	items, opts, err := parseBatch(list)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid batch: %v", err)})
+	}
+	if async, _ := lookupProperty(list, "async"); !asBool(async) {
+		return c.JSON(http.StatusOK, runBatchItems(c, items, opts))
+	}
+
+	retry, _ := lookupProperty(list, "retry")
+	policy, err := parseRetryPolicy(retry)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid retry policy: %v", err)})
+	}
+	tracked := trackedAction{
+		Type:         "ItemList",
+		ActionStatus: actionStatusActive,
+		Object:       map[string]interface{}{"@type": "ItemList", "numberOfItems": len(items)},
+	}
+	return acceptAsyncAction(c, tracked, body, asyncBatch(items, opts), policy)
+}
+
+// runBatchItems runs a parsed batch and builds the ItemList answered for it
+func runBatchItems(c echo.Context, items []*batchItem, opts batchOptions) map[string]interface{} {
+	outcomes := runBatch(c.Request().Context(), items, opts, func(item *batchItem) batchOutcome {
+		return runBatchItem(c, item)
+	})
+	return batchResult(items, outcomes)
+}
+
+// asyncBatch runs a batch as one tracked action, which keeps the item list as its result
+func asyncBatch(items []*batchItem, opts batchOptions) actionFunc {
+	return func(c echo.Context) error {
+		result := runBatchItems(c, items, opts)
+		return c.JSON(http.StatusOK, map[string]interface{}{"actionStatus": result["actionStatus"], "result": result})
+	}
+}
+
+// batchList returns the body as an ItemList document, or nil for a single action
+func batchList(body []byte) map[string]interface{} {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil || asString(doc["@type"]) != "ItemList" {
+		return nil
+	}
+	return doc
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchDocument builds an ItemList of DeleteActions with the given extra properties
+func batchDocument(options map[string]interface{}, items ...map[string]interface{}) map[string]interface{} {
+	elements := make([]interface{}, len(items))
+	for i, item := range items {
+		if item["@type"] == nil {
+			item["@type"] = "DeleteAction"
+		}
+		elements[i] = item
+	}
+	return map[string]interface{}{"@type": "ItemList", "additionalProperty": options, "itemListElement": elements}
+}
+
+func TestParseBatch(t *testing.T) {
+	items, opts, err := parseBatch(batchDocument(
+		map[string]interface{}{"concurrency": 8, "onError": "stop"},
+		map[string]interface{}{"identifier": "copy", "@type": "TransferAction"},
+		map[string]interface{}{"additionalProperty": map[string]interface{}{"dependsOn": "copy"}},
+		map[string]interface{}{"@type": "ListItem", "item": map[string]interface{}{"@type": "DeleteAction", "dependsOn": []interface{}{1.0, 2.0}}},
+	))
+	if err != nil {
+		t.Fatalf("parseBatch failed: %v", err)
+	}
+	if opts.Concurrency != 8 || !opts.StopOnError || opts.Ordered {
+		t.Errorf("unexpected options %+v", opts)
+	}
+	if len(items) != 3 || items[2].Document["@type"] != "DeleteAction" {
+		t.Fatalf("expected ListItems to be unwrapped, got %+v", items)
+	}
+	if len(items[1].DependsOn) != 1 || items[1].DependsOn[0] != 0 {
+		t.Errorf("expected a dependency by identifier, got %v", items[1].DependsOn)
+	}
+	if len(items[2].DependsOn) != 2 || items[2].DependsOn[1] != 1 {
+		t.Errorf("expected dependencies by position, got %v", items[2].DependsOn)
+	}
+
+	invalid := map[string]map[string]interface{}{
+		"empty":       batchDocument(nil),
+		"concurrency": batchDocument(map[string]interface{}{"concurrency": 100}, map[string]interface{}{}),
+		"onError":     batchDocument(map[string]interface{}{"onError": "retry"}, map[string]interface{}{}),
+		"unknown":     batchDocument(nil, map[string]interface{}{"dependsOn": "missing"}),
+		"self":        batchDocument(nil, map[string]interface{}{"dependsOn": 1.0}),
+		"cycle":       batchDocument(nil, map[string]interface{}{"dependsOn": 2.0}, map[string]interface{}{"dependsOn": 1.0}),
+		"duplicate":   batchDocument(nil, map[string]interface{}{"identifier": "a"}, map[string]interface{}{"identifier": "a"}),
+		"nested":      batchDocument(nil, map[string]interface{}{"@type": "ItemList"}),
+		"async item":  batchDocument(nil, map[string]interface{}{"additionalProperty": map[string]interface{}{"async": true}}),
+		"stream":      batchDocument(nil, map[string]interface{}{"@type": "DownloadAction", "additionalProperty": map[string]interface{}{"stream": true}}),
+		"migration":   batchDocument(nil, map[string]interface{}{"@type": "TransferAction", "additionalProperty": map[string]interface{}{"migrate": true}}),
+	}
+	for name, doc := range invalid {
+		if _, _, err := parseBatch(doc); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// batchRun returns a run func that fails the named items and records the order items ran in
+func batchRun(failing ...string) (func(*batchItem) batchOutcome, func() []string) {
+	var mu sync.Mutex
+	var order []string
+	run := func(item *batchItem) batchOutcome {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		order = append(order, item.Identifier)
+		mu.Unlock()
+		for _, id := range failing {
+			if id == item.Identifier {
+				return batchOutcome{Status: actionStatusFailed, Document: map[string]interface{}{"identifier": id}}
+			}
+		}
+		return batchOutcome{Status: actionStatusCompleted, Document: map[string]interface{}{"identifier": item.Identifier}}
+	}
+	return run, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), order...)
+	}
+}
+
+func batchStatuses(outcomes []batchOutcome) string {
+	names := make([]string, len(outcomes))
+	for i, outcome := range outcomes {
+		names[i] = strings.TrimSuffix(outcome.Status, "ActionStatus")
+	}
+	return strings.Join(names, ",")
+}
+
+func TestRunBatch(t *testing.T) {
+	items := func(t *testing.T, options map[string]interface{}, elements ...map[string]interface{}) ([]*batchItem, batchOptions) {
+		t.Helper()
+		parsed, opts, err := parseBatch(batchDocument(options, elements...))
+		if err != nil {
+			t.Fatalf("parseBatch failed: %v", err)
+		}
+		return parsed, opts
+	}
+
+	t.Run("ordered", func(t *testing.T) {
+		list, opts := items(t, map[string]interface{}{"ordered": true},
+			map[string]interface{}{"identifier": "a"}, map[string]interface{}{"identifier": "b"},
+			map[string]interface{}{"identifier": "c"}, map[string]interface{}{"identifier": "d"})
+		run, order := batchRun("b")
+		outcomes := runBatch(context.Background(), list, opts, run)
+		if got := strings.Join(order(), ""); got != "abcd" {
+			t.Errorf("expected list order, got %s", got)
+		}
+		if got := batchStatuses(outcomes); got != "Completed,Failed,Completed,Completed" {
+			t.Errorf("expected the batch to continue after a failure, got %s", got)
+		}
+
+		// Waiting for a later item would deadlock behind the list order
+		forward := batchDocument(map[string]interface{}{"ordered": true},
+			map[string]interface{}{"identifier": "a", "dependsOn": "c"},
+			map[string]interface{}{"identifier": "b"}, map[string]interface{}{"identifier": "c"})
+		if _, _, err := parseBatch(forward); err == nil {
+			t.Error("expected a dependency on a later item to be rejected in an ordered batch")
+		}
+	})
+
+	t.Run("stop on error", func(t *testing.T) {
+		list, opts := items(t, map[string]interface{}{"ordered": true, "onError": "stop"},
+			map[string]interface{}{"identifier": "a"}, map[string]interface{}{"identifier": "b"},
+			map[string]interface{}{"identifier": "c"})
+		run, order := batchRun("b")
+		outcomes := runBatch(context.Background(), list, opts, run)
+		if got := strings.Join(order(), ""); got != "ab" {
+			t.Errorf("expected the batch to stop after b, ran %s", got)
+		}
+		if got := batchStatuses(outcomes); got != "Completed,Failed,Potential" {
+			t.Errorf("unexpected statuses %s", got)
+		}
+	})
+
+	t.Run("dependencies", func(t *testing.T) {
+		list, opts := items(t, map[string]interface{}{"concurrency": 4},
+			map[string]interface{}{"identifier": "delete", "dependsOn": "copy"},
+			map[string]interface{}{"identifier": "copy"},
+			map[string]interface{}{"identifier": "broken"},
+			map[string]interface{}{"identifier": "cleanup", "dependsOn": []interface{}{"copy", "broken"}})
+		run, order := batchRun("broken")
+		outcomes := runBatch(context.Background(), list, opts, run)
+
+		ran := order()
+		position := map[string]int{}
+		for i, id := range ran {
+			position[id] = i
+		}
+		if _, ok := position["cleanup"]; ok || len(ran) != 3 {
+			t.Errorf("expected cleanup to be skipped, ran %v", ran)
+		}
+		if position["delete"] < position["copy"] {
+			t.Errorf("expected delete to run after copy, ran %v", ran)
+		}
+		if got := batchStatuses(outcomes); got != "Completed,Completed,Failed,Potential" {
+			t.Errorf("unexpected statuses %s", got)
+		}
+
+		result := batchResult(list, outcomes)
+		if result["actionStatus"] != actionStatusFailed || result["completedCount"] != 2 || result["skippedCount"] != 1 {
+			t.Errorf("unexpected batch result %v", result)
+		}
+		first := result["itemListElement"].([]interface{})[0].(map[string]interface{})
+		if first["position"] != 1 || first["item"].(map[string]interface{})["identifier"] != "delete" {
+			t.Errorf("expected outcomes in list order, got %v", first)
+		}
+	})
+}
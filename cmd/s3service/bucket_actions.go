@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/labstack/echo/v4"
+)
+
+// isBucketObject reports whether an action targets a bucket rather than an object.
+// Buckets are sent as DataCatalog objects; the legacy REST shape used a Thing
+// with identifier "bucket".
+func isBucketObject(action *semantic.SemanticAction) bool {
+	object := actionNode(action, "object")
+	if object == nil {
+		return false
+	}
+	objectType, _ := object["@type"].(string)
+	identifier, _ := object["identifier"].(string)
+	return objectType == "DataCatalog" || (objectType == "Thing" && identifier == "bucket")
+}
+
+// bucketNameFromAction returns the bucket a DataCatalog action names, falling back to
+// the target's bucket. Only reads and bucket creation use the fallback; operations that
+// delete or reconfigure a bucket take namedBucket so a bare DataCatalog never hits the
+// profile's bucket by accident.
+func bucketNameFromAction(action *semantic.SemanticAction, target *storageTarget) string {
+	if name := namedBucket(action); name != "" {
+		return name
+	}
+	return target.Bucket
+}
+
+// namedBucket returns the bucket a DataCatalog action names with object.name or
+// object.identifier, or "" when it names none
+func namedBucket(action *semantic.SemanticAction) string {
+	object := actionNode(action, "object")
+	if name := asString(object["name"]); name != "" {
+		return name
+	}
+	if identifier := asString(object["identifier"]); identifier != "" && identifier != "bucket" {
+		return identifier
+	}
+	return ""
+}
+
+// executeCreateBucketActionImpl creates a bucket with optional versioning and object lock
+func executeCreateBucketActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	// Resolve the storage profile (or inline target credentials)
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+
+	object := actionNode(action, "object")
+	bucketName := bucketNameFromAction(action, target)
+	if bucketName == "" {
+		return returnActionError(c, action, "Bucket name is required", nil)
+	}
+
+	// Bucket region may differ from the region used to sign requests
+	bucketRegion := target.Region
+	if value, ok := lookupProperty(object, "region"); ok && asString(value) != "" {
+		bucketRegion = asString(value)
+	}
+	versioning := boolOption(action, "versioning")
+	objectLock := boolOption(action, "objectLock")
+
+	// Create S3 client
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(bucketName),
+	}
+	// us-east-1 is the default location and must not be sent as a constraint
+	if bucketRegion != "" && bucketRegion != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(bucketRegion),
+		}
+	}
+	if objectLock {
+		input.ObjectLockEnabledForBucket = aws.Bool(true)
+	}
+
+	if _, err := client.CreateBucket(ctx, input); err != nil {
+		return returnActionError(c, action, "Failed to create bucket", err)
+	}
+
+	// Object lock implicitly enables versioning, so only set it when requested without lock
+	if versioning && !objectLock {
+		_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+			Bucket: aws.String(bucketName),
+			VersioningConfiguration: &types.VersioningConfiguration{
+				Status: types.BucketVersioningStatusEnabled,
+			},
+		})
+		if err != nil {
+			return returnActionError(c, action, "Bucket created but failed to enable versioning", err)
+		}
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "DataCatalog",
+		Format: "application/json",
+		Value: bucketCatalog(bucketName, time.Now(), map[string]interface{}{
+			"region":     bucketRegion,
+			"versioning": versioning || objectLock,
+			"objectLock": objectLock,
+		}),
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
+
+// bucketCatalog describes a bucket as a Schema.org DataCatalog with properties as its
+// additionalProperty. A zero creation time (unknown) is left out.
+func bucketCatalog(name string, created time.Time, properties map[string]interface{}) map[string]interface{} {
+	catalog := map[string]interface{}{
+		"@type":              "DataCatalog",
+		"identifier":         name,
+		"name":               name,
+		"url":                fmt.Sprintf("s3://%s", name),
+		"additionalProperty": properties,
+	}
+	if !created.IsZero() {
+		catalog["dateCreated"] = created.Format(time.RFC3339)
+	}
+	return catalog
+}
+
+// executeListBucketsActionImpl lists the buckets of the resolved storage as DataCatalogs
+func executeListBucketsActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	// Resolve the storage profile (or inline target credentials)
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	catalogs := []interface{}{}
+	paginator := s3.NewListBucketsPaginator(client, &s3.ListBucketsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return returnActionError(c, action, "Failed to list buckets", err)
+		}
+		for _, bucket := range page.Buckets {
+			name := aws.ToString(bucket.Name)
+			region := aws.ToString(bucket.BucketRegion)
+			if region == "" {
+				region = bucketLocation(ctx, client, name)
+			}
+			// ListBuckets reports neither versioning nor Object Lock, so only the region is known
+			catalogs = append(catalogs, bucketCatalog(name, aws.ToTime(bucket.CreationDate), map[string]interface{}{"region": region}))
+		}
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "ItemList",
+		Format: "application/json",
+		Value: map[string]interface{}{
+			"@type":           "ItemList",
+			"numberOfItems":   len(catalogs),
+			"itemListElement": catalogs,
+		},
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
+
+// bucketLocation asks for a bucket's region when ListBuckets does not report it.
+// Failures leave the region empty rather than failing the whole listing.
+func bucketLocation(ctx context.Context, client *s3.Client, bucketName string) string {
+	output, err := client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		return ""
+	}
+	if output.LocationConstraint == "" {
+		// An empty constraint is the default region
+		return "us-east-1"
+	}
+	return string(output.LocationConstraint)
+}
+
+// executeDeleteBucketActionImpl deletes a bucket. With the "force" option the bucket is
+// emptied first: every object version, delete marker and incomplete multipart upload.
+func executeDeleteBucketActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	// Resolve the storage profile (or inline target credentials)
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+
+	bucketName := namedBucket(action)
+	if bucketName == "" {
+		return returnActionError(c, action, "Bucket name is required: set the DataCatalog name or identifier", nil)
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	deleted := 0
+	if boolOption(action, "force") {
+		deleted, err = emptyBucket(ctx, client, bucketName)
+		if err != nil {
+			return returnActionError(c, action, "Failed to empty bucket", err)
+		}
+	}
+
+	if _, err := client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucketName)}); err != nil {
+		if s3StatusCode(err) == http.StatusConflict {
+			return returnActionError(c, action, "Bucket is not empty; set force to delete its contents", err)
+		}
+		return returnActionError(c, action, "Failed to delete bucket", err)
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "DataCatalog",
+		Format: "application/json",
+		Value: map[string]interface{}{
+			"@type":          "DataCatalog",
+			"identifier":     bucketName,
+			"name":           bucketName,
+			"url":            fmt.Sprintf("s3://%s", bucketName),
+			"deletedObjects": deleted,
+		},
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
+
+// emptyBucket deletes every object version and delete marker, falling back to plain
+// object listing on stores without versioning support, and aborts incomplete uploads.
+// It returns the number of deleted entries.
+func emptyBucket(ctx context.Context, client *s3.Client, bucketName string) (int, error) {
+	deleted := 0
+	deletePage := func(objects []types.ObjectIdentifier) error {
+		for _, outcome := range deleteObjectIdentifiers(ctx, client, bucketName, objects) {
+			if outcome.Error != "" {
+				return fmt.Errorf("failed to delete %s: %s", outcome.Key, outcome.Error)
+			}
+			deleted++
+		}
+		return nil
+	}
+
+	versions := s3.NewListObjectVersionsPaginator(client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucketName),
+	})
+	for versions.HasMorePages() {
+		page, err := versions.NextPage(ctx)
+		if s3StatusCode(err) == http.StatusNotImplemented && deleted == 0 {
+			return emptyUnversionedBucket(ctx, client, bucketName)
+		}
+		if err != nil {
+			return deleted, err
+		}
+
+		objects := make([]types.ObjectIdentifier, 0, len(page.Versions)+len(page.DeleteMarkers))
+		for _, version := range page.Versions {
+			objects = append(objects, types.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+		}
+		for _, marker := range page.DeleteMarkers {
+			objects = append(objects, types.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+		}
+		if err := deletePage(objects); err != nil {
+			return deleted, err
+		}
+	}
+
+	if _, err := abortStaleUploads(ctx, client, bucketName, 0); err != nil {
+		return deleted, fmt.Errorf("failed to abort incomplete uploads: %w", err)
+	}
+	return deleted, nil
+}
+
+// emptyUnversionedBucket deletes every object of a store without ListObjectVersions
+func emptyUnversionedBucket(ctx context.Context, client *s3.Client, bucketName string) (int, error) {
+	deleted := 0
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return deleted, err
+		}
+		keys := make([]string, 0, len(page.Contents))
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+		for _, outcome := range deleteKeys(ctx, client, bucketName, keys) {
+			if outcome.Error != "" {
+				return deleted, fmt.Errorf("failed to delete %s: %s", outcome.Key, outcome.Error)
+			}
+			deleted++
+		}
+	}
+
+	if _, err := abortStaleUploads(ctx, client, bucketName, 0); err != nil {
+		return deleted, fmt.Errorf("failed to abort incomplete uploads: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEmptyBucket_Versioned(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "empty-versioned"))
+	fake.versioned = true
+	fake.objects["old/a.txt"] = []byte("a")
+	fake.objects["old/b.txt"] = []byte("b")
+	fake.objects["other/c.txt"] = []byte("c")
+	fake.markers = map[string]bool{"old/gone.txt": true}
+
+	deleted, err := emptyBucket(context.Background(), client, "old")
+	if err != nil {
+		t.Fatalf("empty failed: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("expected 2 versions and 1 delete marker, got %d", deleted)
+	}
+	if len(fake.markers) != 0 {
+		t.Error("delete marker was not removed")
+	}
+	if _, ok := fake.object("other", "c.txt"); !ok {
+		t.Error("other buckets must not be touched")
+	}
+}
+
+func TestEmptyBucket_UnversionedFallback(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "empty-plain"))
+	fake.objects["plain/a.txt"] = []byte("a")
+	fake.objects["plain/dir/b.txt"] = []byte("b")
+
+	deleted, err := emptyBucket(context.Background(), client, "plain")
+	if err != nil {
+		t.Fatalf("empty failed: %v", err)
+	}
+	if deleted != 2 || fake.count("GET /plain?list-type=2") == 0 {
+		t.Errorf("expected the object listing fallback to delete 2 objects, got %d", deleted)
+	}
+}
+
+func TestBucketCatalog(t *testing.T) {
+	entry := bucketCatalog("logs", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), map[string]interface{}{"region": "fsn1"})
+	if entry["dateCreated"] != "2024-01-02T03:04:05Z" {
+		t.Errorf("unexpected dateCreated %v", entry["dateCreated"])
+	}
+	if region := entry["additionalProperty"].(map[string]interface{})["region"]; region != "fsn1" {
+		t.Errorf("unexpected region %v", region)
+	}
+	if _, ok := bucketCatalog("new", time.Time{}, nil)["dateCreated"]; ok {
+		t.Error("expected no dateCreated for an unknown creation time")
+	}
+}
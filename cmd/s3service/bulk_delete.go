@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/labstack/echo/v4"
+)
+
+// ============================================================================
+// Bulk Delete
+// ============================================================================
+
+const (
+	defaultBulkDeleteLimit = 1000 // objects one action may delete unless maxObjects says otherwise
+	maxDeleteBatch         = 1000 // S3 limit on keys per DeleteObjects call
+)
+
+// bulkDeleteOptions selects the objects of a bulk DeleteAction
+type bulkDeleteOptions struct {
+	Prefix string
+	Keys   []string
+	DryRun bool
+	Limit  int64
+}
+
+// isBulkDelete reports whether a DeleteAction targets a prefix or a key list
+func isBulkDelete(action *semantic.SemanticAction) bool {
+	_, hasPrefix := actionOption(action, "prefix")
+	_, hasKeys := actionOption(action, "keys")
+	return hasPrefix || hasKeys
+}
+
+// bulkDeleteOptionsFromAction reads prefix, keys, dryRun and maxObjects options
+func bulkDeleteOptionsFromAction(action *semantic.SemanticAction) (bulkDeleteOptions, error) {
+	limit, err := intOption(action, "maxObjects", defaultBulkDeleteLimit)
+	if err != nil {
+		return bulkDeleteOptions{}, err
+	}
+	if limit < 1 {
+		return bulkDeleteOptions{}, fmt.Errorf("maxObjects must be positive")
+	}
+
+	opts := bulkDeleteOptions{
+		Prefix: stringOption(action, "prefix"),
+		DryRun: boolOption(action, "dryRun"),
+		Limit:  limit,
+	}
+
+	if value, ok := actionOption(action, "keys"); ok {
+		entries, ok := value.([]interface{})
+		if !ok {
+			return bulkDeleteOptions{}, fmt.Errorf("keys must be a list")
+		}
+		for _, entry := range entries {
+			// Entries are plain keys or objects with an identifier
+			key := asString(entry)
+			if node, ok := entry.(map[string]interface{}); ok {
+				key = asString(node["identifier"])
+			}
+			if key == "" {
+				return bulkDeleteOptions{}, fmt.Errorf("invalid key %v", entry)
+			}
+			opts.Keys = append(opts.Keys, key)
+		}
+	}
+
+	switch {
+	case opts.Prefix != "" && opts.Keys != nil:
+		return bulkDeleteOptions{}, fmt.Errorf("set either prefix or keys, not both")
+	case opts.Prefix == "" && len(opts.Keys) == 0:
+		return bulkDeleteOptions{}, fmt.Errorf("a non-empty prefix or keys list is required")
+	}
+	return opts, nil
+}
+
+// collectDeleteKeys returns the keys a bulk delete applies to. It fails rather than
+// deleting a partial set when more than opts.Limit objects match.
+func collectDeleteKeys(ctx context.Context, client *s3.Client, bucketName string, opts bulkDeleteOptions) ([]string, error) {
+	if opts.Keys != nil {
+		if int64(len(opts.Keys)) > opts.Limit {
+			return nil, fmt.Errorf("%d keys exceed the limit of %d objects; set maxObjects to override", len(opts.Keys), opts.Limit)
+		}
+		return opts.Keys, nil
+	}
+
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(opts.Prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+		if int64(len(keys)) > opts.Limit {
+			return nil, fmt.Errorf("prefix %q matches more than %d objects; set maxObjects to override", opts.Prefix, opts.Limit)
+		}
+	}
+	return keys, nil
+}
+
+// deleteOutcome is the result of deleting one key, or one version of it
+type deleteOutcome struct {
+	Key       string
+	VersionID string
+	Error     string
+}
+
+// deleteKeys removes keys in DeleteObjects batches and reports every key's outcome
+func deleteKeys(ctx context.Context, client *s3.Client, bucketName string, keys []string) []deleteOutcome {
+	objects := make([]types.ObjectIdentifier, 0, len(keys))
+	for _, key := range keys {
+		objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+	}
+	return deleteObjectIdentifiers(ctx, client, bucketName, objects)
+}
+
+// deleteObjectIdentifiers removes objects (optionally specific versions) in DeleteObjects
+// batches. A failed batch marks all of its objects as failed and the remaining batches still run.
+func deleteObjectIdentifiers(ctx context.Context, client *s3.Client, bucketName string, objects []types.ObjectIdentifier) []deleteOutcome {
+	outcomes := make([]deleteOutcome, 0, len(objects))
+	for start := 0; start < len(objects); start += maxDeleteBatch {
+		end := start + maxDeleteBatch
+		if end > len(objects) {
+			end = len(objects)
+		}
+		batch := objects[start:end]
+
+		output, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucketName),
+			Delete: &types.Delete{Objects: batch, Quiet: aws.Bool(true)},
+		})
+		reportProgress(ctx, 0, int64(len(batch)))
+		if err != nil {
+			for _, object := range batch {
+				outcomes = append(outcomes, deleteOutcome{Key: aws.ToString(object.Key), VersionID: aws.ToString(object.VersionId), Error: err.Error()})
+			}
+			continue
+		}
+
+		// Quiet mode only reports failures; every other object was deleted
+		failed := map[string]string{}
+		for _, e := range output.Errors {
+			failed[aws.ToString(e.Key)+"\x00"+aws.ToString(e.VersionId)] = fmt.Sprintf("%s: %s", aws.ToString(e.Code), aws.ToString(e.Message))
+		}
+		for _, object := range batch {
+			key, versionID := aws.ToString(object.Key), aws.ToString(object.VersionId)
+			outcomes = append(outcomes, deleteOutcome{Key: key, VersionID: versionID, Error: failed[key+"\x00"+versionID]})
+		}
+	}
+	return outcomes
+}
+
+// executeBulkDeleteActionImpl deletes every object under a prefix, or a list of keys.
+// With dryRun the matching keys are returned without deleting anything.
+func executeBulkDeleteActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	// Resolve the storage profile (or inline target credentials) and bucket
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+	bucketName := target.Bucket
+
+	opts, err := bulkDeleteOptionsFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid bulk delete options", err)
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	keys, err := collectDeleteKeys(ctx, client, bucketName, opts)
+	if err != nil {
+		return returnActionError(c, action, "Failed to select objects", err)
+	}
+
+	var outcomes []deleteOutcome
+	if opts.DryRun {
+		for _, key := range keys {
+			outcomes = append(outcomes, deleteOutcome{Key: key})
+		}
+	} else {
+		outcomes = deleteKeys(ctx, client, bucketName, keys)
+	}
+
+	entries := make([]interface{}, 0, len(outcomes))
+	deleted, failed := 0, 0
+	for _, outcome := range outcomes {
+		entry := map[string]interface{}{
+			"@type":      "DigitalDocument",
+			"identifier": outcome.Key,
+			"contentUrl": fmt.Sprintf("s3://%s/%s", bucketName, outcome.Key),
+		}
+		switch {
+		case opts.DryRun:
+			entry["status"] = "wouldDelete"
+		case outcome.Error != "":
+			entry["status"] = "failed"
+			entry["error"] = outcome.Error
+			failed++
+		default:
+			entry["status"] = "deleted"
+			deleted++
+		}
+		entries = append(entries, entry)
+	}
+
+	value := map[string]interface{}{
+		"@type":        "Dataset",
+		"name":         bucketName,
+		"hasPart":      entries,
+		"dryRun":       opts.DryRun,
+		"matchedCount": len(keys),
+		"deletedCount": deleted,
+		"failedCount":  failed,
+	}
+	if opts.Prefix != "" {
+		value["prefix"] = opts.Prefix
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "Dataset",
+		Format: "application/json",
+		Value:  value,
+	}
+
+	if failed > 0 {
+		return returnActionError(c, action, fmt.Sprintf("%d of %d objects could not be deleted", failed, len(keys)), nil)
+	}
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
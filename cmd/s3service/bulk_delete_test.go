@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCollectDeleteKeys_Limit(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "bulk-limit"))
+	for i := 0; i < 5; i++ {
+		fake.objects[fmt.Sprintf("bucket/tmp/%d.log", i)] = []byte("x")
+	}
+	fake.objects["bucket/keep.txt"] = []byte("x")
+
+	keys, err := collectDeleteKeys(context.Background(), client, "bucket", bulkDeleteOptions{Prefix: "tmp/", Limit: 5})
+	if err != nil || len(keys) != 5 {
+		t.Fatalf("expected 5 keys, got %v, %v", keys, err)
+	}
+
+	_, err = collectDeleteKeys(context.Background(), client, "bucket", bulkDeleteOptions{Prefix: "tmp/", Limit: 4})
+	if err == nil || !strings.Contains(err.Error(), "maxObjects") {
+		t.Fatalf("expected the safety cap to refuse, got %v", err)
+	}
+	if fake.count("POST /bucket?delete") != 0 {
+		t.Error("nothing should be deleted when the cap is exceeded")
+	}
+}
+
+func TestDeleteKeys_BatchesAndReportsFailures(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "bulk-delete"))
+
+	var keys []string
+	for i := 0; i < maxDeleteBatch+10; i++ {
+		key := fmt.Sprintf("logs/%05d.log", i)
+		fake.objects["bucket/"+key] = []byte("x")
+		keys = append(keys, key)
+	}
+	fake.denyDelete = map[string]bool{"bucket/logs/00003.log": true}
+
+	outcomes := deleteKeys(context.Background(), client, "bucket", keys)
+	if len(outcomes) != len(keys) {
+		t.Fatalf("expected %d outcomes, got %d", len(keys), len(outcomes))
+	}
+	if got := fake.count("POST /bucket?delete"); got != 2 {
+		t.Errorf("expected 2 DeleteObjects batches, got %d", got)
+	}
+
+	failed := 0
+	for _, outcome := range outcomes {
+		if outcome.Error != "" {
+			failed++
+			if outcome.Key != "logs/00003.log" {
+				t.Errorf("unexpected failure for %s", outcome.Key)
+			}
+		}
+	}
+	if failed != 1 {
+		t.Errorf("expected exactly one failed key, got %d", failed)
+	}
+	if _, ok := fake.object("bucket", "logs/00003.log"); !ok {
+		t.Error("denied key should still exist")
+	}
+	if _, ok := fake.object("bucket", "logs/00004.log"); ok {
+		t.Error("deleted key still exists")
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strings"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ============================================================================
+// Checksums
+// ============================================================================
+
+const (
+	checksumSHA256 = "sha256"
+	checksumCRC32C = "crc32c"
+	checksumMD5    = "md5"
+)
+
+// checksumAlgorithms lists the supported algorithms in preference order
+var checksumAlgorithms = []string{checksumSHA256, checksumCRC32C, checksumMD5}
+
+// errChecksumMismatch marks content that does not match an expected digest
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// checksumSpec holds the digests an action expects and the algorithms to compute
+type checksumSpec struct {
+	Expected map[string][]byte
+	Compute  []string
+}
+
+// checksumSpecFromAction reads expected digests from the sha256, crc32c and md5
+// properties (on the action, its object, or additionalProperty), as hex or base64.
+// The checksumAlgorithm option lists extra algorithms to compute. Without either
+// nothing is hashed locally.
+func checksumSpecFromAction(action *semantic.SemanticAction) (checksumSpec, error) {
+	spec := checksumSpec{Expected: map[string][]byte{}}
+	for _, algorithm := range checksumAlgorithms {
+		value := stringOption(action, algorithm)
+		if value == "" {
+			continue
+		}
+		digest, err := decodeDigest(algorithm, value)
+		if err != nil {
+			return checksumSpec{}, err
+		}
+		spec.Expected[algorithm] = digest
+		spec.Compute = append(spec.Compute, algorithm)
+	}
+
+	for _, algorithm := range strings.Split(stringOption(action, "checksumAlgorithm"), ",") {
+		algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+		if algorithm == "" {
+			continue
+		}
+		if newChecksumHash(algorithm) == nil {
+			return checksumSpec{}, fmt.Errorf("unsupported checksumAlgorithm %q", algorithm)
+		}
+		spec.add(algorithm)
+	}
+	return spec, nil
+}
+
+// add schedules an algorithm for computation
+func (s *checksumSpec) add(algorithm string) {
+	for _, existing := range s.Compute {
+		if existing == algorithm {
+			return
+		}
+	}
+	s.Compute = append(s.Compute, algorithm)
+}
+
+// expect records an expected digest unless the action already supplied one
+func (s *checksumSpec) expect(algorithm string, digest []byte) {
+	if _, ok := s.Expected[algorithm]; ok || digest == nil {
+		return
+	}
+	if s.Expected == nil {
+		s.Expected = map[string][]byte{}
+	}
+	s.Expected[algorithm] = digest
+	s.add(algorithm)
+}
+
+// expectStored adds the checksums S3 holds for an object. Composite (multipart)
+// checksums and ETags that are not content MD5s are ignored.
+func (s *checksumSpec) expectStored(head *s3.HeadObjectOutput) {
+	if head.ChecksumType != types.ChecksumTypeComposite {
+		s.expect(checksumSHA256, decodeStoredChecksum(aws.ToString(head.ChecksumSHA256)))
+		s.expect(checksumCRC32C, decodeStoredChecksum(aws.ToString(head.ChecksumCRC32C)))
+	}
+	encrypted := head.ServerSideEncryption == types.ServerSideEncryptionAwsKms ||
+		head.ServerSideEncryption == types.ServerSideEncryptionAwsKmsDsse ||
+		head.SSECustomerAlgorithm != nil
+	if md5Hex := etagMD5(aws.ToString(head.ETag)); md5Hex != "" && !encrypted {
+		digest, _ := hex.DecodeString(md5Hex)
+		s.expect(checksumMD5, digest)
+	}
+}
+
+// verify compares computed digests with the expected ones
+func (s checksumSpec) verify(sums map[string][]byte) error {
+	for _, algorithm := range checksumAlgorithms {
+		expected, ok := s.Expected[algorithm]
+		if !ok {
+			continue
+		}
+		if actual := sums[algorithm]; !bytes.Equal(actual, expected) {
+			return fmt.Errorf("%s %w: expected %s, got %s",
+				algorithm, errChecksumMismatch, hex.EncodeToString(expected), hex.EncodeToString(actual))
+		}
+	}
+	return nil
+}
+
+// checksumWriter hashes everything written to it with several algorithms at once
+type checksumWriter struct {
+	hashes map[string]hash.Hash
+}
+
+func newChecksumWriter(algorithms []string) *checksumWriter {
+	w := &checksumWriter{hashes: map[string]hash.Hash{}}
+	for _, algorithm := range algorithms {
+		if h := newChecksumHash(algorithm); h != nil {
+			w.hashes[algorithm] = h
+		}
+	}
+	return w
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+	for _, h := range w.hashes {
+		_, _ = h.Write(p)
+	}
+	return len(p), nil
+}
+
+// sums returns the digests of everything written so far
+func (w *checksumWriter) sums() map[string][]byte {
+	sums := make(map[string][]byte, len(w.hashes))
+	for algorithm, h := range w.hashes {
+		sums[algorithm] = h.Sum(nil)
+	}
+	return sums
+}
+
+// computeChecksums hashes a seekable body and rewinds it for the upload
+func computeChecksums(r io.ReadSeeker, algorithms []string) (map[string][]byte, error) {
+	w := newChecksumWriter(algorithms)
+	if _, err := io.Copy(w, r); err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return w.sums(), nil
+}
+
+// hashSection hashes size bytes of src in one sequential pass, stopping when ctx ends
+func hashSection(ctx context.Context, src io.ReaderAt, size int64, algorithms []string) (map[string][]byte, error) {
+	w := newChecksumWriter(algorithms)
+	r := io.NewSectionReader(src, 0, size)
+	buf := make([]byte, 1<<20)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := r.Read(buf)
+		_, _ = w.Write(buf[:n])
+		if err == io.EOF {
+			return w.sums(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// checksumReader hashes a body while it is uploaded. The SDK seeks back to the start
+// to sign or retry a request, which starts the digests over.
+type checksumReader struct {
+	io.ReadSeeker
+	algorithms []string
+	hashes     *checksumWriter
+	offset     int64
+	skipped    bool // a seek elsewhere left the digests incomplete
+}
+
+func newChecksumReader(r io.ReadSeeker, algorithms []string) *checksumReader {
+	return &checksumReader{ReadSeeker: r, algorithms: algorithms, hashes: newChecksumWriter(algorithms)}
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	_, _ = r.hashes.Write(p[:n])
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *checksumReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	if pos == 0 {
+		r.hashes = newChecksumWriter(r.algorithms)
+		r.skipped = false
+	} else if pos != r.offset {
+		r.skipped = true
+	}
+	r.offset = pos
+	return pos, nil
+}
+
+// sums returns the digests of the body as last read from the start
+func (r *checksumReader) sums() (map[string][]byte, error) {
+	if r.skipped {
+		return nil, fmt.Errorf("upload body was not read in one pass")
+	}
+	return r.hashes.sums(), nil
+}
+
+// checksumValues renders digests as hex strings for an action result
+func checksumValues(sums map[string][]byte) map[string]interface{} {
+	values := make(map[string]interface{}, len(sums))
+	algorithms := make([]string, 0, len(sums))
+	for algorithm := range sums {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	for _, algorithm := range algorithms {
+		values[algorithm] = hex.EncodeToString(sums[algorithm])
+	}
+	return values
+}
+
+// applyChecksums sends the expected digests with a PutObject so S3 rejects a body that
+// does not match. S3 accepts one flexible checksum per request; sha256 is preferred over
+// crc32c. Without either the SDK computes a SHA-256 while sending the body.
+func applyChecksums(input *s3.PutObjectInput, spec checksumSpec) {
+	sums := spec.Expected
+	if digest, ok := sums[checksumSHA256]; ok {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+		input.ChecksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(digest))
+	} else if digest, ok := sums[checksumCRC32C]; ok {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmCrc32c
+		input.ChecksumCRC32C = aws.String(base64.StdEncoding.EncodeToString(digest))
+	} else {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+	}
+	if digest, ok := sums[checksumMD5]; ok {
+		input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(digest))
+	}
+}
+
+func newChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case checksumSHA256:
+		return sha256.New()
+	case checksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case checksumMD5:
+		return md5.New()
+	default:
+		return nil
+	}
+}
+
+// decodeDigest parses a hex or base64 digest and checks its length
+func decodeDigest(algorithm, value string) ([]byte, error) {
+	size := newChecksumHash(algorithm).Size()
+	value = strings.TrimSpace(value)
+	if digest, err := hex.DecodeString(value); err == nil && len(digest) == size {
+		return digest, nil
+	}
+	if digest, err := base64.StdEncoding.DecodeString(value); err == nil && len(digest) == size {
+		return digest, nil
+	}
+	return nil, fmt.Errorf("invalid %s checksum %q: expected %d bytes as hex or base64", algorithm, value, size)
+}
+
+// decodeStoredChecksum parses a base64 checksum header, ignoring composite "-N" values
+func decodeStoredChecksum(value string) []byte {
+	if value == "" || strings.Contains(value, "-") {
+		return nil
+	}
+	digest, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil
+	}
+	return digest
+}
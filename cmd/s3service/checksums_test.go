@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestDecodeDigest(t *testing.T) {
+	// sha256("hello")
+	const hexDigest = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	raw, _ := hex.DecodeString(hexDigest)
+
+	for _, value := range []string{hexDigest, base64.StdEncoding.EncodeToString(raw)} {
+		digest, err := decodeDigest(checksumSHA256, value)
+		if err != nil || !bytes.Equal(digest, raw) {
+			t.Errorf("decodeDigest(%q) = %x, %v", value, digest, err)
+		}
+	}
+	if _, err := decodeDigest(checksumCRC32C, hexDigest); err == nil {
+		t.Error("expected a length error for a sha256 digest given as crc32c")
+	}
+}
+
+func TestChecksumSpec_Verify(t *testing.T) {
+	sums, err := computeChecksums(bytes.NewReader([]byte("hello")), checksumAlgorithms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(sums[checksumCRC32C]); got != "9a71bb4c" {
+		t.Errorf("unexpected crc32c %s", got)
+	}
+
+	spec := checksumSpec{}
+	spec.expect(checksumMD5, sums[checksumMD5])
+	if err := spec.verify(sums); err != nil {
+		t.Errorf("expected matching checksums, got %v", err)
+	}
+
+	spec.expect(checksumCRC32C, []byte{0, 0, 0, 0})
+	if err := spec.verify(sums); err == nil {
+		t.Error("expected a crc32c mismatch")
+	}
+}
+
+func TestChecksumSpec_ExpectStored(t *testing.T) {
+	spec := checksumSpec{}
+	spec.expectStored(&s3.HeadObjectOutput{
+		ETag:           aws.String(`"5d41402abc4b2a76b9719d911017c592"`),
+		ChecksumCRC32C: aws.String("mnG7TA=="),
+	})
+	if len(spec.Expected) != 2 {
+		t.Errorf("expected md5 and crc32c to be taken from the object, got %v", spec.Expected)
+	}
+
+	composite := checksumSpec{}
+	composite.expectStored(&s3.HeadObjectOutput{
+		ETag:           aws.String(`"5d41402abc4b2a76b9719d911017c592-2"`),
+		ChecksumCRC32C: aws.String("mnG7TA==-2"),
+		ChecksumType:   types.ChecksumTypeComposite,
+	})
+	if len(composite.Expected) != 0 {
+		t.Errorf("expected multipart checksums to be ignored, got %v", composite.Expected)
+	}
+}
+
+func TestPutObject_SendsChecksums(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "checksum-put"))
+
+	data := []byte("hello")
+	body := &uploadBody{Reader: bytes.NewReader(data), Size: int64(len(data))}
+	sums, _ := computeChecksums(body.Reader, checksumAlgorithms)
+	body.Checksums = checksumSpec{Expected: sums}
+
+	if _, err := putObject(context.Background(), client, "bucket", "hello.txt", body); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if stored, _ := fake.object("bucket", "hello.txt"); !bytes.Equal(stored, data) {
+		t.Errorf("stored %q", stored)
+	}
+	if got := fake.header("x-amz-checksum-sha256"); got != base64.StdEncoding.EncodeToString(sums[checksumSHA256]) {
+		t.Errorf("unexpected sha256 header %q", got)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ============================================================================
+// S3 Client Pool
+// ============================================================================
+
+// clientPoolConfig tunes the shared HTTP transport and client cache
+type clientPoolConfig struct {
+	MaxClients          int           // cached clients before the least recently used is evicted
+	ClientIdleTTL       time.Duration // unused clients are evicted after this long
+	MaxIdleConns        int           // idle connections kept across all endpoints
+	MaxIdleConnsPerHost int           // idle connections kept per endpoint
+	IdleConnTimeout     time.Duration // idle connections are closed after this long
+}
+
+// defaultClientPoolConfig is used by the service-wide pool
+var defaultClientPoolConfig = clientPoolConfig{
+	MaxClients:          256,
+	ClientIdleTTL:       15 * time.Minute,
+	MaxIdleConns:        512,
+	MaxIdleConnsPerHost: 64,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+// pooledClient is a cached client and when it was last handed out
+type pooledClient struct {
+	client   *s3.Client
+	lastUsed time.Time
+}
+
+// clientPool caches S3 clients per endpoint, region, addressing style and credential
+// fingerprint. All clients share one tuned HTTP transport, so connections (and TLS
+// sessions) are reused across actions instead of being set up per call.
+type clientPool struct {
+	cfg        clientPoolConfig
+	httpClient *awshttp.BuildableClient
+
+	// loadBase loads the default config; base caches it once that succeeds
+	loadBase func() (aws.Config, error)
+	base     *aws.Config
+
+	mu        sync.Mutex
+	clients   map[string]*pooledClient
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// s3Clients is the service-wide client pool
+var s3Clients = newClientPool(defaultClientPoolConfig)
+
+func newClientPool(cfg clientPoolConfig) *clientPool {
+	httpClient := awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+		tr.MaxIdleConns = cfg.MaxIdleConns
+		tr.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+		tr.IdleConnTimeout = cfg.IdleConnTimeout
+	})
+
+	return &clientPool{
+		cfg:        cfg,
+		httpClient: httpClient,
+		// Not bound to a request: a cancelled first request must not break the pool
+		loadBase: func() (aws.Config, error) {
+			return config.LoadDefaultConfig(context.Background(), config.WithHTTPClient(httpClient))
+		},
+		clients: map[string]*pooledClient{},
+		now:     time.Now,
+	}
+}
+
+// clientKey fingerprints a target's connection settings. Secrets are hashed so they
+// never appear in map keys, logs or heap dumps of the pool.
+func clientKey(target *storageTarget) string {
+	h := sha256.New()
+	for _, part := range []string{target.Endpoint, target.Region, fmt.Sprint(target.PathStyle), target.AccessKey, target.SecretKey} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the cached client for a target, creating it on first use
+func (p *clientPool) Get(ctx context.Context, target *storageTarget) (*s3.Client, error) {
+	key := clientKey(target)
+	now := p.now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sweep(now)
+	if entry, ok := p.clients[key]; ok {
+		entry.lastUsed = now
+		return entry.client, nil
+	}
+
+	client, err := p.newClient(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p.clients) >= p.cfg.MaxClients {
+		p.evictOldest()
+	}
+	p.clients[key] = &pooledClient{client: client, lastUsed: now}
+	return client, nil
+}
+
+// Len returns the number of cached clients
+func (p *clientPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.clients)
+}
+
+// newClient builds a client from the shared base config. The default config
+// (environment, shared config files) is loaded once per pool, not per action; a
+// failed load is retried by the next call. The caller holds p.mu.
+func (p *clientPool) newClient(target *storageTarget) (*s3.Client, error) {
+	if p.base == nil {
+		base, err := p.loadBase()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		p.base = &base
+	}
+
+	cfg := p.base.Copy()
+	if target.Region != "" {
+		cfg.Region = target.Region
+	}
+	cfg.Credentials = aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(target.AccessKey, target.SecretKey, ""))
+
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(target.Endpoint)
+		o.UsePathStyle = target.PathStyle
+	}), nil
+}
+
+// sweep evicts clients unused for longer than the idle TTL, at most once per TTL/4
+func (p *clientPool) sweep(now time.Time) {
+	if p.cfg.ClientIdleTTL <= 0 || now.Sub(p.lastSweep) < p.cfg.ClientIdleTTL/4 {
+		return
+	}
+	p.lastSweep = now
+	for key, entry := range p.clients {
+		if now.Sub(entry.lastUsed) > p.cfg.ClientIdleTTL {
+			delete(p.clients, key)
+		}
+	}
+}
+
+// evictOldest removes the least recently used client
+func (p *clientPool) evictOldest() {
+	oldestKey := ""
+	var oldest time.Time
+	for key, entry := range p.clients {
+		if oldestKey == "" || entry.lastUsed.Before(oldest) {
+			oldestKey = key
+			oldest = entry.lastUsed
+		}
+	}
+	if oldestKey != "" {
+		delete(p.clients, oldestKey)
+	}
+}
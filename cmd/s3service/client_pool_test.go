@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func fakeTarget(endpoint, accessKey string) *storageTarget {
+	return &storageTarget{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		AccessKey: accessKey,
+		SecretKey: "secret",
+		Bucket:    "bucket",
+		PathStyle: true,
+	}
+}
+
+func TestClientPool_ReusesClients(t *testing.T) {
+	pool := newClientPool(defaultClientPoolConfig)
+	ctx := context.Background()
+
+	first, err := pool.Get(ctx, fakeTarget("http://localhost:9000", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := pool.Get(ctx, fakeTarget("http://localhost:9000", "a"))
+	other, _ := pool.Get(ctx, fakeTarget("http://localhost:9000", "b"))
+
+	if first != second {
+		t.Error("expected the same client for identical targets")
+	}
+	if first == other {
+		t.Error("expected a separate client for different credentials")
+	}
+	if pool.Len() != 2 {
+		t.Errorf("expected 2 cached clients, got %d", pool.Len())
+	}
+}
+
+func TestClientPool_RetriesFailedConfigLoad(t *testing.T) {
+	pool := newClientPool(defaultClientPoolConfig)
+	loads := 0
+	pool.loadBase = func() (aws.Config, error) {
+		loads++
+		if loads == 1 {
+			return aws.Config{}, errors.New("shared config unreadable")
+		}
+		return aws.Config{}, nil
+	}
+
+	// The first request's context is already gone; the config load does not use it
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.Get(cancelled, fakeTarget("http://localhost:9000", "a")); err == nil {
+		t.Fatal("expected the failed config load to be reported")
+	}
+	if _, err := pool.Get(cancelled, fakeTarget("http://localhost:9000", "a")); err != nil {
+		t.Fatalf("expected the config load to be retried, got %v", err)
+	}
+	_, _ = pool.Get(context.Background(), fakeTarget("http://localhost:9000", "b"))
+	if loads != 2 {
+		t.Errorf("expected the config to be loaded until it succeeds and then kept, loaded %d times", loads)
+	}
+}
+
+func TestClientPool_Eviction(t *testing.T) {
+	cfg := defaultClientPoolConfig
+	cfg.MaxClients = 2
+	cfg.ClientIdleTTL = time.Minute
+	pool := newClientPool(cfg)
+
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, _ = pool.Get(ctx, fakeTarget("http://localhost:9000", "a"))
+	now = now.Add(time.Second)
+	_, _ = pool.Get(ctx, fakeTarget("http://localhost:9000", "b"))
+	now = now.Add(time.Second)
+	_, _ = pool.Get(ctx, fakeTarget("http://localhost:9000", "c"))
+
+	if pool.Len() != 2 {
+		t.Fatalf("expected LRU eviction to keep 2 clients, got %d", pool.Len())
+	}
+	if _, ok := pool.clients[clientKey(fakeTarget("http://localhost:9000", "a"))]; ok {
+		t.Error("expected the least recently used client to be evicted")
+	}
+
+	now = now.Add(2 * time.Minute)
+	_, _ = pool.Get(ctx, fakeTarget("http://localhost:9000", "d"))
+	if pool.Len() != 1 {
+		t.Errorf("expected idle clients to be swept, got %d", pool.Len())
+	}
+}
+
+// BenchmarkClientPerRequest measures the previous behaviour: a fresh config, client
+// and HTTP transport for every action
+func BenchmarkClientPerRequest(b *testing.B) {
+	fake, server := newFakeS3Server(b)
+	fake.objects["bucket/key"] = []byte{}
+	target := fakeTarget(server.URL, "bench")
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cfg, err := config.LoadDefaultConfig(ctx,
+			config.WithRegion(target.Region),
+			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(target.AccessKey, target.SecretKey, "")),
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+		client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(target.Endpoint)
+			o.UsePathStyle = true
+		})
+		if _, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkClientPooled measures pooled clients sharing one transport
+func BenchmarkClientPooled(b *testing.B) {
+	fake, server := newFakeS3Server(b)
+	fake.objects["bucket/key"] = []byte{}
+	target := fakeTarget(server.URL, "bench")
+	pool := newClientPool(defaultClientPoolConfig)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client, err := pool.Get(ctx, target)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkClientPooledParallel measures pooled clients under concurrent actions
+func BenchmarkClientPooledParallel(b *testing.B) {
+	fake, server := newFakeS3Server(b)
+	fake.objects["bucket/key"] = []byte{}
+	target := fakeTarget(server.URL, "bench")
+	pool := newClientPool(defaultClientPoolConfig)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			client, err := pool.Get(ctx, target)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"eve.evalgo.org/semantic"
+	"github.com/labstack/echo/v4"
+)
+
+// executeCopyActionImpl copies (TransferAction) or moves (MoveAction) an object.
+//
+// The source key is object.identifier and the destination key is targetUrl (default:
+// the same key). fromLocation and toLocation name the source and destination storage
+// like a target does (profile, bucket or inline credentials); either defaults to the
+// action's target. versionId (or object.version) copies that version of the source.
+func executeCopyActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	src, err := resolveLocation(action, "fromLocation")
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve source storage", err)
+	}
+	dst, err := resolveLocation(action, "toLocation")
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve destination storage", err)
+	}
+
+	object, err := semantic.GetS3ObjectFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to extract S3 object", err)
+	}
+	srcKey := object.Identifier
+	if srcKey == "" {
+		srcKey = object.Name
+	}
+	if srcKey == "" {
+		return returnActionError(c, action, "Object identifier (source key) is required", nil)
+	}
+	dstKey := semantic.GetS3TargetUrlFromAction(action)
+	if dstKey == "" {
+		dstKey = srcKey
+	}
+	srcVersion := versionIDOption(action)
+	if src.sameService(dst) && src.Bucket == dst.Bucket && srcKey == dstKey && srcVersion == "" {
+		return returnActionError(c, action, "Source and destination are the same object", nil)
+	}
+
+	opts, err := copyOptionsFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid copy options", err)
+	}
+	partOpts, err := multipartOptionsFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid multipart options", err)
+	}
+
+	srcClient, err := createS3Client(ctx, src)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+	dstClient, err := createS3Client(ctx, dst)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	copier := &objectCopy{
+		src:        src,
+		dst:        dst,
+		srcClient:  srcClient,
+		dstClient:  dstClient,
+		srcKey:     srcKey,
+		dstKey:     dstKey,
+		srcVersion: srcVersion,
+		opts:       opts,
+		parts:      partOpts,
+	}
+	result, err := copier.run(ctx)
+	if err != nil {
+		return returnActionError(c, action, "Failed to copy object", err)
+	}
+
+	value := map[string]interface{}{
+		"identifier":    dstKey,
+		"contentUrl":    fmt.Sprintf("s3://%s/%s", dst.Bucket, dstKey),
+		"sourceUrl":     fmt.Sprintf("s3://%s/%s", src.Bucket, srcKey),
+		"contentSize":   result.Size,
+		"etag":          result.ETag,
+		"copyMethod":    result.Method,
+		"deletedSource": result.DeletedSource,
+	}
+	if result.VersionID != "" {
+		value["version"] = result.VersionID
+	}
+	if srcVersion != "" {
+		value["sourceVersion"] = srcVersion
+	}
+	if result.Parts > 0 {
+		value["parts"] = result.Parts
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "DigitalDocument",
+		Format: "application/json",
+		Value:  value,
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
+
+// resolveLocation resolves a copy's source or destination, falling back to the target
+func resolveLocation(action *semantic.SemanticAction, name string) (*storageTarget, error) {
+	if actionNode(action, name) == nil {
+		return resolveStorage(action)
+	}
+	return resolveStorageNode(action, name)
+}
@@ -0,0 +1,724 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory, path-style S3 endpoint covering the calls the service makes
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte      // "bucket/key" -> content
+	stored   map[string]http.Header // "bucket/key" -> content type, stored headers and metadata
+	uploads  map[string]map[int32][]byte
+	pending  map[string]http.Header // upload ID -> headers of the completed object
+	nextID   int
+	requests []string
+	headers  http.Header // headers of the last request
+
+	// failPart, when set, makes UploadPart of that part number fail once
+	failPart int32
+	// truncateGets cuts the body of that many ranged GetObject responses short
+	truncateGets int
+	// denyDelete makes DeleteObjects report these keys ("bucket/key") as failed
+	denyDelete map[string]bool
+	// versioned enables ListObjectVersions; markers are delete markers ("bucket/key")
+	versioned bool
+	markers   map[string]bool
+	// versionIDs and history track versions once writes happen with versioned set
+	versionIDs map[string]string        // "bucket/key" -> current version ID
+	history    map[string][]fakeVersion // "bucket/key" -> noncurrent versions, oldest first
+	// lifecycle holds each bucket's lifecycle configuration XML
+	lifecycle map[string][]byte
+	// lastModified is reported for every object; zero reports the Unix epoch
+	lastModified time.Time
+	// partChecksums holds the SHA-256 each UploadPart was sent with ("uploadId/part")
+	partChecksums map[string]string
+}
+
+// fakeVersion is a noncurrent object version
+type fakeVersion struct {
+	id      string
+	content []byte
+	stored  http.Header
+}
+
+func newFakeS3Server(t testing.TB) (*fakeS3, *httptest.Server) {
+	t.Helper()
+	fake := &fakeS3{
+		objects:    map[string][]byte{},
+		stored:     map[string]http.Header{},
+		uploads:    map[string]map[int32][]byte{},
+		pending:    map[string]http.Header{},
+		versionIDs: map[string]string{},
+		history:    map[string][]fakeVersion{},
+		lifecycle:  map[string][]byte{},
+
+		partChecksums: map[string]string{},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+	f.headers = r.Header.Clone()
+
+	body, _ := io.ReadAll(r.Body)
+	body = decodeAWSChunked(r, body)
+
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		f.listBuckets(w)
+
+	case r.Method == http.MethodGet && query.Has("location"):
+		writeXML(w, "<LocationConstraint>eu-central-1</LocationConstraint>")
+
+	case r.Method == http.MethodGet && query.Has("versions"):
+		f.listVersions(w, path)
+
+	case query.Has("lifecycle"):
+		switch r.Method {
+		case http.MethodGet:
+			config, ok := f.lifecycle[path]
+			if !ok {
+				writeS3Error(w, http.StatusNotFound, "NoSuchLifecycleConfiguration")
+				return
+			}
+			writeXML(w, string(config))
+		case http.MethodPut:
+			f.lifecycle[path] = body
+			w.WriteHeader(http.StatusOK)
+		case http.MethodDelete:
+			delete(f.lifecycle, path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	case r.Method == http.MethodPut && query.Has("versioning"):
+		f.versioned = strings.Contains(string(body), "<Status>Enabled</Status>")
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodGet && query.Has("uploads"):
+		writeXML(w, "<ListMultipartUploadsResult><IsTruncated>false</IsTruncated></ListMultipartUploadsResult>")
+
+	case r.Method == http.MethodDelete && !strings.Contains(path, "/"):
+		for key := range f.objects {
+			if strings.HasPrefix(key, path+"/") {
+				writeS3Error(w, http.StatusConflict, "BucketNotEmpty")
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet && query.Get("list-type") == "2":
+		f.listObjects(w, path, query)
+
+	case r.Method == http.MethodPost && !strings.Contains(path, "/") && strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data"):
+		f.postForm(w, r, path, body)
+
+	case r.Method == http.MethodPost && query.Has("delete"):
+		f.deleteObjects(w, path, body)
+
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[id] = map[int32][]byte{}
+		f.pending[id] = storedHeaders(r.Header)
+		writeXML(w, fmt.Sprintf("<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id))
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			content, ok := f.copySource(source)
+			if !ok {
+				writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			if header := r.Header.Get("X-Amz-Copy-Source-Range"); header != "" {
+				ranges, err := parseByteRanges(header, int64(len(content)))
+				if err != nil {
+					writeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+					return
+				}
+				content = content[ranges[0].Start : ranges[0].End+1]
+			}
+			parts[int32(number)] = content
+			writeXML(w, fmt.Sprintf("<CopyPartResult><ETag>%s</ETag></CopyPartResult>", etagOf(content)))
+			return
+		}
+		if f.failPart != 0 && int32(number) == f.failPart {
+			f.failPart = 0
+			writeS3Error(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		if checksum := r.Header.Get("X-Amz-Checksum-Sha256"); checksum != "" {
+			if sum := sha256.Sum256(body); checksum != base64.StdEncoding.EncodeToString(sum[:]) {
+				writeS3Error(w, http.StatusBadRequest, "BadDigest")
+				return
+			}
+			f.partChecksums[query.Get("uploadId")+"/"+query.Get("partNumber")] = checksum
+			w.Header().Set("X-Amz-Checksum-Sha256", checksum)
+		}
+		parts[int32(number)] = body
+		w.Header().Set("ETag", etagOf(body))
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		numbers := make([]int, 0, len(parts))
+		for number := range parts {
+			numbers = append(numbers, int(number))
+		}
+		sort.Ints(numbers)
+		var content []byte
+		for _, number := range numbers {
+			content = append(content, parts[int32(number)]...)
+		}
+		f.write(w, path, content, f.pending[query.Get("uploadId")])
+		delete(f.uploads, query.Get("uploadId"))
+		writeXML(w, fmt.Sprintf("<CompleteMultipartUploadResult><ETag>%s</ETag></CompleteMultipartUploadResult>", etagOf(content)))
+
+	case r.Method == http.MethodGet && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var b strings.Builder
+		b.WriteString("<ListPartsResult><IsTruncated>false</IsTruncated>")
+		for number, data := range parts {
+			checksum := f.partChecksums[fmt.Sprintf("%s/%d", query.Get("uploadId"), number)]
+			fmt.Fprintf(&b, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag><Size>%d</Size><ChecksumSHA256>%s</ChecksumSHA256></Part>",
+				number, etagOf(data), len(data), checksum)
+		}
+		b.WriteString("</ListPartsResult>")
+		writeXML(w, b.String())
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		content, ok := f.copySource(r.Header.Get("X-Amz-Copy-Source"))
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		source, _ := f.sourceHeaders(r.Header.Get("X-Amz-Copy-Source"))
+		stored := source.Clone()
+		if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+			stored = storedHeaders(r.Header)
+		}
+		if stored == nil {
+			stored = http.Header{}
+		}
+		stored.Del("X-Amz-Tagging")
+		if r.Header.Get("X-Amz-Tagging-Directive") == "REPLACE" {
+			stored.Set("X-Amz-Tagging", r.Header.Get("X-Amz-Tagging"))
+		} else if tagging := source.Get("X-Amz-Tagging"); tagging != "" {
+			stored.Set("X-Amz-Tagging", tagging)
+		}
+		f.write(w, path, content, stored)
+		writeXML(w, fmt.Sprintf("<CopyObjectResult><ETag>%s</ETag></CopyObjectResult>", etagOf(content)))
+
+	case query.Has("tagging"):
+		f.tagging(w, r, path, body)
+
+	case r.Method == http.MethodPut:
+		f.write(w, path, body, storedHeaders(r.Header))
+		w.Header().Set("ETag", etagOf(body))
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		content, stored, ok := f.version(path, query.Get("versionId"))
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if id := query.Get("versionId"); id != "" {
+			w.Header().Set("X-Amz-Version-Id", id)
+		} else if id := f.versionIDs[path]; id != "" {
+			w.Header().Set("X-Amz-Version-Id", id)
+		}
+		for name, values := range stored {
+			if name != "X-Amz-Tagging" {
+				w.Header()[name] = values
+			}
+		}
+		w.Header().Set("ETag", etagOf(content))
+		w.Header().Set("Last-Modified", f.modified().Format(http.TimeFormat))
+		status := http.StatusOK
+		if header := r.Header.Get("Range"); header != "" {
+			ranges, err := parseByteRanges(header, int64(len(content)))
+			if err != nil {
+				writeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", ranges[0].Start, ranges[0].End, len(content)))
+			content = content[ranges[0].Start : ranges[0].End+1]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			if status == http.StatusPartialContent && f.truncateGets > 0 {
+				f.truncateGets--
+				content = content[:len(content)/2]
+			}
+			_, _ = w.Write(content)
+		}
+
+	case r.Method == http.MethodDelete && query.Has("versionId"):
+		f.deleteVersion(path, query.Get("versionId"))
+		w.Header().Set("X-Amz-Version-Id", query.Get("versionId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodDelete:
+		if f.versioned {
+			if _, ok := f.objects[path]; ok {
+				f.history[path] = append(f.history[path], fakeVersion{id: f.currentVersionID(path), content: f.objects[path], stored: f.stored[path]})
+			}
+			if f.markers == nil {
+				f.markers = map[string]bool{}
+			}
+			f.markers[path] = true
+			w.Header().Set("X-Amz-Delete-Marker", "true")
+			w.Header().Set("X-Amz-Version-Id", "m1")
+		}
+		delete(f.objects, path)
+		delete(f.stored, path)
+		delete(f.versionIDs, path)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// postForm stores a browser form upload. Policies are not enforced; the service
+// checks uploads against their policy in the upload callback.
+func (f *fakeS3) postForm(w http.ResponseWriter, r *http.Request, bucket string, body []byte) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		writeS3Error(w, http.StatusBadRequest, "MalformedPOSTRequest")
+		return
+	}
+	form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(32 << 20)
+	if err != nil || len(form.File["file"]) != 1 || len(form.Value["key"]) != 1 {
+		writeS3Error(w, http.StatusBadRequest, "MalformedPOSTRequest")
+		return
+	}
+	header := form.File["file"][0]
+	file, err := header.Open()
+	if err != nil {
+		writeS3Error(w, http.StatusBadRequest, "MalformedPOSTRequest")
+		return
+	}
+	content, _ := io.ReadAll(file)
+	file.Close()
+
+	stored := http.Header{}
+	if values := form.Value["Content-Type"]; len(values) == 1 {
+		stored.Set("Content-Type", values[0])
+	}
+	key := strings.ReplaceAll(form.Value["key"][0], "${filename}", header.Filename)
+	f.write(w, bucket+"/"+key, content, stored)
+	w.Header().Set("ETag", etagOf(content))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listObjects answers ListObjectsV2 with max-keys and key-based continuation tokens
+func (f *fakeS3) listObjects(w http.ResponseWriter, bucket string, query url.Values) {
+	prefix := bucket + "/" + query.Get("prefix")
+	after := query.Get("continuation-token")
+	if after == "" {
+		after = query.Get("start-after")
+	}
+	maxKeys := 1000
+	if value, err := strconv.Atoi(query.Get("max-keys")); err == nil && value > 0 {
+		maxKeys = value
+	}
+
+	var keys []string
+	for path := range f.objects {
+		key := strings.TrimPrefix(path, bucket+"/")
+		if strings.HasPrefix(path, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	truncated := len(keys) > maxKeys
+	if truncated {
+		keys = keys[:maxKeys]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<ListBucketResult><Name>%s</Name><KeyCount>%d</KeyCount><IsTruncated>%t</IsTruncated>", bucket, len(keys), truncated)
+	if truncated {
+		fmt.Fprintf(&b, "<NextContinuationToken>%s</NextContinuationToken>", keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		content := f.objects[bucket+"/"+key]
+		fmt.Fprintf(&b, "<Contents><Key>%s</Key><Size>%d</Size><ETag>%s</ETag><LastModified>%s</LastModified></Contents>", key, len(content), etagOf(content), f.modified().Format(time.RFC3339))
+	}
+	b.WriteString("</ListBucketResult>")
+	writeXML(w, b.String())
+}
+
+// listBuckets answers ListBuckets with every bucket that holds objects
+func (f *fakeS3) listBuckets(w http.ResponseWriter) {
+	names := map[string]bool{}
+	for path := range f.objects {
+		bucket, _, _ := strings.Cut(path, "/")
+		names[bucket] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var b strings.Builder
+	b.WriteString("<ListAllMyBucketsResult><Buckets>")
+	for _, name := range sorted {
+		fmt.Fprintf(&b, "<Bucket><Name>%s</Name><CreationDate>2024-01-02T03:04:05.000Z</CreationDate></Bucket>", name)
+	}
+	b.WriteString("</Buckets></ListAllMyBucketsResult>")
+	writeXML(w, b.String())
+}
+
+// listVersions answers ListObjectVersions with one version per object plus delete markers
+func (f *fakeS3) listVersions(w http.ResponseWriter, bucket string) {
+	if !f.versioned {
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("<ListVersionsResult><IsTruncated>false</IsTruncated>")
+	for path, content := range f.objects {
+		if key, ok := strings.CutPrefix(path, bucket+"/"); ok {
+			id := f.currentVersionID(path)
+			fmt.Fprintf(&b, "<Version><Key>%s</Key><VersionId>%s</VersionId><IsLatest>true</IsLatest><Size>%d</Size></Version>", key, id, len(content))
+		}
+	}
+	for path, versions := range f.history {
+		if key, ok := strings.CutPrefix(path, bucket+"/"); ok {
+			for i, version := range versions {
+				// Later history entries are newer
+				modified := time.Unix(int64(i), 0).UTC().Format(time.RFC3339)
+				fmt.Fprintf(&b, "<Version><Key>%s</Key><VersionId>%s</VersionId><IsLatest>false</IsLatest><Size>%d</Size><LastModified>%s</LastModified></Version>", key, version.id, len(version.content), modified)
+			}
+		}
+	}
+	for path := range f.markers {
+		if key, ok := strings.CutPrefix(path, bucket+"/"); ok {
+			fmt.Fprintf(&b, "<DeleteMarker><Key>%s</Key><VersionId>m1</VersionId><IsLatest>true</IsLatest></DeleteMarker>", key)
+		}
+	}
+	b.WriteString("</ListVersionsResult>")
+	writeXML(w, b.String())
+}
+
+// deleteObjects answers a DeleteObjects batch, failing keys listed in denyDelete
+func (f *fakeS3) deleteObjects(w http.ResponseWriter, bucket string, body []byte) {
+	var request struct {
+		Objects []struct {
+			Key       string `xml:"Key"`
+			VersionID string `xml:"VersionId"`
+		} `xml:"Object"`
+	}
+	if err := xml.Unmarshal(body, &request); err != nil {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("<DeleteResult>")
+	for _, object := range request.Objects {
+		path := bucket + "/" + object.Key
+		if object.VersionID != "" && f.markers[path] {
+			delete(f.markers, path)
+			continue
+		}
+		if object.VersionID != "" && object.VersionID != f.currentVersionID(path) {
+			f.deleteVersion(path, object.VersionID)
+			continue
+		}
+		if f.denyDelete[path] {
+			fmt.Fprintf(&b, "<Error><Key>%s</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>", object.Key)
+			continue
+		}
+		delete(f.objects, path)
+	}
+	b.WriteString("</DeleteResult>")
+	writeXML(w, b.String())
+}
+
+// tagging serves GetObjectTagging, PutObjectTagging and DeleteObjectTagging. Tags are
+// kept URL-encoded in the object's stored X-Amz-Tagging header.
+func (f *fakeS3) tagging(w http.ResponseWriter, r *http.Request, path string, body []byte) {
+	if _, ok := f.objects[path]; !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	if f.stored[path] == nil {
+		f.stored[path] = http.Header{}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tags, _ := url.ParseQuery(f.stored[path].Get("X-Amz-Tagging"))
+		var b strings.Builder
+		b.WriteString("<Tagging><TagSet>")
+		for key := range tags {
+			fmt.Fprintf(&b, "<Tag><Key>%s</Key><Value>%s</Value></Tag>", key, tags.Get(key))
+		}
+		b.WriteString("</TagSet></Tagging>")
+		writeXML(w, b.String())
+	case http.MethodPut:
+		var tagging struct {
+			Tags []struct {
+				Key   string `xml:"Key"`
+				Value string `xml:"Value"`
+			} `xml:"TagSet>Tag"`
+		}
+		if err := xml.Unmarshal(body, &tagging); err != nil {
+			writeS3Error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		tags := url.Values{}
+		for _, tag := range tagging.Tags {
+			tags.Set(tag.Key, tag.Value)
+		}
+		f.stored[path].Set("X-Amz-Tagging", tags.Encode())
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		f.stored[path].Del("X-Amz-Tagging")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// write stores an object. With versioning enabled the replaced content becomes a
+// noncurrent version and the new one gets a version ID, reported in the response.
+func (f *fakeS3) write(w http.ResponseWriter, path string, content []byte, stored http.Header) {
+	if f.versioned {
+		if _, ok := f.objects[path]; ok {
+			f.history[path] = append(f.history[path], fakeVersion{id: f.currentVersionID(path), content: f.objects[path], stored: f.stored[path]})
+		}
+		f.nextID++
+		f.versionIDs[path] = fmt.Sprintf("v%d", f.nextID)
+		delete(f.markers, path)
+		w.Header().Set("X-Amz-Version-Id", f.versionIDs[path])
+	}
+	f.objects[path] = content
+	f.stored[path] = stored
+}
+
+// currentVersionID returns the version ID of the current object; objects written
+// before versioning was enabled have the "null" version
+func (f *fakeS3) currentVersionID(path string) string {
+	if id := f.versionIDs[path]; id != "" {
+		return id
+	}
+	return "null"
+}
+
+// version returns the current object, or the version with that ID
+func (f *fakeS3) version(path, id string) ([]byte, http.Header, bool) {
+	if id == "" || id == f.currentVersionID(path) {
+		content, ok := f.objects[path]
+		return content, f.stored[path], ok
+	}
+	for _, version := range f.history[path] {
+		if version.id == id {
+			return version.content, version.stored, true
+		}
+	}
+	return nil, nil, false
+}
+
+// deleteVersion permanently removes one version; deleting the current version makes
+// the newest noncurrent one current again
+func (f *fakeS3) deleteVersion(path, id string) {
+	if _, ok := f.objects[path]; ok && id == f.currentVersionID(path) {
+		delete(f.objects, path)
+		delete(f.stored, path)
+		delete(f.versionIDs, path)
+		if versions := f.history[path]; len(versions) > 0 {
+			latest := versions[len(versions)-1]
+			f.history[path] = versions[:len(versions)-1]
+			f.objects[path], f.stored[path], f.versionIDs[path] = latest.content, latest.stored, latest.id
+		}
+		return
+	}
+	versions := f.history[path]
+	for i, version := range versions {
+		if version.id == id {
+			f.history[path] = append(versions[:i:i], versions[i+1:]...)
+			return
+		}
+	}
+}
+
+// copySource returns the content named by an x-amz-copy-source header
+func (f *fakeS3) copySource(source string) ([]byte, bool) {
+	path, id := copySourceVersion(source)
+	content, _, ok := f.version(path, id)
+	return content, ok
+}
+
+// sourceHeaders returns the stored headers of the object named by an x-amz-copy-source header
+func (f *fakeS3) sourceHeaders(source string) (http.Header, bool) {
+	path, id := copySourceVersion(source)
+	_, stored, ok := f.version(path, id)
+	return stored, ok
+}
+
+// copySourceVersion splits an x-amz-copy-source header into a "bucket/key" path and version ID
+func copySourceVersion(source string) (string, string) {
+	source, query, _ := strings.Cut(source, "?")
+	values, _ := url.ParseQuery(query)
+	return copySourcePath(source), values.Get("versionId")
+}
+
+// copySourcePath turns an x-amz-copy-source header into a "bucket/key" path
+func copySourcePath(source string) string {
+	if unescaped, err := url.PathUnescape(source); err == nil {
+		source = unescaped
+	}
+	return strings.TrimPrefix(source, "/")
+}
+
+// storedHeaders picks the headers S3 stores with an object from a write request
+func storedHeaders(header http.Header) http.Header {
+	stored := http.Header{}
+	for name, values := range header {
+		switch {
+		case name == "Content-Type", name == "Cache-Control", name == "Content-Disposition":
+			stored[name] = values
+		case name == "Content-Encoding":
+			if encoding := strings.Trim(strings.ReplaceAll(values[0], "aws-chunked", ""), ", "); encoding != "" {
+				stored[name] = []string{encoding}
+			}
+		case strings.HasPrefix(name, "X-Amz-Meta-"), name == "X-Amz-Tagging":
+			stored[name] = values
+		}
+	}
+	return stored
+}
+
+// storedHeader returns a header stored with an object
+func (f *fakeS3) storedHeader(bucket, key, name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stored[bucket+"/"+key].Get(name)
+}
+
+// modified is the modification time reported for every object
+func (f *fakeS3) modified() time.Time {
+	if f.lastModified.IsZero() {
+		return time.Unix(0, 0).UTC()
+	}
+	return f.lastModified.UTC()
+}
+
+// object returns stored content
+func (f *fakeS3) object(bucket, key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	content, ok := f.objects[bucket+"/"+key]
+	return content, ok
+}
+
+// count returns how many requests started with prefix (e.g. "PUT /bucket/key?partNumber")
+func (f *fakeS3) count(prefix string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, request := range f.requests {
+		if strings.HasPrefix(request, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// header returns a header of the last request
+func (f *fakeS3) header(name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.headers.Get(name)
+}
+
+// decodeAWSChunked strips aws-chunked framing the SDK uses for trailing checksums
+func decodeAWSChunked(r *http.Request, body []byte) []byte {
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") {
+		return body
+	}
+	var out []byte
+	rest := string(body)
+	for {
+		line, after, ok := strings.Cut(rest, "\r\n")
+		if !ok {
+			return out
+		}
+		sizeText, _, _ := strings.Cut(line, ";")
+		size, err := strconv.ParseInt(sizeText, 16, 64)
+		if err != nil || size == 0 {
+			return out
+		}
+		out = append(out, after[:size]...)
+		rest = strings.TrimPrefix(after[size:], "\r\n")
+	}
+}
+
+func etagOf(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeXML(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, xml.Header+body)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "%s<Error><Code>%s</Code><Message>%s</Message></Error>", xml.Header, code, code)
+}
@@ -178,10 +178,14 @@ func TestIntegration_ListObjects(t *testing.T) {
 		t.Errorf("Expected CompletedActionStatus, got %s", result.ActionStatus)
 	}
 
-	// Access result from properties
-	resultData, ok := result.Properties["result"].([]interface{})
-	if !ok {
-		resultData = []interface{}{}
+	// Access listed entries from the Dataset result
+	resultData := []interface{}{}
+	if result.Result != nil {
+		if dataset, ok := result.Result.Value.(map[string]interface{}); ok {
+			if parts, ok := dataset["hasPart"].([]interface{}); ok {
+				resultData = parts
+			}
+		}
 	}
 
 	t.Logf("✓ List test passed - found %d objects with prefix 'test/'", len(resultData))
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ============================================================================
+// Async Job Store
+// ============================================================================
+
+const (
+	defaultJobRetention    = 24 * time.Hour
+	jobPruneInterval       = time.Hour // how often finished jobs past retention are deleted
+	defaultRetryBackoff    = time.Second
+	defaultMaxRetryBackoff = 5 * time.Minute
+	maxRetryAttempts       = 20
+)
+
+var (
+	jobsBucket      = []byte("jobs")      // tracked identifier -> asyncJob
+	actionIDsBucket = []byte("actionIds") // action identifier -> tracked identifier
+)
+
+// retryPolicy says how often a failed async action runs and how long it waits
+// between attempts
+type retryPolicy struct {
+	MaxAttempts int           `json:"maxAttempts"`
+	Backoff     time.Duration `json:"backoff"`
+	MaxBackoff  time.Duration `json:"maxBackoff"`
+}
+
+// parseRetryPolicy reads an action's retry option: a number of attempts, or
+// {"maxAttempts": 3, "backoff": "2s", "maxBackoff": "1m"}. Unset means one attempt.
+func parseRetryPolicy(value interface{}) (retryPolicy, error) {
+	policy := retryPolicy{MaxAttempts: 1, Backoff: defaultRetryBackoff, MaxBackoff: defaultMaxRetryBackoff}
+	if value == nil {
+		return policy, nil
+	}
+
+	attempts := value
+	if node, ok := value.(map[string]interface{}); ok {
+		attempts = node["maxAttempts"]
+		for name, field := range map[string]*time.Duration{"backoff": &policy.Backoff, "maxBackoff": &policy.MaxBackoff} {
+			if node[name] == nil {
+				continue
+			}
+			d, err := parseTimeout(node[name])
+			if err != nil || d <= 0 {
+				return policy, fmt.Errorf("invalid retry %s %v", name, node[name])
+			}
+			*field = d
+		}
+	}
+	if attempts != nil {
+		n, err := asInt(attempts)
+		if err != nil || n < 1 || n > maxRetryAttempts {
+			return policy, fmt.Errorf("retry maxAttempts must be between 1 and %d", maxRetryAttempts)
+		}
+		policy.MaxAttempts = int(n)
+	}
+	if policy.MaxBackoff < policy.Backoff {
+		policy.MaxBackoff = policy.Backoff
+	}
+	return policy, nil
+}
+
+// delay is the wait after the given failed attempt: the backoff, doubled for every
+// further attempt, up to MaxBackoff
+func (p retryPolicy) delay(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
+
+// asyncJob is an accepted async action as the job store keeps it
+type asyncJob struct {
+	Action      trackedAction   `json:"action"`
+	ActionID    string          `json:"actionId,omitempty"` // the action's own identifier, for deduplication
+	Body        json.RawMessage `json:"body,omitempty"`     // unset when the action held inline credentials
+	Retry       retryPolicy     `json:"retry"`
+	NextAttempt time.Time       `json:"nextAttempt,omitempty"`
+	Updated     time.Time       `json:"updated"`
+
+	// Checkpoint is where a long action, such as a migration, continues after a
+	// failed attempt or a restart (see saveCheckpoint)
+	Checkpoint json.RawMessage `json:"checkpoint,omitempty"`
+}
+
+// jobStore persists async actions in a bbolt database, so that accepted actions
+// survive a restart
+type jobStore struct {
+	db  *bolt.DB
+	now func() time.Time
+}
+
+// defaultJobStorePath is S3_JOB_STORE, or jobs.db in the service's state directory
+func defaultJobStorePath() (string, error) {
+	if path := os.Getenv("S3_JOB_STORE"); path != "" {
+		return path, nil
+	}
+	dir, err := serviceStateDir()
+	if err != nil {
+		return "", fmt.Errorf("S3_JOB_STORE is not set: %w", err)
+	}
+	return filepath.Join(dir, "jobs.db"), nil
+}
+
+// serviceStateDir is where the service keeps state that must survive a restart:
+// $XDG_STATE_HOME/s3service, by default ~/.local/state/s3service. Unlike the temp
+// dir it is not wiped on reboot or by a private /tmp.
+func serviceStateDir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("there is no home directory for the default state directory: %w", err)
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "s3service"), nil
+}
+
+// jobRetention reads S3_JOB_RETENTION: how long finished jobs are kept (default 24h)
+func jobRetention() time.Duration {
+	if value := os.Getenv("S3_JOB_RETENTION"); value != "" {
+		if parsed, err := parseTimeout(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultJobRetention
+}
+
+func openJobStore(path string) (*jobStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create job store directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{jobsBucket, actionIDsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize job store: %w", err)
+	}
+	return &jobStore{db: db, now: time.Now}, nil
+}
+
+// Close releases the database
+func (s *jobStore) Close() error {
+	return s.db.Close()
+}
+
+// Create stores a new job. When a job with the same action identifier is already
+// stored, nothing is written and that job is returned instead.
+func (s *jobStore) Create(job *asyncJob) (*asyncJob, error) {
+	var existing *asyncJob
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if job.ActionID != "" {
+			if id := tx.Bucket(actionIDsBucket).Get([]byte(job.ActionID)); id != nil {
+				if data := tx.Bucket(jobsBucket).Get(id); data != nil {
+					existing = &asyncJob{}
+					return json.Unmarshal(data, existing)
+				}
+			}
+			if err := tx.Bucket(actionIDsBucket).Put([]byte(job.ActionID), []byte(job.Action.Identifier)); err != nil {
+				return err
+			}
+		}
+		return s.put(tx, job)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store job: %w", err)
+	}
+	return existing, nil
+}
+
+// Save updates a stored job
+func (s *jobStore) Save(job *asyncJob) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.put(tx, job)
+	})
+}
+
+func (s *jobStore) put(tx *bolt.Tx, job *asyncJob) error {
+	job.Updated = s.now().UTC()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(jobsBucket).Put([]byte(job.Action.Identifier), data)
+}
+
+// Delete removes a job and its action identifier
+func (s *jobStore) Delete(job *asyncJob) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return s.delete(tx, job)
+	})
+}
+
+func (s *jobStore) delete(tx *bolt.Tx, job *asyncJob) error {
+	if job.ActionID != "" {
+		ids := tx.Bucket(actionIDsBucket)
+		if id := ids.Get([]byte(job.ActionID)); string(id) == job.Action.Identifier {
+			if err := ids.Delete([]byte(job.ActionID)); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Bucket(jobsBucket).Delete([]byte(job.Action.Identifier))
+}
+
+// List returns the stored jobs, oldest first
+func (s *jobStore) List() ([]*asyncJob, error) {
+	var jobs []*asyncJob
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(_, data []byte) error {
+			job := &asyncJob{}
+			if err := json.Unmarshal(data, job); err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jobs: %w", err)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Action.StartTime.Before(jobs[j].Action.StartTime)
+	})
+	return jobs, nil
+}
+
+// Prune deletes finished jobs last updated before cutoff, which frees their action
+// identifiers for reuse
+func (s *jobStore) Prune(cutoff time.Time) (int, error) {
+	jobs, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		for _, job := range jobs {
+			if job.Action.ActionStatus == actionStatusActive || !job.Updated.Before(cutoff) {
+				continue
+			}
+			if err := s.delete(tx, job); err != nil {
+				return err
+			}
+			pruned++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune jobs: %w", err)
+	}
+	return pruned, nil
+}
+
+// runJobPruner deletes finished jobs older than retention every interval until ctx
+// ends. report is called after every run.
+func runJobPruner(ctx context.Context, store *jobStore, interval, retention time.Duration, report func(pruned int, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		report(store.Prune(store.now().Add(-retention)))
+	}
+}
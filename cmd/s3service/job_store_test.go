@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newTestJobStore(t *testing.T) *jobStore {
+	t.Helper()
+	store, err := openJobStore(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatalf("openJobStore failed: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestParseRetryPolicy(t *testing.T) {
+	policy, err := parseRetryPolicy(nil)
+	if err != nil || policy.MaxAttempts != 1 {
+		t.Errorf("expected a single attempt by default, got %+v (%v)", policy, err)
+	}
+
+	policy, err = parseRetryPolicy(map[string]interface{}{"maxAttempts": 5.0, "backoff": "2s", "maxBackoff": 5.0})
+	if err != nil {
+		t.Fatalf("parseRetryPolicy failed: %v", err)
+	}
+	delays := []time.Duration{policy.delay(1), policy.delay(2), policy.delay(3)}
+	if policy.MaxAttempts != 5 || delays[0] != 2*time.Second || delays[1] != 4*time.Second || delays[2] != 5*time.Second {
+		t.Errorf("expected doubling backoff capped at 5s, got %+v %v", policy, delays)
+	}
+
+	for _, value := range []interface{}{0.0, 100.0, map[string]interface{}{"backoff": "soon"}} {
+		if _, err := parseRetryPolicy(value); err == nil {
+			t.Errorf("expected %v to be rejected", value)
+		}
+	}
+}
+
+func TestServiceStateDir(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+	t.Setenv("S3_JOB_STORE", "")
+	t.Setenv("S3_MULTIPART_STATE_DIR", "")
+
+	if path, err := defaultJobStorePath(); err != nil || path != filepath.Join(state, "s3service", "jobs.db") {
+		t.Errorf("unexpected job store path %q (%v)", path, err)
+	}
+	if dir := defaultMultipartStateDir(); dir != filepath.Join(state, "s3service", "multipart") {
+		t.Errorf("multipart progress must live in the state directory, got %q", dir)
+	}
+}
+
+func TestJobStore_DeduplicatesAndPrunes(t *testing.T) {
+	store := newTestJobStore(t)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	first := &asyncJob{Action: trackedAction{Identifier: "job-1", ActionStatus: actionStatusActive}, ActionID: "upload-1"}
+	if existing, err := store.Create(first); err != nil || existing != nil {
+		t.Fatalf("expected the job to be created, got %v (%v)", existing, err)
+	}
+	again := &asyncJob{Action: trackedAction{Identifier: "job-2", ActionStatus: actionStatusActive}, ActionID: "upload-1"}
+	existing, err := store.Create(again)
+	if err != nil || existing == nil || existing.Action.Identifier != "job-1" {
+		t.Fatalf("expected the first job for a repeated identifier, got %v (%v)", existing, err)
+	}
+
+	// Active jobs are kept however old they are; finished ones expire
+	if pruned, _ := store.Prune(now.Add(time.Hour)); pruned != 0 {
+		t.Errorf("expected active jobs to be kept, pruned %d", pruned)
+	}
+	first.Action.ActionStatus = actionStatusCompleted
+	if err := store.Save(first); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if pruned, _ := store.Prune(now.Add(time.Hour)); pruned != 1 {
+		t.Errorf("expected the finished job to be pruned, pruned %d", pruned)
+	}
+	if existing, _ := store.Create(again); existing != nil {
+		t.Errorf("expected the identifier to be free after pruning, got %v", existing)
+	}
+	if jobs, _ := store.List(); len(jobs) != 1 || jobs[0].Action.Identifier != "job-2" {
+		t.Errorf("unexpected jobs %v", jobs)
+	}
+}
+
+func TestRunJobPruner(t *testing.T) {
+	store := newTestJobStore(t)
+	job := &asyncJob{Action: trackedAction{Identifier: "job-1", ActionStatus: actionStatusCompleted}, ActionID: "upload-1"}
+	if _, err := store.Create(job); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reports := make(chan int, 10)
+	go runJobPruner(ctx, store, 5*time.Millisecond, time.Nanosecond, func(pruned int, err error) {
+		if err == nil {
+			reports <- pruned
+		}
+	})
+	select {
+	case pruned := <-reports:
+		if pruned != 1 {
+			t.Errorf("expected the finished job to be pruned, pruned %d", pruned)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the pruner never ran")
+	}
+}
+
+func TestActionRunner_RetriesStoredActions(t *testing.T) {
+	tracker := newTestStateTracker()
+	runner := newActionRunner(tracker)
+	store := newTestJobStore(t)
+	runner.UseStore(store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { cancel(); runner.Wait() }()
+	runner.Start(ctx, 1, 10)
+
+	var calls atomic.Int32
+	flaky := func(c echo.Context) error {
+		if calls.Add(1) < 3 {
+			return c.JSON(http.StatusBadGateway, map[string]interface{}{"actionStatus": actionStatusFailed, "error": "SlowDown"})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{"actionStatus": actionStatusCompleted, "result": "uploaded"})
+	}
+	body := []byte(`{"@type": "CreateAction", "identifier": "upload-1"}`)
+	policy := retryPolicy{MaxAttempts: 3, Backoff: 5 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	tracked, duplicate, err := runner.Accept(trackedAction{Type: "CreateAction"}, body, flaky, policy)
+	if err != nil || duplicate {
+		t.Fatalf("Accept failed: %v (duplicate %v)", err, duplicate)
+	}
+	action := waitForStatus(t, tracker, tracked.Identifier)
+	if action.ActionStatus != actionStatusCompleted || action.Attempts != 3 || action.Result != "uploaded" {
+		t.Errorf("expected the third attempt to complete, got %+v", action)
+	}
+
+	again, duplicate, err := runner.Accept(trackedAction{Type: "CreateAction"}, body, flaky, policy)
+	if err != nil || !duplicate || again.Identifier != tracked.Identifier {
+		t.Errorf("expected the repeated identifier to return the first action, got %+v (%v)", again, err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected a duplicate not to run, ran %d times", calls.Load())
+	}
+	if jobs, _ := store.List(); len(jobs) != 1 || jobs[0].Action.ActionStatus != actionStatusCompleted {
+		t.Errorf("expected the stored job to be completed, got %+v", jobs)
+	}
+}
+
+func TestActionRunner_CancelsRunningActions(t *testing.T) {
+	tracker := newTestStateTracker()
+	runner := newActionRunner(tracker)
+	store := newTestJobStore(t)
+	runner.UseStore(store)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() { cancel(); runner.Wait() }()
+	runner.Start(ctx, 1, 10)
+
+	var calls atomic.Int32
+	blocking := func(c echo.Context) error {
+		calls.Add(1)
+		<-c.Request().Context().Done()
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"actionStatus": actionStatusFailed, "error": "stopped"})
+	}
+	policy := retryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	tracked, _, err := runner.Accept(trackedAction{Type: "TransferAction"}, []byte(`{"@type": "TransferAction"}`), blocking, policy)
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	if runner.Cancel("unknown") {
+		t.Error("expected only running actions to be cancelled")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !runner.Cancel(tracked.Identifier) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	action := waitForStatus(t, tracker, tracked.Identifier)
+	if action.ActionStatus != actionStatusFailed || action.Error != errActionCancelled.Error() || action.Attempts != 1 {
+		t.Errorf("expected the action to fail as cancelled after one attempt, got %+v", action)
+	}
+	if jobs, _ := store.List(); len(jobs) != 1 || jobs[0].Action.ActionStatus != actionStatusFailed {
+		t.Errorf("expected the stored job to be failed, not retried or resumed, got %+v", jobs)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected a cancelled action not to be retried, ran %d times", calls.Load())
+	}
+}
+
+func TestActionRunner_ResumesStoredActionsAfterRestart(t *testing.T) {
+	store := newTestJobStore(t)
+	once := retryPolicy{MaxAttempts: 1, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	defaults, _ := parseRetryPolicy(nil)
+
+	// The first run stops while one action runs and two wait in the queue; the
+	// running one keeps the default single attempt, which shutdown doesn't use up
+	tracker := newTestStateTracker()
+	runner := newActionRunner(tracker)
+	runner.UseStore(store)
+	ctx, cancel := context.WithCancel(context.Background())
+	runner.Start(ctx, 1, 10)
+
+	blocking := func(c echo.Context) error {
+		<-c.Request().Context().Done()
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"actionStatus": actionStatusFailed, "error": "cancelled"})
+	}
+	accept := func(body string, policy retryPolicy) trackedAction {
+		tracked, _, err := runner.Accept(trackedAction{Type: "DownloadAction"}, []byte(body), blocking, policy)
+		if err != nil {
+			t.Fatalf("Accept failed: %v", err)
+		}
+		return tracked
+	}
+	running := accept(`{"@type": "DownloadAction", "identifier": "running"}`, defaults)
+	deadline := time.Now().Add(5 * time.Second)
+	for action, _ := tracker.Get(running.Identifier); action.Attempts == 0 && time.Now().Before(deadline); action, _ = tracker.Get(running.Identifier) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	lastAttempt := accept(`{"@type": "DownloadAction", "identifier": "last"}`, once)
+	queued := accept(`{"@type": "DownloadAction", "identifier": "queued"}`, once)
+	credentials := accept(`{"@type": "DownloadAction", "target": {"@type": "DataCatalog", "accessKey": "AKIA", "secretKey": "s3cr3t"}}`, once)
+	cancel()
+	runner.Wait()
+
+	// Mark one job as cut off during its last attempt
+	jobs, _ := store.List()
+	for _, job := range jobs {
+		if job.Action.Identifier == lastAttempt.Identifier {
+			job.Action.Attempts = 1
+			_ = store.Save(job)
+		}
+		if job.Action.Identifier == credentials.Identifier && job.Body != nil {
+			t.Error("expected inline credentials not to be stored")
+		}
+	}
+
+	// The next start resumes what its retry policy allows and fails the rest
+	tracker = newTestStateTracker()
+	runner = newActionRunner(tracker)
+	runner.UseStore(store)
+	runner.build = func(body []byte) (actionFunc, error) {
+		return func(c echo.Context) error {
+			return c.JSON(http.StatusOK, map[string]interface{}{"actionStatus": actionStatusCompleted, "result": bodyIdentifier(body)})
+		}, nil
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer func() { cancel(); runner.Wait() }()
+	runner.Start(ctx, 1, 10)
+
+	resumed, failed, err := runner.Resume(time.Hour)
+	if err != nil || resumed != 2 || failed != 2 {
+		t.Fatalf("expected 2 resumed and 2 failed actions, got %d and %d (%v)", resumed, failed, err)
+	}
+	for _, want := range []struct {
+		id, status string
+		attempts   int
+	}{
+		{running.Identifier, actionStatusCompleted, 1},
+		{queued.Identifier, actionStatusCompleted, 1},
+		{lastAttempt.Identifier, actionStatusFailed, 1},
+		{credentials.Identifier, actionStatusFailed, 0},
+	} {
+		action := waitForStatus(t, tracker, want.id)
+		if action.ActionStatus != want.status || action.Attempts != want.attempts {
+			t.Errorf("expected %s to be %s after %d attempts, got %+v", want.id, want.status, want.attempts, action)
+		}
+	}
+}
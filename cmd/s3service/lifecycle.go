@@ -0,0 +1,774 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/labstack/echo/v4"
+)
+
+// ============================================================================
+// Bucket Lifecycle Rules
+// ============================================================================
+
+const (
+	maxLifecycleRules         = 1000 // S3 limit on rules per bucket
+	maxLifecycleRuleID        = 255  // S3 limit on rule ID length
+	maxNewerNoncurrent        = 100  // S3 limit on retained noncurrent versions
+	defaultLifecycleDryRunCap = 1000 // affected entries a dry run lists
+)
+
+// lifecycleRule is the JSON form of an S3 lifecycle rule. A rule applies to the
+// objects under Prefix that carry every tag in Tags and whose size in bytes is
+// above ObjectSizeGreaterThan and below ObjectSizeLessThan, where those are set.
+type lifecycleRule struct {
+	ID                             string                  `json:"id"`
+	Status                         string                  `json:"status,omitempty"` // Enabled (default) or Disabled
+	Prefix                         string                  `json:"prefix,omitempty"`
+	Tags                           map[string]string       `json:"tags,omitempty"`
+	ObjectSizeGreaterThan          int64                   `json:"objectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan             int64                   `json:"objectSizeLessThan,omitempty"`
+	Expiration                     *lifecycleExpiration    `json:"expiration,omitempty"`
+	NoncurrentVersionExpiration    *noncurrentExpiration   `json:"noncurrentVersionExpiration,omitempty"`
+	AbortIncompleteMultipartUpload *abortIncompleteUploads `json:"abortIncompleteMultipartUpload,omitempty"`
+	Transitions                    []lifecycleTransition   `json:"transitions,omitempty"`
+}
+
+// lifecycleExpiration expires current objects after Days or on Date, or removes
+// delete markers that no longer hide any version
+type lifecycleExpiration struct {
+	Days                      int32  `json:"days,omitempty"`
+	Date                      string `json:"date,omitempty"` // YYYY-MM-DD, midnight UTC
+	ExpiredObjectDeleteMarker bool   `json:"expiredObjectDeleteMarker,omitempty"`
+}
+
+// noncurrentExpiration deletes versions NoncurrentDays after they were superseded,
+// keeping the NewerNoncurrentVersions most recent ones
+type noncurrentExpiration struct {
+	NoncurrentDays          int32 `json:"noncurrentDays"`
+	NewerNoncurrentVersions int32 `json:"newerNoncurrentVersions,omitempty"`
+}
+
+// abortIncompleteUploads aborts multipart uploads still open after DaysAfterInitiation
+type abortIncompleteUploads struct {
+	DaysAfterInitiation int32 `json:"daysAfterInitiation"`
+}
+
+// lifecycleTransition moves current objects to another storage class after Days or on
+// Date. Days is a pointer because 0 (transition right away) is a valid value.
+type lifecycleTransition struct {
+	Days         *int32 `json:"days,omitempty"`
+	Date         string `json:"date,omitempty"`
+	StorageClass string `json:"storageClass"`
+}
+
+// days returns the transition's day count, 0 for a transition on a date
+func (t lifecycleTransition) days() int32 {
+	return aws.ToInt32(t.Days)
+}
+
+// lifecycleRulesOption reads and validates the "lifecycleRules" option, a list of rules
+func lifecycleRulesOption(action *semantic.SemanticAction) ([]lifecycleRule, error) {
+	value, ok := actionOption(action, "lifecycleRules")
+	if !ok {
+		return nil, fmt.Errorf("lifecycleRules is required")
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return parseLifecycleRules(data)
+}
+
+// parseLifecycleRules decodes a JSON rule list, rejecting unknown fields so that a
+// misspelt rule action is not silently dropped, and validates it
+func parseLifecycleRules(data []byte) ([]lifecycleRule, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var rules []lifecycleRule
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid lifecycle rules: %w", err)
+	}
+	if err := validateLifecycleRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// validateLifecycleRules checks rules against the S3 constraints and fills in defaults
+func validateLifecycleRules(rules []lifecycleRule) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("at least one rule is required; delete the configuration to remove all rules")
+	}
+	if len(rules) > maxLifecycleRules {
+		return fmt.Errorf("%d rules exceed the limit of %d", len(rules), maxLifecycleRules)
+	}
+
+	ids := map[string]bool{}
+	for i := range rules {
+		rule := &rules[i]
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.ID, err)
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("rule %q: duplicate id", rule.ID)
+		}
+		ids[rule.ID] = true
+	}
+	return nil
+}
+
+// validate checks one rule and normalizes its status
+func (r *lifecycleRule) validate() error {
+	switch {
+	case r.ID == "":
+		return fmt.Errorf("id is required")
+	case len(r.ID) > maxLifecycleRuleID:
+		return fmt.Errorf("id exceeds %d characters", maxLifecycleRuleID)
+	}
+
+	switch strings.ToLower(r.Status) {
+	case "", "enabled":
+		r.Status = string(types.ExpirationStatusEnabled)
+	case "disabled":
+		r.Status = string(types.ExpirationStatusDisabled)
+	default:
+		return fmt.Errorf("status must be Enabled or Disabled")
+	}
+
+	if r.Expiration == nil && r.NoncurrentVersionExpiration == nil && r.AbortIncompleteMultipartUpload == nil && len(r.Transitions) == 0 {
+		return fmt.Errorf("a rule needs expiration, noncurrentVersionExpiration, abortIncompleteMultipartUpload or transitions")
+	}
+	if len(r.Tags) > 0 {
+		if err := validateTags(r.Tags); err != nil {
+			return err
+		}
+	}
+	if r.ObjectSizeGreaterThan < 0 || r.ObjectSizeLessThan < 0 {
+		return fmt.Errorf("object size filters must not be negative")
+	}
+	if r.ObjectSizeLessThan > 0 && r.ObjectSizeLessThan <= r.ObjectSizeGreaterThan {
+		return fmt.Errorf("objectSizeLessThan must be greater than objectSizeGreaterThan")
+	}
+
+	if e := r.Expiration; e != nil {
+		set := 0
+		for _, ok := range []bool{e.Days != 0, e.Date != "", e.ExpiredObjectDeleteMarker} {
+			if ok {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("expiration needs exactly one of days, date or expiredObjectDeleteMarker")
+		}
+		if e.Days < 0 {
+			return fmt.Errorf("expiration days must be positive")
+		}
+		if e.Date != "" {
+			if _, err := lifecycleDate(e.Date); err != nil {
+				return err
+			}
+		}
+		if e.ExpiredObjectDeleteMarker && len(r.Tags) > 0 {
+			return fmt.Errorf("expiredObjectDeleteMarker cannot be combined with a tag filter")
+		}
+	}
+
+	if n := r.NoncurrentVersionExpiration; n != nil {
+		if n.NoncurrentDays < 1 {
+			return fmt.Errorf("noncurrentDays must be positive")
+		}
+		if n.NewerNoncurrentVersions < 0 || n.NewerNoncurrentVersions > maxNewerNoncurrent {
+			return fmt.Errorf("newerNoncurrentVersions must be between 0 and %d", maxNewerNoncurrent)
+		}
+	}
+
+	if a := r.AbortIncompleteMultipartUpload; a != nil {
+		if a.DaysAfterInitiation < 1 {
+			return fmt.Errorf("daysAfterInitiation must be positive")
+		}
+		if len(r.Tags) > 0 || r.hasSizeFilter() {
+			return fmt.Errorf("abortIncompleteMultipartUpload cannot be combined with a tag or object size filter")
+		}
+	}
+
+	classes := map[string]bool{}
+	for _, class := range types.TransitionStorageClass("").Values() {
+		classes[string(class)] = true
+	}
+	for _, t := range r.Transitions {
+		if !classes[t.StorageClass] {
+			return fmt.Errorf("unknown storage class %q", t.StorageClass)
+		}
+		if (t.Days != nil) == (t.Date != "") || t.days() < 0 {
+			return fmt.Errorf("transition to %s needs either days (0 or more) or a date", t.StorageClass)
+		}
+		if t.Date != "" {
+			if _, err := lifecycleDate(t.Date); err != nil {
+				return err
+			}
+		}
+		if r.Expiration != nil && r.Expiration.Days > 0 && t.Days != nil && t.days() >= r.Expiration.Days {
+			return fmt.Errorf("transition to %s after %d days is not before expiration after %d days", t.StorageClass, t.days(), r.Expiration.Days)
+		}
+	}
+	return nil
+}
+
+func (r lifecycleRule) hasSizeFilter() bool {
+	return r.ObjectSizeGreaterThan > 0 || r.ObjectSizeLessThan > 0
+}
+
+// matchesSize reports whether an object of size bytes passes the rule's size filter
+func (r lifecycleRule) matchesSize(size int64) bool {
+	return (r.ObjectSizeGreaterThan == 0 || size > r.ObjectSizeGreaterThan) &&
+		(r.ObjectSizeLessThan == 0 || size < r.ObjectSizeLessThan)
+}
+
+// lifecycleDate parses a rule date. S3 only accepts midnight UTC.
+func lifecycleDate(value string) (time.Time, error) {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		date, err = time.Parse(time.RFC3339, value)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %q must be YYYY-MM-DD", value)
+	}
+	date = date.UTC()
+	if !date.Equal(date.Truncate(24 * time.Hour)) {
+		return time.Time{}, fmt.Errorf("date %q must be midnight UTC", value)
+	}
+	return date, nil
+}
+
+// toS3 converts a validated rule to the S3 representation
+func (r lifecycleRule) toS3() types.LifecycleRule {
+	rule := types.LifecycleRule{
+		ID:     aws.String(r.ID),
+		Status: types.ExpirationStatus(r.Status),
+		Filter: &types.LifecycleRuleFilter{},
+	}
+
+	// A single predicate is set directly; several (a prefix, tags, size bounds) need And
+	predicates := len(r.Tags)
+	for _, set := range []bool{r.Prefix != "", r.ObjectSizeGreaterThan > 0, r.ObjectSizeLessThan > 0} {
+		if set {
+			predicates++
+		}
+	}
+	switch {
+	case predicates > 1:
+		rule.Filter.And = &types.LifecycleRuleAndOperator{
+			Prefix: optionalString(r.Prefix),
+			Tags:   tagSet(r.Tags),
+		}
+		if r.ObjectSizeGreaterThan > 0 {
+			rule.Filter.And.ObjectSizeGreaterThan = aws.Int64(r.ObjectSizeGreaterThan)
+		}
+		if r.ObjectSizeLessThan > 0 {
+			rule.Filter.And.ObjectSizeLessThan = aws.Int64(r.ObjectSizeLessThan)
+		}
+	case len(r.Tags) == 1:
+		tag := tagSet(r.Tags)[0]
+		rule.Filter.Tag = &tag
+	case r.ObjectSizeGreaterThan > 0:
+		rule.Filter.ObjectSizeGreaterThan = aws.Int64(r.ObjectSizeGreaterThan)
+	case r.ObjectSizeLessThan > 0:
+		rule.Filter.ObjectSizeLessThan = aws.Int64(r.ObjectSizeLessThan)
+	default:
+		rule.Filter.Prefix = aws.String(r.Prefix)
+	}
+
+	if e := r.Expiration; e != nil {
+		rule.Expiration = &types.LifecycleExpiration{}
+		switch {
+		case e.Days > 0:
+			rule.Expiration.Days = aws.Int32(e.Days)
+		case e.Date != "":
+			date, _ := lifecycleDate(e.Date)
+			rule.Expiration.Date = aws.Time(date)
+		default:
+			rule.Expiration.ExpiredObjectDeleteMarker = aws.Bool(true)
+		}
+	}
+	if n := r.NoncurrentVersionExpiration; n != nil {
+		rule.NoncurrentVersionExpiration = &types.NoncurrentVersionExpiration{
+			NoncurrentDays: aws.Int32(n.NoncurrentDays),
+		}
+		if n.NewerNoncurrentVersions > 0 {
+			rule.NoncurrentVersionExpiration.NewerNoncurrentVersions = aws.Int32(n.NewerNoncurrentVersions)
+		}
+	}
+	if a := r.AbortIncompleteMultipartUpload; a != nil {
+		rule.AbortIncompleteMultipartUpload = &types.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int32(a.DaysAfterInitiation),
+		}
+	}
+	for _, t := range r.Transitions {
+		transition := types.Transition{StorageClass: types.TransitionStorageClass(t.StorageClass)}
+		if t.Date != "" {
+			date, _ := lifecycleDate(t.Date)
+			transition.Date = aws.Time(date)
+		} else {
+			transition.Days = aws.Int32(t.days())
+		}
+		rule.Transitions = append(rule.Transitions, transition)
+	}
+	return rule
+}
+
+// lifecycleRuleFromS3 converts a stored S3 rule to the JSON form
+func lifecycleRuleFromS3(rule types.LifecycleRule) lifecycleRule {
+	r := lifecycleRule{
+		ID:     aws.ToString(rule.ID),
+		Status: string(rule.Status),
+		// Rules written before filters existed carry a top-level prefix
+		Prefix: aws.ToString(rule.Prefix),
+	}
+	if f := rule.Filter; f != nil {
+		if f.Prefix != nil {
+			r.Prefix = aws.ToString(f.Prefix)
+		}
+		var tags []types.Tag
+		if f.Tag != nil {
+			tags = append(tags, *f.Tag)
+		}
+		r.ObjectSizeGreaterThan = aws.ToInt64(f.ObjectSizeGreaterThan)
+		r.ObjectSizeLessThan = aws.ToInt64(f.ObjectSizeLessThan)
+		if f.And != nil {
+			r.Prefix = aws.ToString(f.And.Prefix)
+			tags = append(tags, f.And.Tags...)
+			r.ObjectSizeGreaterThan = aws.ToInt64(f.And.ObjectSizeGreaterThan)
+			r.ObjectSizeLessThan = aws.ToInt64(f.And.ObjectSizeLessThan)
+		}
+		for _, tag := range tags {
+			if r.Tags == nil {
+				r.Tags = map[string]string{}
+			}
+			r.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	if e := rule.Expiration; e != nil {
+		r.Expiration = &lifecycleExpiration{
+			Days:                      aws.ToInt32(e.Days),
+			ExpiredObjectDeleteMarker: aws.ToBool(e.ExpiredObjectDeleteMarker),
+		}
+		if e.Date != nil {
+			r.Expiration.Date = e.Date.UTC().Format("2006-01-02")
+		}
+	}
+	if n := rule.NoncurrentVersionExpiration; n != nil {
+		r.NoncurrentVersionExpiration = &noncurrentExpiration{
+			NoncurrentDays:          aws.ToInt32(n.NoncurrentDays),
+			NewerNoncurrentVersions: aws.ToInt32(n.NewerNoncurrentVersions),
+		}
+	}
+	if a := rule.AbortIncompleteMultipartUpload; a != nil {
+		r.AbortIncompleteMultipartUpload = &abortIncompleteUploads{DaysAfterInitiation: aws.ToInt32(a.DaysAfterInitiation)}
+	}
+	for _, t := range rule.Transitions {
+		transition := lifecycleTransition{StorageClass: string(t.StorageClass)}
+		if t.Date != nil {
+			transition.Date = t.Date.UTC().Format("2006-01-02")
+		} else {
+			transition.Days = aws.Int32(aws.ToInt32(t.Days))
+		}
+		r.Transitions = append(r.Transitions, transition)
+	}
+	return r
+}
+
+// getLifecycleRules reads a bucket's lifecycle rules; a bucket without a configuration has none
+func getLifecycleRules(ctx context.Context, client *s3.Client, bucketName string) ([]lifecycleRule, error) {
+	output, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucketName),
+	})
+	if s3StatusCode(err) == http.StatusNotFound {
+		return []lifecycleRule{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]lifecycleRule, 0, len(output.Rules))
+	for _, rule := range output.Rules {
+		rules = append(rules, lifecycleRuleFromS3(rule))
+	}
+	return rules, nil
+}
+
+// putLifecycleRules replaces a bucket's whole lifecycle configuration
+func putLifecycleRules(ctx context.Context, client *s3.Client, bucketName string, rules []lifecycleRule) error {
+	s3Rules := make([]types.LifecycleRule, 0, len(rules))
+	for _, rule := range rules {
+		s3Rules = append(s3Rules, rule.toS3())
+	}
+	_, err := client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucketName),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: s3Rules},
+	})
+	return err
+}
+
+// ----------------------------------------------------------------------------
+// Dry run
+// ----------------------------------------------------------------------------
+
+// lifecycleEffect is one thing a rule would do to an existing object, version or upload
+type lifecycleEffect struct {
+	Rule      string
+	Action    string // expire, transition, expireNoncurrent, removeDeleteMarker, abortUpload
+	Key       string
+	VersionID string
+	UploadID  string
+	Class     string // storage class of a transition
+}
+
+// due reports whether days have passed since t, or whether date has been reached
+func lifecycleDue(t time.Time, days int32, date string, now time.Time) bool {
+	if date != "" {
+		at, err := lifecycleDate(date)
+		return err == nil && !now.Before(at)
+	}
+	return days >= 0 && !t.IsZero() && !now.Before(t.Add(time.Duration(days)*24*time.Hour))
+}
+
+// currentEffect returns what the rule would do now to a current object last modified
+// at modified: expiry wins over transitions, and the latest due transition applies
+func (r lifecycleRule) currentEffect(key string, modified, now time.Time) (lifecycleEffect, bool) {
+	if e := r.Expiration; e != nil && !e.ExpiredObjectDeleteMarker && lifecycleDue(modified, e.Days, e.Date, now) {
+		return lifecycleEffect{Rule: r.ID, Action: "expire", Key: key}, true
+	}
+
+	var due *lifecycleTransition
+	for i, t := range r.Transitions {
+		if !lifecycleDue(modified, t.days(), t.Date, now) {
+			continue
+		}
+		if due == nil || t.days() > due.days() || t.Date > due.Date {
+			due = &r.Transitions[i]
+		}
+	}
+	if due != nil {
+		return lifecycleEffect{Rule: r.ID, Action: "transition", Key: key, Class: due.StorageClass}, true
+	}
+	return lifecycleEffect{}, false
+}
+
+// versionRecord is one listed object version or delete marker
+type versionRecord struct {
+	Key       string
+	VersionID string
+	Modified  time.Time
+	Size      int64
+	Latest    bool
+	Marker    bool
+}
+
+// versionEffects returns what the rule would do now to the versions of one key, given
+// newest first. A version becomes noncurrent when the next newer one is written.
+func (r lifecycleRule) versionEffects(versions []versionRecord, now time.Time) []lifecycleEffect {
+	var effects []lifecycleEffect
+	if len(versions) == 0 {
+		return effects
+	}
+
+	if e := r.Expiration; e != nil && e.ExpiredObjectDeleteMarker && len(versions) == 1 && versions[0].Latest && versions[0].Marker {
+		effects = append(effects, lifecycleEffect{Rule: r.ID, Action: "removeDeleteMarker", Key: versions[0].Key, VersionID: versions[0].VersionID})
+	}
+
+	n := r.NoncurrentVersionExpiration
+	if n == nil {
+		return effects
+	}
+	noncurrent := 0
+	for i := 1; i < len(versions); i++ {
+		if versions[i].Marker {
+			continue
+		}
+		noncurrent++
+		if noncurrent <= int(n.NewerNoncurrentVersions) {
+			continue
+		}
+		if r.matchesSize(versions[i].Size) && lifecycleDue(versions[i-1].Modified, n.NoncurrentDays, "", now) {
+			effects = append(effects, lifecycleEffect{Rule: r.ID, Action: "expireNoncurrent", Key: versions[i].Key, VersionID: versions[i].VersionID})
+		}
+	}
+	return effects
+}
+
+// lifecycleDryRun reports what the enabled rules would do to the bucket's existing
+// objects, versions and uploads at now. It collects up to limit effects and counts all.
+func lifecycleDryRun(ctx context.Context, client *s3.Client, bucketName string, rules []lifecycleRule, now time.Time, limit int) ([]lifecycleEffect, int, error) {
+	var effects []lifecycleEffect
+	total := 0
+	add := func(effect lifecycleEffect) {
+		total++
+		if len(effects) < limit {
+			effects = append(effects, effect)
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.Status != string(types.ExpirationStatusEnabled) {
+			continue
+		}
+
+		if (rule.Expiration != nil && !rule.Expiration.ExpiredObjectDeleteMarker) || len(rule.Transitions) > 0 {
+			paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+				Bucket: aws.String(bucketName),
+				Prefix: optionalString(rule.Prefix),
+			})
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					return nil, 0, fmt.Errorf("failed to list objects for rule %q: %w", rule.ID, err)
+				}
+				for _, obj := range page.Contents {
+					if !rule.matchesSize(aws.ToInt64(obj.Size)) {
+						continue
+					}
+					effect, ok := rule.currentEffect(aws.ToString(obj.Key), aws.ToTime(obj.LastModified), now)
+					if !ok {
+						continue
+					}
+					// Tags are only read for objects the rule would otherwise affect
+					if len(rule.Tags) > 0 {
+						tags, err := getObjectTags(ctx, client, bucketName, effect.Key, "")
+						if err != nil {
+							return nil, 0, fmt.Errorf("failed to read tags of %s: %w", effect.Key, err)
+						}
+						if !matchesTags(tags, rule.Tags) {
+							continue
+						}
+					}
+					add(effect)
+				}
+			}
+		}
+
+		if rule.NoncurrentVersionExpiration != nil || (rule.Expiration != nil && rule.Expiration.ExpiredObjectDeleteMarker) {
+			err := forEachKeyVersions(ctx, client, bucketName, rule.Prefix, func(versions []versionRecord) error {
+				found := rule.versionEffects(versions, now)
+				if len(found) > 0 && len(rule.Tags) > 0 {
+					// Noncurrent versions are matched by their own tags
+					kept := found[:0]
+					for _, effect := range found {
+						tags, err := getObjectTags(ctx, client, bucketName, effect.Key, effect.VersionID)
+						if err != nil {
+							return fmt.Errorf("failed to read tags of %s: %w", effect.Key, err)
+						}
+						if matchesTags(tags, rule.Tags) {
+							kept = append(kept, effect)
+						}
+					}
+					found = kept
+				}
+				for _, effect := range found {
+					add(effect)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to list versions for rule %q: %w", rule.ID, err)
+			}
+		}
+
+		if a := rule.AbortIncompleteMultipartUpload; a != nil {
+			paginator := s3.NewListMultipartUploadsPaginator(client, &s3.ListMultipartUploadsInput{
+				Bucket: aws.String(bucketName),
+				Prefix: optionalString(rule.Prefix),
+			})
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					return nil, 0, fmt.Errorf("failed to list uploads for rule %q: %w", rule.ID, err)
+				}
+				for _, upload := range page.Uploads {
+					if lifecycleDue(aws.ToTime(upload.Initiated), a.DaysAfterInitiation, "", now) {
+						add(lifecycleEffect{Rule: rule.ID, Action: "abortUpload", Key: aws.ToString(upload.Key), UploadID: aws.ToString(upload.UploadId)})
+					}
+				}
+			}
+		}
+	}
+	return effects, total, nil
+}
+
+// forEachKeyVersions lists the versions and delete markers under prefix and calls fn
+// with each key's versions, newest first
+func forEachKeyVersions(ctx context.Context, client *s3.Client, bucketName, prefix string, fn func([]versionRecord) error) error {
+	var current []versionRecord
+	flush := func() error {
+		if len(current) == 0 {
+			return nil
+		}
+		sort.SliceStable(current, func(i, j int) bool {
+			if current[i].Latest != current[j].Latest {
+				return current[i].Latest
+			}
+			return current[i].Modified.After(current[j].Modified)
+		})
+		err := fn(current)
+		current = nil
+		return err
+	}
+
+	paginator := s3.NewListObjectVersionsPaginator(client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucketName),
+		Prefix: optionalString(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		records := make([]versionRecord, 0, len(page.Versions)+len(page.DeleteMarkers))
+		for _, v := range page.Versions {
+			records = append(records, versionRecord{Key: aws.ToString(v.Key), VersionID: aws.ToString(v.VersionId), Modified: aws.ToTime(v.LastModified), Size: aws.ToInt64(v.Size), Latest: aws.ToBool(v.IsLatest)})
+		}
+		for _, m := range page.DeleteMarkers {
+			records = append(records, versionRecord{Key: aws.ToString(m.Key), VersionID: aws.ToString(m.VersionId), Modified: aws.ToTime(m.LastModified), Latest: aws.ToBool(m.IsLatest), Marker: true})
+		}
+		// Keys arrive in order across pages, but versions and markers are listed separately
+		sort.SliceStable(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+		for _, record := range records {
+			if len(current) > 0 && current[0].Key != record.Key {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			current = append(current, record)
+		}
+	}
+	return flush()
+}
+
+// ----------------------------------------------------------------------------
+// Action
+// ----------------------------------------------------------------------------
+
+// executeLifecycleActionImpl manages the lifecycle configuration of a DataCatalog bucket:
+// DownloadAction reads it, UpdateAction replaces it with "lifecycleRules" (or with
+// dryRun reports what the rules would affect now) and DeleteAction removes it.
+func executeLifecycleActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	// Resolve the storage profile (or inline target credentials)
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+	// Reading or dry-running rules may default to the profile's bucket; changing them
+	// must name the bucket
+	bucketName := namedBucket(action)
+	if actionType(action) == "DownloadAction" || (actionType(action) == "UpdateAction" && boolOption(action, "dryRun")) {
+		bucketName = bucketNameFromAction(action, target)
+	}
+	if bucketName == "" {
+		return returnActionError(c, action, "Bucket name is required: set the DataCatalog name or identifier", nil)
+	}
+
+	var rules []lifecycleRule
+	if actionType(action) == "UpdateAction" {
+		if rules, err = lifecycleRulesOption(action); err != nil {
+			return returnActionError(c, action, "Invalid lifecycle rules", err)
+		}
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	value := map[string]interface{}{
+		"@type":      "DataCatalog",
+		"identifier": bucketName,
+		"name":       bucketName,
+		"url":        fmt.Sprintf("s3://%s", bucketName),
+	}
+
+	switch {
+	case actionType(action) == "DeleteAction":
+		_, err := client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{Bucket: aws.String(bucketName)})
+		if err != nil {
+			return returnActionError(c, action, "Failed to delete lifecycle configuration", err)
+		}
+		value["lifecycleRules"] = []lifecycleRule{}
+
+	case actionType(action) == "UpdateAction" && boolOption(action, "dryRun"):
+		limit, err := intOption(action, "maxObjects", defaultLifecycleDryRunCap)
+		if err != nil || limit < 1 {
+			return returnActionError(c, action, "maxObjects must be positive", err)
+		}
+		effects, total, err := lifecycleDryRun(ctx, client, bucketName, rules, time.Now(), int(limit))
+		if err != nil {
+			return returnActionError(c, action, "Failed to evaluate lifecycle rules", err)
+		}
+		entries := make([]interface{}, 0, len(effects))
+		for _, effect := range effects {
+			entry := map[string]interface{}{
+				"@type":           "DigitalDocument",
+				"identifier":      effect.Key,
+				"contentUrl":      fmt.Sprintf("s3://%s/%s", bucketName, effect.Key),
+				"rule":            effect.Rule,
+				"lifecycleAction": effect.Action,
+			}
+			if effect.VersionID != "" {
+				entry["version"] = effect.VersionID
+			}
+			if effect.UploadID != "" {
+				entry["uploadId"] = effect.UploadID
+			}
+			if effect.Class != "" {
+				entry["storageClass"] = effect.Class
+			}
+			entries = append(entries, entry)
+		}
+		value = map[string]interface{}{
+			"@type":          "Dataset",
+			"name":           bucketName,
+			"dryRun":         true,
+			"hasPart":        entries,
+			"affectedCount":  total,
+			"isTruncated":    total > len(effects),
+			"lifecycleRules": rules,
+		}
+
+	case actionType(action) == "UpdateAction":
+		if err := putLifecycleRules(ctx, client, bucketName, rules); err != nil {
+			return returnActionError(c, action, "Failed to update lifecycle configuration", err)
+		}
+		value["lifecycleRules"] = rules
+
+	default:
+		stored, err := getLifecycleRules(ctx, client, bucketName)
+		if err != nil {
+			return returnActionError(c, action, "Failed to read lifecycle configuration", err)
+		}
+		value["lifecycleRules"] = stored
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   asString(value["@type"]),
+		Format: "application/json",
+		Value:  value,
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
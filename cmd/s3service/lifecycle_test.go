@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestParseLifecycleRules_Validation(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty list":          `[]`,
+		"missing id":          `[{"prefix": "temp/", "expiration": {"days": 7}}]`,
+		"no rule action":      `[{"id": "a", "prefix": "temp/"}]`,
+		"unknown field":       `[{"id": "a", "expiration": {"days": 7}, "expire": {"days": 1}}]`,
+		"days and date":       `[{"id": "a", "expiration": {"days": 7, "date": "2030-01-01"}}]`,
+		"date not midnight":   `[{"id": "a", "expiration": {"date": "2030-01-01T12:00:00Z"}}]`,
+		"bad status":          `[{"id": "a", "status": "On", "expiration": {"days": 7}}]`,
+		"duplicate id":        `[{"id": "a", "expiration": {"days": 7}}, {"id": "a", "expiration": {"days": 8}}]`,
+		"unknown class":       `[{"id": "a", "transitions": [{"days": 30, "storageClass": "COLD"}]}]`,
+		"transition too late": `[{"id": "a", "expiration": {"days": 30}, "transitions": [{"days": 60, "storageClass": "GLACIER"}]}]`,
+		"abort with tags":     `[{"id": "a", "tags": {"k": "v"}, "abortIncompleteMultipartUpload": {"daysAfterInitiation": 1}}]`,
+		"noncurrent zero":     `[{"id": "a", "noncurrentVersionExpiration": {"noncurrentDays": 0}}]`,
+		"size bounds":         `[{"id": "a", "objectSizeGreaterThan": 100, "objectSizeLessThan": 50, "expiration": {"days": 7}}]`,
+		"abort with size":     `[{"id": "a", "objectSizeLessThan": 50, "abortIncompleteMultipartUpload": {"daysAfterInitiation": 1}}]`,
+		"negative days":       `[{"id": "a", "transitions": [{"days": -1, "storageClass": "GLACIER"}]}]`,
+	} {
+		if _, err := parseLifecycleRules([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	rules, err := parseLifecycleRules([]byte(`[{"id": "temp", "prefix": "temp/", "expiration": {"days": 7}}]`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rules[0].Status != "Enabled" {
+		t.Errorf("expected the status to default to Enabled, got %q", rules[0].Status)
+	}
+}
+
+func TestLifecycleRules_RoundTrip(t *testing.T) {
+	_, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "lifecycle"))
+
+	rules, err := parseLifecycleRules([]byte(`[
+		{"id": "temp", "prefix": "temp/", "expiration": {"days": 7}, "abortIncompleteMultipartUpload": {"daysAfterInitiation": 2}},
+		{"id": "backups", "prefix": "backups/", "tags": {"class": "archive", "team": "ops"},
+		 "transitions": [{"days": 30, "storageClass": "STANDARD_IA"}, {"days": 90, "storageClass": "GLACIER"}],
+		 "noncurrentVersionExpiration": {"noncurrentDays": 30, "newerNoncurrentVersions": 3}},
+		{"id": "markers", "status": "Disabled", "expiration": {"expiredObjectDeleteMarker": true}},
+		{"id": "sunset", "tags": {"project": "apollo"}, "expiration": {"date": "2030-01-01"}},
+		{"id": "large", "objectSizeGreaterThan": 1048576, "transitions": [{"days": 0, "storageClass": "GLACIER_IR"}]},
+		{"id": "small-logs", "prefix": "logs/", "objectSizeLessThan": 1024, "expiration": {"days": 30}}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := putLifecycleRules(context.Background(), client, "bucket", rules); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	stored, err := getLifecycleRules(context.Background(), client, "bucket")
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if !reflect.DeepEqual(stored, rules) {
+		t.Errorf("rules changed on the way through S3:\nput %+v\ngot %+v", rules, stored)
+	}
+
+	if empty, err := getLifecycleRules(context.Background(), client, "other"); err != nil || len(empty) != 0 {
+		t.Errorf("a bucket without configuration has no rules, got %v (%v)", empty, err)
+	}
+}
+
+func TestLifecycleRule_CurrentEffect(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	rule := lifecycleRule{
+		ID:          "backups",
+		Expiration:  &lifecycleExpiration{Days: 365},
+		Transitions: []lifecycleTransition{{Days: aws.Int32(30), StorageClass: "STANDARD_IA"}, {Days: aws.Int32(90), StorageClass: "GLACIER"}},
+	}
+	for age, want := range map[int]string{10: "", 45: "transition:STANDARD_IA", 100: "transition:GLACIER", 400: "expire"} {
+		effect, ok := rule.currentEffect("db.dump", now.AddDate(0, 0, -age), now)
+		got := ""
+		if ok {
+			got = effect.Action
+			if effect.Class != "" {
+				got += ":" + effect.Class
+			}
+		}
+		if got != want {
+			t.Errorf("age %d days: expected %q, got %q", age, want, got)
+		}
+	}
+}
+
+func TestLifecycleRule_VersionEffects(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+	rule := lifecycleRule{ID: "old", NoncurrentVersionExpiration: &noncurrentExpiration{NoncurrentDays: 30, NewerNoncurrentVersions: 1}}
+
+	// v4 is current; v3 is kept as the newest noncurrent version; v2 became noncurrent
+	// 50 days ago and v1 60 days ago
+	versions := []versionRecord{
+		{Key: "k", VersionID: "v4", Modified: day(50), Latest: true},
+		{Key: "k", VersionID: "v3", Modified: day(55)},
+		{Key: "k", VersionID: "v2", Modified: day(60)},
+		{Key: "k", VersionID: "v1", Modified: day(90)},
+	}
+	effects := rule.versionEffects(versions, now)
+	if len(effects) != 2 || effects[0].VersionID != "v2" || effects[1].VersionID != "v1" {
+		t.Errorf("expected v2 and v1 to expire, got %+v", effects)
+	}
+
+	markerRule := lifecycleRule{ID: "markers", Expiration: &lifecycleExpiration{ExpiredObjectDeleteMarker: true}}
+	lone := []versionRecord{{Key: "gone", VersionID: "m1", Latest: true, Marker: true}}
+	if effects := markerRule.versionEffects(lone, now); len(effects) != 1 || effects[0].Action != "removeDeleteMarker" {
+		t.Errorf("expected the lone delete marker to be removed, got %+v", effects)
+	}
+	hiding := append(lone, versionRecord{Key: "gone", VersionID: "v1"})
+	if effects := markerRule.versionEffects(hiding, now); len(effects) != 0 {
+		t.Errorf("a marker hiding a version is kept, got %+v", effects)
+	}
+}
+
+func TestLifecycleDryRun(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "lifecycle-dry-run"))
+	// The fake reports every object as last modified at the Unix epoch
+	for _, key := range []string{"temp/a", "temp/b", "temp/c", "keep/d"} {
+		fake.objects["bucket/"+key] = []byte(key)
+	}
+
+	rules, err := parseLifecycleRules([]byte(`[
+		{"id": "temp", "prefix": "temp/", "expiration": {"days": 7}},
+		{"id": "future", "expiration": {"date": "2999-01-01"}},
+		{"id": "large", "prefix": "keep/", "objectSizeGreaterThan": 100, "expiration": {"days": 1}},
+		{"id": "off", "status": "Disabled", "expiration": {"days": 1}}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	effects, total, err := lifecycleDryRun(context.Background(), client, "bucket", rules, time.Now(), 2)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if total != 3 || len(effects) != 2 {
+		t.Fatalf("expected 3 affected objects with 2 listed, got %d and %+v", total, effects)
+	}
+	if effects[0].Key != "temp/a" || effects[0].Rule != "temp" || effects[0].Action != "expire" {
+		t.Errorf("unexpected effect %+v", effects[0])
+	}
+	if _, ok := fake.object("bucket", "temp/a"); !ok {
+		t.Error("a dry run must not delete anything")
+	}
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
 	"eve.evalgo.org/web"
 
@@ -19,16 +23,36 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 )
 
+// shutdownTimeout bounds how long requests in flight may take to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Initialize logger
 	logger := common.ServiceLogger("s3service", "1.0.0")
 
 	// Register action handlers with the semantic action registry
 	// This allows the service to handle semantic actions without modifying switch statements
-	semantic.MustRegister("CreateAction", executeUploadAction)
+	semantic.MustRegister("CreateAction", executeCreateAction)
 	semantic.MustRegister("DownloadAction", executeDownloadAction)
 	semantic.MustRegister("DeleteAction", executeDeleteAction)
 	semantic.MustRegister("SearchAction", executeListAction)
+	semantic.MustRegister("TransferAction", executeCopyAction)
+	semantic.MustRegister("MoveAction", executeCopyAction)
+	semantic.MustRegister("UpdateAction", executeUpdateAction)
+	semantic.MustRegister("ReplaceAction", executeReplaceAction)
+
+	// Load named storage profiles from S3_PROFILES_FILE and the environment
+	if err := loadProfiles(); err != nil {
+		logger.WithError(err).Error("Failed to load storage profiles")
+		os.Exit(1)
+	}
+	logger.Infof("Loaded storage profiles: %v", profiles.Names())
+
+	// Default timeout for actions that do not set their own
+	if err := loadActionTimeout(); err != nil {
+		logger.WithError(err).Error("Invalid action timeout")
+		os.Exit(1)
+	}
 
 	e := echo.New()
 
@@ -64,33 +88,163 @@ func main() {
 			{
 				Method:      "POST",
 				Path:        "/v1/api/semantic/action",
-				Description: "Execute S3 operations via semantic actions (primary interface)",
+				Description: "Execute S3 operations via semantic actions (primary interface); with \"async\": true answers 202 and runs the action in the background, kept across restarts and deduplicated by identifier; an ItemList runs a batch of actions",
+			},
+			{
+				Method:      "GET",
+				Path:        "/v1/api/objects",
+				Description: "List objects with prefix, delimiter, cursor and maxKeys (REST convenience - converts to SearchAction)",
 			},
 			{
 				Method:      "POST",
 				Path:        "/v1/api/objects",
-				Description: "Upload object (REST convenience - converts to CreateAction)",
+				Description: "Upload object from JSON (base64), multipart/form-data or raw body (REST convenience - converts to CreateAction)",
+			},
+			{
+				Method:      "POST",
+				Path:        "/v1/api/uploads",
+				Description: "Initiate a client-driven multipart upload (?key=)",
+			},
+			{
+				Method:      "PUT",
+				Path:        "/v1/api/uploads/:uploadId/parts/:partNumber",
+				Description: "Upload one part of a multipart upload (raw body, ?key=)",
+			},
+			{
+				Method:      "POST",
+				Path:        "/v1/api/uploads/:uploadId/complete",
+				Description: "Complete a multipart upload (optional parts list, ?key=)",
+			},
+			{
+				Method:      "DELETE",
+				Path:        "/v1/api/uploads/:uploadId",
+				Description: "Abort a multipart upload (?key=)",
+			},
+			{
+				Method:      "GET",
+				Path:        "/v1/api/objects/*key",
+				Description: "Stream object (or ?versionId=) to the client with Range and conditional request support (REST convenience - converts to DownloadAction)",
+			},
+			{
+				Method:      "HEAD",
+				Path:        "/v1/api/objects/*key",
+				Description: "Object metadata headers without a body",
+			},
+			{
+				Method:      "PATCH",
+				Path:        "/v1/api/objects/*key",
+				Description: "Change content type, cache headers, user metadata or tags in place (REST convenience - converts to UpdateAction)",
 			},
 			{
 				Method:      "GET",
-				Path:        "/v1/api/objects/:key",
-				Description: "Download object (REST convenience - converts to SearchAction)",
+				Path:        "/v1/api/tags/*key",
+				Description: "Read object tags (REST convenience - converts to DownloadAction with tagging)",
+			},
+			{
+				Method:      "PUT",
+				Path:        "/v1/api/tags/*key",
+				Description: "Replace object tags (REST convenience - converts to UpdateAction)",
+			},
+			{
+				Method:      "DELETE",
+				Path:        "/v1/api/tags/*key",
+				Description: "Remove all object tags (REST convenience - converts to DeleteAction with tagging)",
+			},
+			{
+				Method:      "DELETE",
+				Path:        "/v1/api/objects/*key",
+				Description: "Delete object, or permanently delete one ?versionId= (REST convenience - converts to DeleteAction)",
 			},
 			{
 				Method:      "DELETE",
-				Path:        "/v1/api/objects/:key",
-				Description: "Delete object (REST convenience - converts to DeleteAction)",
+				Path:        "/v1/api/objects",
+				Description: "Delete every object under ?prefix= (dryRun, maxObjects safety cap; converts to DeleteAction)",
+			},
+			{
+				Method:      "POST",
+				Path:        "/v1/api/copy",
+				Description: "Copy object within or across buckets and profiles (REST convenience - converts to TransferAction)",
+			},
+			{
+				Method:      "POST",
+				Path:        "/v1/api/move",
+				Description: "Move object within or across buckets and profiles (REST convenience - converts to MoveAction)",
+			},
+			{
+				Method:      "POST",
+				Path:        "/v1/api/presign",
+				Description: "Time-limited GET, PUT or multipart part URL, or a browser POST form with key prefix, size and content-type conditions, signed for the profile's public endpoint (converts to DownloadAction/CreateAction with presign)",
+			},
+			{
+				Method:      "POST",
+				Path:        "/v1/api/presign/callback",
+				Description: "Verify a browser POST upload against its signed policy and record it (converts to CreateAction with uploadCallback)",
+			},
+			{
+				Method:      "POST",
+				Path:        "/v1/api/sync",
+				Description: "Mirror a server directory to a bucket prefix or the reverse, transferring only changed files, with include/exclude globs and optional deletes (converts to CreateAction/DownloadAction with sync)",
+			},
+			{
+				Method:      "POST",
+				Path:        "/v1/api/migrations",
+				Description: "Start an async migration copying every object under a prefix to another profile, bucket or prefix, checkpointed in the job store, with size or checksum verification (converts to TransferAction with migrate; DELETE /v1/api/migrations/:id cancels)",
+			},
+			{
+				Method:      "GET",
+				Path:        "/v1/api/actions",
+				Description: "Recent actions from the state manager with their status, progress, result and errors, newest first (GET /v1/api/actions/:id for one)",
 			},
 			{
 				Method:      "GET",
 				Path:        "/v1/api/buckets",
-				Description: "List buckets (REST convenience - converts to SearchAction)",
+				Description: "List buckets with region and creation date (REST convenience - converts to SearchAction)",
 			},
 			{
 				Method:      "POST",
 				Path:        "/v1/api/buckets",
 				Description: "Create bucket (REST convenience - converts to CreateAction)",
 			},
+			{
+				Method:      "DELETE",
+				Path:        "/v1/api/buckets/:name",
+				Description: "Delete bucket; ?force=true first deletes all object versions, delete markers and uploads (REST convenience - converts to DeleteAction)",
+			},
+			{
+				Method:      "PUT",
+				Path:        "/v1/api/buckets/:name/versioning",
+				Description: "Enable or suspend bucket versioning (REST convenience - converts to UpdateAction)",
+			},
+			{
+				Method:      "GET",
+				Path:        "/v1/api/buckets/:name/lifecycle",
+				Description: "Read bucket lifecycle rules (REST convenience - converts to DownloadAction with lifecycle)",
+			},
+			{
+				Method:      "PUT",
+				Path:        "/v1/api/buckets/:name/lifecycle",
+				Description: "Replace bucket lifecycle rules; ?dryRun=true reports the objects they would affect (converts to UpdateAction)",
+			},
+			{
+				Method:      "DELETE",
+				Path:        "/v1/api/buckets/:name/lifecycle",
+				Description: "Remove all bucket lifecycle rules (REST convenience - converts to DeleteAction with lifecycle)",
+			},
+			{
+				Method:      "GET",
+				Path:        "/v1/api/versions",
+				Description: "List object versions and delete markers (?prefix=, keyMarker, versionIdMarker; converts to SearchAction with versions)",
+			},
+			{
+				Method:      "POST",
+				Path:        "/v1/api/versions/restore",
+				Description: "Restore an earlier object version as the current one (REST convenience - converts to ReplaceAction)",
+			},
+			{
+				Method:      "GET",
+				Path:        "/v1/api/profiles",
+				Description: "List configured storage profiles (without credentials)",
+			},
 			{
 				Method:      "GET",
 				Path:        "/health",
@@ -102,9 +256,12 @@ func main() {
 	// Initialize state manager
 	sm := statemanager.New(statemanager.Config{
 		ServiceName:   "s3service",
-		MaxOperations: 100,
+		MaxOperations: maxTrackedActions,
 	})
 
+	// Every action, async job and migration is recorded as an operation
+	trackedActions.Use(sm)
+
 	// Register state endpoints
 	apiGroup := e.Group("/v1/api")
 	sm.RegisterRoutes(apiGroup)
@@ -158,11 +315,59 @@ func main() {
 		logger.WithError(err).Error("Failed to register with registry")
 	}
 
+	// Abort stale incomplete multipart uploads in each profile's bucket
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+	sweepInterval, sweepMaxAge := multipartSweepSettings()
+	if sweepInterval > 0 {
+		go runMultipartSweeper(sweepCtx, sweepInterval, sweepMaxAge, func(profile, bucket string, aborted int, err error) {
+			if err != nil {
+				logger.WithError(err).Error(fmt.Sprintf("Multipart sweep failed for %s/%s", profile, bucket))
+				return
+			}
+			if aborted > 0 {
+				logger.Infof("Aborted %d stale multipart uploads in %s/%s", aborted, profile, bucket)
+			}
+		})
+	}
+
+	// Run async actions in a bounded worker pool, keeping accepted ones in the job
+	// store so they survive a restart
+	asyncCtx, stopAsync := context.WithCancel(context.Background())
+	defer stopAsync()
+	retention := jobRetention()
+	jobsPath, err := defaultJobStorePath()
+	var jobs *jobStore
+	if err == nil {
+		jobs, err = openJobStore(jobsPath)
+	}
+	if err != nil {
+		logger.WithError(err).Error("Failed to open job store; async actions will not survive a restart")
+	} else {
+		logger.Infof("Keeping async actions in job store %s", jobsPath)
+		defer func() { _ = jobs.Close() }()
+		actions.UseStore(jobs)
+		go runJobPruner(asyncCtx, jobs, jobPruneInterval, retention, func(pruned int, err error) {
+			if err != nil {
+				logger.WithError(err).Error("Failed to prune job store")
+			} else if pruned > 0 {
+				logger.Infof("Pruned %d finished async actions from the job store", pruned)
+			}
+		})
+	}
+	asyncWorkers, asyncQueueSize := asyncSettings()
+	actions.Start(asyncCtx, asyncWorkers, asyncQueueSize)
+	if resumed, failed, err := actions.Resume(retention); err != nil {
+		logger.WithError(err).Error("Failed to resume async actions")
+	} else if resumed+failed > 0 {
+		logger.Infof("Resumed %d async actions; %d could not be resumed and were marked failed", resumed, failed)
+	}
+
 	// Start server in goroutine
 	go func() {
 		logger.Infof("Starting S3 Semantic Service on port %s", port)
 		logger.Info("Supports Hetzner S3, AWS S3, and S3-compatible storage")
-		if err := e.Start(":" + port); err != nil {
+		if err := e.Start(":" + port); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.WithError(err).Error("Server error")
 		}
 	}()
@@ -179,10 +384,17 @@ func main() {
 		logger.WithError(err).Error("Failed to unregister from registry")
 	}
 
-	// Shutdown server
-	if err := e.Close(); err != nil {
+	// Stop accepting requests and let the ones in flight finish
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := e.Shutdown(shutdownCtx); err != nil {
 		logger.WithError(err).Error("Error during shutdown")
 	}
 
+	// Cancel running async actions and migrations; the job store keeps them, with
+	// their checkpoints, and the queued ones for the next start
+	stopAsync()
+	actions.Wait()
+
 	logger.Info("Server stopped")
 }
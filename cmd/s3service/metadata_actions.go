@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/labstack/echo/v4"
+)
+
+// executeUpdateActionImpl changes an object's content type, stored headers, user
+// metadata or tags in place. S3 objects are immutable, so header and metadata changes
+// copy the object onto itself with the REPLACE metadata directive; tags are written
+// with PutObjectTagging and need no copy.
+//
+// metadata and tags are merged into the stored values (an empty value removes an
+// entry) unless metadataDirective or taggingDirective is "REPLACE".
+func executeUpdateActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	// Resolve the storage profile (or inline target credentials) and bucket
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+	bucketName := target.Bucket
+
+	object, err := semantic.GetS3ObjectFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to extract S3 object", err)
+	}
+	s3Key := object.Identifier
+	if s3Key == "" {
+		s3Key = object.Name
+	}
+	if s3Key == "" {
+		return returnActionError(c, action, "Object identifier (S3 key) is required", nil)
+	}
+
+	attrs, err := objectMetadataFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid object metadata", err)
+	}
+	opts := copyOptions{
+		ContentType:        stringOption(action, "contentType"),
+		CacheControl:       aws.ToString(attrs.CacheControl),
+		ContentDisposition: aws.ToString(attrs.ContentDisposition),
+		ContentEncoding:    aws.ToString(attrs.ContentEncoding),
+		Metadata:           attrs.Metadata,
+		MergeMetadata:      !strings.EqualFold(stringOption(action, "metadataDirective"), "REPLACE"),
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = object.EncodingFormat
+	}
+	if opts.Metadata == nil && !opts.MergeMetadata {
+		opts.Metadata = map[string]string{}
+	}
+	tags, hasTags, err := tagsOption(action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid tags", err)
+	}
+	replaceTags := strings.EqualFold(stringOption(action, "taggingDirective"), "REPLACE")
+	if !hasTags && replaceTags {
+		tags, hasTags = map[string]string{}, true
+	}
+	if !opts.replacesHeaders() && !hasTags {
+		return returnActionError(c, action, "Nothing to update: set encodingFormat, cacheControl, contentDisposition, contentEncoding, metadata or tags", nil)
+	}
+
+	partOpts, err := multipartOptionsFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid multipart options", err)
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	if opts.replacesHeaders() {
+		copier := &objectCopy{
+			src:       target,
+			dst:       target,
+			srcClient: client,
+			dstClient: client,
+			srcKey:    s3Key,
+			dstKey:    s3Key,
+			opts:      opts,
+			parts:     partOpts,
+		}
+		if _, err := copier.run(ctx); err != nil {
+			return returnActionError(c, action, "Failed to update object metadata", err)
+		}
+	}
+
+	if hasTags {
+		tags, err = updateObjectTags(ctx, client, bucketName, s3Key, tags, !replaceTags)
+		if err != nil {
+			return returnActionError(c, action, "Failed to update object tags", err)
+		}
+	}
+
+	// Report what S3 stored rather than what was requested
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(s3Key),
+	})
+	if err != nil {
+		return returnActionError(c, action, "Failed to read object metadata", err)
+	}
+
+	value := map[string]interface{}{
+		"@type":       "DigitalDocument",
+		"identifier":  s3Key,
+		"contentUrl":  fmt.Sprintf("s3://%s/%s", bucketName, s3Key),
+		"contentSize": aws.ToInt64(head.ContentLength),
+		"etag":        aws.ToString(head.ETag),
+	}
+	if version := aws.ToString(head.VersionId); version != "" {
+		value["version"] = version
+	}
+	headAttributes(head).describe(value)
+	if hasTags {
+		value["tags"] = propertyValues(tags)
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "DigitalDocument",
+		Format: "application/json",
+		Value:  value,
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/labstack/echo/v4"
+)
+
+// ============================================================================
+// Migration Jobs
+// ============================================================================
+
+const (
+	defaultMigrationConcurrency = 4
+	migrationPageSize           = 1000 // objects listed, copied and checkpointed at a time
+	maxMigrationFailures        = 100  // failures kept in a job; failedCount covers all
+)
+
+// How a copied object is verified. Both compare sizes.
+const (
+	migrationVerifySize     = "size"
+	migrationVerifyChecksum = "checksum" // and stored checksums, or SHA-256 of both objects
+)
+
+// migrationLocation is one side of a migration. Only profiles are accepted, since a
+// migration resumed after a restart must find its credentials again.
+type migrationLocation struct {
+	Profile string `json:"profile"`
+	Bucket  string `json:"bucket"`
+	Prefix  string `json:"prefix,omitempty"`
+}
+
+func (l migrationLocation) String() string {
+	return fmt.Sprintf("s3://%s/%s (%s)", l.Bucket, l.Prefix, l.Profile)
+}
+
+// target resolves the location with the profiles registry
+func (l migrationLocation) target(registry *profileRegistry) (*storageTarget, error) {
+	profile, ok := registry.Get(l.Profile)
+	if !ok {
+		return nil, fmt.Errorf("unknown storage profile %q", l.Profile)
+	}
+	if !profile.Allows("TransferAction") {
+		return nil, fmt.Errorf("TransferAction is not allowed on storage profile %q", profile.Name)
+	}
+	return profile.target(l.Bucket), nil
+}
+
+// migrationFailure is an object that could not be copied or verified
+type migrationFailure struct {
+	Key   string `json:"identifier"`
+	Error string `json:"error"`
+}
+
+// migrationJob is a migration and its progress, saved as the checkpoint of its async
+// action. StartAfter is the checkpoint itself: every source key up to and including
+// it has been handled.
+type migrationJob struct {
+	Source      migrationLocation  `json:"source"`
+	Destination migrationLocation  `json:"destination"`
+	Concurrency int                `json:"concurrency"`
+	Verify      string             `json:"verify"`
+	Parts       multipartOptions   `json:"parts"`
+	StartAfter  string             `json:"startAfter,omitempty"`
+	Copied      int64              `json:"copiedCount"`
+	Skipped     int64              `json:"skippedCount"`
+	Failed      int64              `json:"failedCount"`
+	Bytes       int64              `json:"transferredBytes"`
+	Failures    []migrationFailure `json:"failures,omitempty"`
+}
+
+// result describes the job's outcome; the action's progress fields count every
+// object handled and the bytes copied
+func (j *migrationJob) result() map[string]interface{} {
+	return map[string]interface{}{
+		"@type":            "Dataset",
+		"fromLocation":     j.Source,
+		"toLocation":       j.Destination,
+		"verify":           j.Verify,
+		"copiedCount":      j.Copied,
+		"skippedCount":     j.Skipped,
+		"failedCount":      j.Failed,
+		"transferredBytes": j.Bytes,
+		"checkpoint":       j.StartAfter,
+		"failures":         j.Failures,
+	}
+}
+
+// migration copies every object of one job
+type migration struct {
+	mu                   sync.Mutex // guards job progress
+	job                  *migrationJob
+	src, dst             *storageTarget
+	srcClient, dstClient *s3.Client
+}
+
+// newMigration resolves a job's storage with the profiles registry
+func newMigration(ctx context.Context, job *migrationJob, registry *profileRegistry) (*migration, error) {
+	src, err := job.Source.target(registry)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	dst, err := job.Destination.target(registry)
+	if err != nil {
+		return nil, fmt.Errorf("destination: %w", err)
+	}
+	srcClient, err := createS3Client(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	dstClient, err := createS3Client(ctx, dst)
+	if err != nil {
+		return nil, err
+	}
+	return &migration{job: job, src: src, dst: dst, srcClient: srcClient, dstClient: dstClient}, nil
+}
+
+// runMigration runs a job under the async action of ctx, continuing from the
+// checkpoint an earlier attempt saved. The job's progress is updated in place.
+func runMigration(ctx context.Context, job *migrationJob, registry *profileRegistry) error {
+	if _, err := loadCheckpoint(ctx, job); err != nil {
+		return err
+	}
+	m, err := newMigration(ctx, job, registry)
+	if err != nil {
+		return err
+	}
+	if err := m.run(ctx); err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return err
+	}
+	return nil
+}
+
+// run copies the source prefix page by page, saving the checkpoint and reporting
+// progress after each page, so an interrupted job repeats at most one page. Objects
+// that fail are recorded and the job goes on; only listing and checkpoint errors
+// stop it.
+func (m *migration) run(ctx context.Context) error {
+	for {
+		m.mu.Lock()
+		copied, handled := m.job.Bytes, m.job.Copied+m.job.Skipped+m.job.Failed
+		m.mu.Unlock()
+
+		page, err := m.srcClient.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:     aws.String(m.src.Bucket),
+			Prefix:     aws.String(m.job.Source.Prefix),
+			StartAfter: optionalString(m.job.StartAfter),
+			MaxKeys:    aws.Int32(migrationPageSize),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", m.job.Source, err)
+		}
+
+		m.copyPage(ctx, page.Contents)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		m.mu.Lock()
+		if n := len(page.Contents); n > 0 {
+			m.job.StartAfter = aws.ToString(page.Contents[n-1].Key)
+		}
+		err = saveCheckpoint(ctx, m.job)
+		copied, handled = m.job.Bytes-copied, m.job.Copied+m.job.Skipped+m.job.Failed-handled
+		m.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		reportProgress(ctx, copied, handled)
+		if !aws.ToBool(page.IsTruncated) || len(page.Contents) == 0 {
+			return nil
+		}
+	}
+}
+
+// copyPage copies the objects of one listing page with job.Concurrency workers
+func (m *migration) copyPage(ctx context.Context, objects []types.Object) {
+	jobs := make(chan types.Object)
+	var wg sync.WaitGroup
+	for i := 0; i < m.job.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range jobs {
+				copied, err := m.copyObject(ctx, object)
+				if ctx.Err() != nil {
+					// Interrupted: the checkpoint is not advanced, so the object is redone
+					continue
+				}
+				m.record(aws.ToString(object.Key), aws.ToInt64(object.Size), copied, err)
+			}
+		}()
+	}
+
+feed:
+	for _, object := range objects {
+		select {
+		case jobs <- object:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// record counts an object's outcome
+func (m *migration) record(key string, size int64, copied bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case err != nil:
+		m.job.Failed++
+		if len(m.job.Failures) < maxMigrationFailures {
+			m.job.Failures = append(m.job.Failures, migrationFailure{Key: key, Error: err.Error()})
+		}
+	case copied:
+		m.job.Copied++
+		m.job.Bytes += size
+	default:
+		m.job.Skipped++
+	}
+}
+
+// copyObject copies one object unless the destination already holds it, then
+// verifies the copy. It reports whether anything was copied.
+func (m *migration) copyObject(ctx context.Context, object types.Object) (bool, error) {
+	srcKey := aws.ToString(object.Key)
+	dstKey := m.job.Destination.Prefix + strings.TrimPrefix(srcKey, m.job.Source.Prefix)
+
+	srcHead, err := m.head(ctx, m.srcClient, m.src.Bucket, srcKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read source object: %w", err)
+	}
+	dstHead, err := m.head(ctx, m.dstClient, m.dst.Bucket, dstKey)
+	if err != nil && s3StatusCode(err) != http.StatusNotFound {
+		return false, fmt.Errorf("failed to read destination object: %w", err)
+	}
+	if err == nil {
+		upToDate, err := m.upToDate(ctx, srcKey, dstKey, srcHead, dstHead)
+		if err != nil {
+			return false, err
+		}
+		if upToDate {
+			return false, nil
+		}
+	}
+
+	copier := &objectCopy{
+		src:       m.src,
+		dst:       m.dst,
+		srcClient: m.srcClient,
+		dstClient: m.dstClient,
+		srcKey:    srcKey,
+		dstKey:    dstKey,
+		parts:     m.job.Parts,
+	}
+	// Progress is reported with each checkpoint, so a resumed job counts it once
+	if _, err := copier.run(withoutProgress(ctx)); err != nil {
+		return false, err
+	}
+
+	if dstHead, err = m.head(ctx, m.dstClient, m.dst.Bucket, dstKey); err != nil {
+		return true, fmt.Errorf("failed to verify copy: %w", err)
+	}
+	if m.job.Verify == migrationVerifyChecksum {
+		same, err := m.sameContent(ctx, srcKey, dstKey, srcHead, dstHead)
+		if err != nil {
+			return true, fmt.Errorf("failed to verify copy: %w", err)
+		}
+		if !same {
+			return true, fmt.Errorf("checksum mismatch after copy")
+		}
+	} else if src, dst := aws.ToInt64(srcHead.ContentLength), aws.ToInt64(dstHead.ContentLength); src != dst {
+		return true, fmt.Errorf("size mismatch after copy: source %d, destination %d bytes", src, dst)
+	}
+	return true, nil
+}
+
+func (m *migration) head(ctx context.Context, client *s3.Client, bucketName, key string) (*s3.HeadObjectOutput, error) {
+	return client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(bucketName),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+}
+
+// upToDate reports whether an existing destination object needs no copy: same size
+// and, when verifying checksums, the same content; otherwise the same ETag or a
+// destination written after the source
+func (m *migration) upToDate(ctx context.Context, srcKey, dstKey string, srcHead, dstHead *s3.HeadObjectOutput) (bool, error) {
+	if aws.ToInt64(srcHead.ContentLength) != aws.ToInt64(dstHead.ContentLength) {
+		return false, nil
+	}
+	if m.job.Verify == migrationVerifyChecksum {
+		return m.sameContent(ctx, srcKey, dstKey, srcHead, dstHead)
+	}
+	return aws.ToString(srcHead.ETag) == aws.ToString(dstHead.ETag) ||
+		!aws.ToTime(dstHead.LastModified).Before(aws.ToTime(srcHead.LastModified)), nil
+}
+
+// sameContent compares a checksum both objects store (SHA-256, CRC32C or a plain MD5
+// ETag). When they share none, both objects are read and hashed.
+func (m *migration) sameContent(ctx context.Context, srcKey, dstKey string, srcHead, dstHead *s3.HeadObjectOutput) (bool, error) {
+	var srcSpec, dstSpec checksumSpec
+	srcSpec.expectStored(srcHead)
+	dstSpec.expectStored(dstHead)
+	for _, algorithm := range checksumAlgorithms {
+		srcDigest, ok := srcSpec.Expected[algorithm]
+		if !ok {
+			continue
+		}
+		if dstDigest, ok := dstSpec.Expected[algorithm]; ok {
+			return bytes.Equal(srcDigest, dstDigest), nil
+		}
+	}
+
+	srcDigest, err := objectSHA256(ctx, m.srcClient, m.src.Bucket, srcKey)
+	if err != nil {
+		return false, err
+	}
+	dstDigest, err := objectSHA256(ctx, m.dstClient, m.dst.Bucket, dstKey)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(srcDigest, dstDigest), nil
+}
+
+// objectSHA256 streams an object and returns its SHA-256
+func objectSHA256(ctx context.Context, client *s3.Client, bucketName, key string) ([]byte, error) {
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = output.Body.Close() }()
+
+	w := newChecksumWriter([]string{checksumSHA256})
+	if _, err := io.Copy(w, output.Body); err != nil {
+		return nil, err
+	}
+	return w.sums()[checksumSHA256], nil
+}
+
+// migrationJobFromAction reads the source prefix (object.identifier), destination
+// prefix (targetUrl, default the same), fromLocation, toLocation and the concurrency
+// and verify options of a TransferAction with migrate
+func migrationJobFromAction(action *semantic.SemanticAction) (*migrationJob, error) {
+	src, err := resolveLocation(action, "fromLocation")
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	dst, err := resolveLocation(action, "toLocation")
+	if err != nil {
+		return nil, fmt.Errorf("destination: %w", err)
+	}
+	if src.Profile == "" || dst.Profile == "" {
+		return nil, fmt.Errorf("migrations need storage profiles: inline credentials are not kept for resuming")
+	}
+
+	srcPrefix := asString(actionNode(action, "object")["identifier"])
+	dstPrefix := semantic.GetS3TargetUrlFromAction(action)
+	if dstPrefix == "" {
+		dstPrefix = srcPrefix
+	}
+	if src.sameService(dst) && src.Bucket == dst.Bucket &&
+		(strings.HasPrefix(srcPrefix, dstPrefix) || strings.HasPrefix(dstPrefix, srcPrefix)) {
+		return nil, fmt.Errorf("source and destination prefixes overlap")
+	}
+
+	verify := strings.ToLower(stringOption(action, "verify"))
+	switch verify {
+	case "":
+		verify = migrationVerifySize
+	case migrationVerifySize, migrationVerifyChecksum:
+	default:
+		return nil, fmt.Errorf("verify must be size or checksum")
+	}
+	concurrency, err := intOption(action, "concurrency", defaultMigrationConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 || concurrency > maxPartConcurrency {
+		return nil, fmt.Errorf("concurrency must be between 1 and %d", maxPartConcurrency)
+	}
+	parts, err := multipartOptionsFromAction(action)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &migrationJob{
+		Source:      migrationLocation{Profile: src.Profile, Bucket: src.Bucket, Prefix: srcPrefix},
+		Destination: migrationLocation{Profile: dst.Profile, Bucket: dst.Bucket, Prefix: dstPrefix},
+		Concurrency: int(concurrency),
+		Verify:      verify,
+		Parts:       parts,
+	}
+	return job, nil
+}
+
+// executeMigrationActionImpl copies every object under a prefix to another profile,
+// bucket or prefix (TransferAction with migrate). Migrations always run as async
+// actions, so the job store keeps their checkpoint and progress is read from
+// /v1/api/actions/{id}; a retry or the next start continues after the last
+// checkpoint.
+func executeMigrationActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	job, err := migrationJobFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid migration", err)
+	}
+
+	err = runMigration(c.Request().Context(), job, profiles)
+	action.Result = &semantic.SemanticResult{
+		Type:   "Dataset",
+		Format: "application/json",
+		Value:  job.result(),
+	}
+	if err != nil {
+		return returnActionError(c, action, "Migration stopped", err)
+	}
+	if job.Failed > 0 {
+		return returnActionError(c, action, fmt.Sprintf("%d objects could not be migrated", job.Failed), nil)
+	}
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
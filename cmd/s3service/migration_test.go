@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// migrationProfiles registers a source and a destination profile on two fake services
+func migrationProfiles(t *testing.T, srcURL, dstURL string) *profileRegistry {
+	t.Helper()
+	registry := newProfileRegistry()
+	for _, profile := range []StorageProfile{
+		{Name: "aws", Endpoint: srcURL, Region: "us-east-1", Bucket: "bucket", AccessKey: "migrate-src", SecretKey: "secret"},
+		{Name: "hetzner", Endpoint: dstURL, Region: "us-east-1", Bucket: "bucket", AccessKey: "migrate-dst", SecretKey: "secret"},
+	} {
+		if err := registry.Add(profile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return registry
+}
+
+// migrationHandler runs a copy of job the way executeMigrationActionImpl does and
+// hands the job it ran to done
+func migrationHandler(job migrationJob, registry *profileRegistry, done chan<- *migrationJob) actionFunc {
+	return func(c echo.Context) error {
+		run := job
+		err := runMigration(c.Request().Context(), &run, registry)
+		done <- &run
+		if err != nil || run.Failed > 0 {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{"actionStatus": actionStatusFailed, "result": run.result(), "error": "migration failed"})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{"actionStatus": actionStatusCompleted, "result": run.result()})
+	}
+}
+
+func startMigrationRunner(t *testing.T, store *jobStore) (*stateTracker, *actionRunner) {
+	t.Helper()
+	tracker := newTestStateTracker()
+	runner := newActionRunner(tracker)
+	runner.UseStore(store)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() { cancel(); runner.Wait() })
+	runner.Start(ctx, 1, 10)
+	return tracker, runner
+}
+
+func TestMigration_CopiesSkipsAndVerifies(t *testing.T) {
+	src, srcServer := newFakeS3Server(t)
+	dst, dstServer := newFakeS3Server(t)
+	src.lastModified = time.Now()
+	src.objects["bucket/data/a.txt"] = []byte("alpha")
+	src.objects["bucket/data/b.txt"] = []byte("bravo")
+	src.objects["bucket/data/c/d.txt"] = []byte("delta")
+	src.objects["bucket/other.txt"] = []byte("not migrated")
+	dst.objects["bucket/moved/a.txt"] = []byte("alpha") // already there
+	dst.objects["bucket/moved/b.txt"] = []byte("BRAVO") // same size, older and different
+
+	store := newTestJobStore(t)
+	tracker, runner := startMigrationRunner(t, store)
+	job := migrationJob{
+		Source:      migrationLocation{Profile: "aws", Bucket: "bucket", Prefix: "data/"},
+		Destination: migrationLocation{Profile: "hetzner", Bucket: "bucket", Prefix: "moved/"},
+		Concurrency: 2,
+		Verify:      migrationVerifyChecksum,
+		Parts:       multipartOptions{PartSize: minPartSize, Concurrency: 1, Threshold: minPartSize},
+	}
+	done := make(chan *migrationJob, 1)
+	handle := migrationHandler(job, migrationProfiles(t, srcServer.URL, dstServer.URL), done)
+	accepted, _, err := runner.Accept(trackedAction{Type: "TransferAction"}, []byte(`{"@type": "TransferAction"}`), handle, retryPolicy{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+
+	tracked := waitForStatus(t, tracker, accepted.Identifier)
+	ran := <-done
+	if tracked.ActionStatus != actionStatusCompleted {
+		t.Fatalf("expected a completed migration, got %+v", tracked)
+	}
+	if ran.Copied != 2 || ran.Skipped != 1 || ran.Failed != 0 {
+		t.Errorf("unexpected progress %+v", ran)
+	}
+	if tracked.ItemsProcessed != 3 || tracked.BytesTransferred != ran.Bytes {
+		t.Errorf("expected the progress fields to count the objects and copied bytes, got %d items and %d bytes", tracked.ItemsProcessed, tracked.BytesTransferred)
+	}
+	for key, want := range map[string]string{"moved/a.txt": "alpha", "moved/b.txt": "bravo", "moved/c/d.txt": "delta"} {
+		if content, _ := dst.object("bucket", key); string(content) != want {
+			t.Errorf("%s: expected %q, got %q", key, want, content)
+		}
+	}
+	if _, ok := dst.object("bucket", "moved/other.txt"); ok {
+		t.Error("objects outside the prefix must not be migrated")
+	}
+
+	jobs, _ := store.List()
+	var checkpoint migrationJob
+	if len(jobs) != 1 || json.Unmarshal(jobs[0].Checkpoint, &checkpoint) != nil || checkpoint.StartAfter != "data/c/d.txt" {
+		t.Errorf("expected the job store to keep the last checkpoint, got %+v", jobs)
+	}
+}
+
+func TestMigration_ResumesFromCheckpoint(t *testing.T) {
+	src, srcServer := newFakeS3Server(t)
+	dst, dstServer := newFakeS3Server(t)
+	for _, key := range []string{"a", "b", "c"} {
+		src.objects["bucket/data/"+key] = []byte(key)
+	}
+
+	// A migration cut off by the last shutdown after its first checkpoint
+	store := newTestJobStore(t)
+	job := migrationJob{
+		Source:      migrationLocation{Profile: "aws", Bucket: "bucket", Prefix: "data/"},
+		Destination: migrationLocation{Profile: "hetzner", Bucket: "bucket", Prefix: "data/"},
+		Concurrency: 1,
+		Verify:      migrationVerifySize,
+		Parts:       multipartOptions{PartSize: minPartSize, Concurrency: 1, Threshold: minPartSize},
+	}
+	checkpoint := job
+	checkpoint.StartAfter, checkpoint.Copied, checkpoint.Bytes = "data/b", 2, 2
+	data, _ := json.Marshal(checkpoint)
+	stored := &asyncJob{
+		Action: trackedAction{
+			Identifier:       "migration-1",
+			Type:             "TransferAction",
+			ActionStatus:     actionStatusActive,
+			StartTime:        time.Now().UTC(),
+			BytesTransferred: 2,
+			ItemsProcessed:   2,
+		},
+		Body:       json.RawMessage(`{"@type": "TransferAction"}`),
+		Retry:      retryPolicy{MaxAttempts: 1},
+		Checkpoint: data,
+	}
+	if _, err := store.Create(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker, runner := startMigrationRunner(t, store)
+	done := make(chan *migrationJob, 1)
+	handle := migrationHandler(job, migrationProfiles(t, srcServer.URL, dstServer.URL), done)
+	runner.build = func([]byte) (actionFunc, error) { return handle, nil }
+	if resumed, failed, err := runner.Resume(time.Hour); err != nil || resumed != 1 || failed != 0 {
+		t.Fatalf("expected the migration to resume, got %d resumed and %d failed (%v)", resumed, failed, err)
+	}
+
+	tracked := waitForStatus(t, tracker, "migration-1")
+	ran := <-done
+	if _, ok := dst.object("bucket", "data/c"); !ok {
+		t.Error("expected the object after the checkpoint to be copied")
+	}
+	for _, key := range []string{"data/a", "data/b"} {
+		if _, ok := dst.object("bucket", key); ok {
+			t.Errorf("%s is before the checkpoint and must not be copied again", key)
+		}
+	}
+	if tracked.ActionStatus != actionStatusCompleted || ran.Copied != 3 || tracked.ItemsProcessed != 3 || tracked.BytesTransferred != 3 {
+		t.Errorf("expected the migration to complete with the earlier progress, got %+v after %+v", tracked, ran)
+	}
+}
+
+func TestMigration_FailsUnresolvableProfiles(t *testing.T) {
+	registry := migrationProfiles(t, "http://localhost:1", "http://localhost:2")
+	job := &migrationJob{
+		Source:      migrationLocation{Profile: "removed", Bucket: "bucket"},
+		Destination: migrationLocation{Profile: "hetzner", Bucket: "bucket"},
+	}
+	if err := runMigration(context.Background(), job, registry); err == nil {
+		t.Error("expected a migration from an unknown profile to fail")
+	}
+}
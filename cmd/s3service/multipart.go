@@ -0,0 +1,661 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ============================================================================
+// Multipart Upload
+// ============================================================================
+
+const (
+	minPartSize               = 5 << 20  // S3 minimum for every part but the last
+	defaultPartSize           = 16 << 20 // part size when the action does not set one
+	defaultPartConcurrency    = 4        // parts uploaded in parallel
+	maxPartConcurrency        = 32
+	defaultMultipartThreshold = 64 << 20 // objects at least this large use multipart upload
+	maxUploadParts            = 10000    // S3 limit on parts per upload
+	maxPartSize               = 5 << 30  // S3 limit on a single part
+	maxObjectSize             = 5 << 40  // S3 limit on a single object
+)
+
+// multipartOptions controls when and how uploads are split into parts
+type multipartOptions struct {
+	PartSize    int64
+	Concurrency int
+	Threshold   int64
+}
+
+// multipartOptionsFromAction reads partSize, concurrency and multipartThreshold options
+func multipartOptionsFromAction(action *semantic.SemanticAction) (multipartOptions, error) {
+	partSize, err := intOption(action, "partSize", defaultPartSize)
+	if err != nil {
+		return multipartOptions{}, err
+	}
+	concurrency, err := intOption(action, "concurrency", defaultPartConcurrency)
+	if err != nil {
+		return multipartOptions{}, err
+	}
+	threshold, err := intOption(action, "multipartThreshold", defaultMultipartThreshold)
+	if err != nil {
+		return multipartOptions{}, err
+	}
+
+	if partSize < minPartSize {
+		return multipartOptions{}, fmt.Errorf("partSize must be at least %d bytes", minPartSize)
+	}
+	if concurrency < 1 || concurrency > maxPartConcurrency {
+		return multipartOptions{}, fmt.Errorf("concurrency must be between 1 and %d", maxPartConcurrency)
+	}
+	if threshold < minPartSize {
+		threshold = minPartSize
+	}
+
+	return multipartOptions{PartSize: partSize, Concurrency: int(concurrency), Threshold: threshold}, nil
+}
+
+// partSizeFor grows the part size so the object fits in maxUploadParts parts
+func (o multipartOptions) partSizeFor(size int64) int64 {
+	partSize := o.PartSize
+	if minimum := (size + maxUploadParts - 1) / maxUploadParts; partSize < minimum {
+		partSize = minimum
+	}
+	return partSize
+}
+
+// completedPart is an uploaded part as needed by CompleteMultipartUpload
+type completedPart struct {
+	PartNumber     int32  `json:"partNumber"`
+	ETag           string `json:"etag"`
+	Size           int64  `json:"size,omitempty"`
+	ChecksumSHA256 string `json:"checksumSha256,omitempty"`
+}
+
+// multipartState is the persisted progress of a resumable upload
+type multipartState struct {
+	UploadID string          `json:"uploadId"`
+	Bucket   string          `json:"bucket"`
+	Key      string          `json:"key"`
+	Size     int64           `json:"size"`
+	PartSize int64           `json:"partSize"`
+	Parts    []completedPart `json:"parts"`
+	Created  time.Time       `json:"created"`
+	// Checksummed uploads send a SHA-256 with every part; older state is not resumed
+	Checksummed bool `json:"checksummed,omitempty"`
+}
+
+// multipartStore persists upload IDs and completed-part ETags as JSON files so an
+// interrupted upload of the same source can continue where it stopped
+type multipartStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// multipartStates is the service-wide resume store (S3_MULTIPART_STATE_DIR)
+var multipartStates = &multipartStore{dir: defaultMultipartStateDir()}
+
+// defaultMultipartStateDir is S3_MULTIPART_STATE_DIR, or multipart in the service's
+// state directory so uploads still resume after a restart. Only without a home
+// directory does progress fall back to the temp dir.
+func defaultMultipartStateDir() string {
+	if dir := os.Getenv("S3_MULTIPART_STATE_DIR"); dir != "" {
+		return dir
+	}
+	if dir, err := serviceStateDir(); err == nil {
+		return filepath.Join(dir, "multipart")
+	}
+	return filepath.Join(os.TempDir(), "s3service-multipart")
+}
+
+// multipartStateKey identifies an upload of a specific version of a source file
+func multipartStateKey(target *storageTarget, key, source string, size int64, modTime time.Time) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%d\x00%d", target.Endpoint, target.Bucket, key, source, size, modTime.UnixNano())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *multipartStore) path(stateKey string) string {
+	return filepath.Join(s.dir, stateKey+".json")
+}
+
+// Load returns the saved state for a key, if any
+func (s *multipartStore) Load(stateKey string) (*multipartState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path(stateKey))
+	if err != nil {
+		return nil, false
+	}
+	var state multipartState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, false
+	}
+	return &state, true
+}
+
+// Save writes the state atomically
+func (s *multipartStore) Save(stateKey string, state *multipartState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create multipart state dir: %w", err)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(stateKey) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write multipart state: %w", err)
+	}
+	return os.Rename(tmp, s.path(stateKey))
+}
+
+// Delete removes the saved state for a key
+func (s *multipartStore) Delete(stateKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = os.Remove(s.path(stateKey))
+}
+
+// uploadResult describes a finished upload
+type uploadResult struct {
+	ETag         string
+	VersionID    string
+	Multipart    bool
+	UploadID     string
+	Parts        int
+	ResumedParts int
+	// Checksums are the digests computed for body.Checksums while uploading
+	Checksums map[string][]byte
+}
+
+// uploadObject stores a body with PutObject, or with a parallel multipart upload when
+// it is at least opts.Threshold bytes. A non-empty stateKey makes the multipart upload
+// resumable: progress is persisted and kept when the upload fails. S3 checks a SHA-256
+// of every request body; content that does not match body.Checksums is not kept.
+func uploadObject(ctx context.Context, client *s3.Client, bucketName, key string, body *uploadBody, stateKey string, opts multipartOptions) (*uploadResult, error) {
+	uploader := &multipartUploader{
+		client:   client,
+		bucket:   bucketName,
+		key:      key,
+		opts:     opts,
+		stateKey: stateKey,
+	}
+
+	if body.Stream != nil {
+		if body.Size >= opts.Threshold {
+			return uploader.uploadStream(ctx, body)
+		}
+		// A stream below the threshold is read into memory so the SDK can sign and
+		// checksum it like any other PutObject body
+		data := make([]byte, body.Size)
+		if _, err := io.ReadFull(body.Stream, data); err != nil {
+			return nil, fmt.Errorf("failed to read upload content: %w", err)
+		}
+		buffered := *body
+		buffered.Reader, buffered.Stream = bytes.NewReader(data), nil
+		return putObjectVerified(ctx, client, bucketName, key, &buffered)
+	}
+
+	readerAt, ok := body.Reader.(io.ReaderAt)
+	if !ok || body.Size < opts.Threshold {
+		return putObjectVerified(ctx, client, bucketName, key, body)
+	}
+	return uploader.upload(ctx, readerAt, body)
+}
+
+// multipartUploader runs one multipart upload with parallel part uploads
+type multipartUploader struct {
+	client   *s3.Client
+	bucket   string
+	key      string
+	opts     multipartOptions
+	stateKey string
+
+	mu    sync.Mutex
+	state *multipartState
+}
+
+func (u *multipartUploader) upload(ctx context.Context, src io.ReaderAt, body *uploadBody) (*uploadResult, error) {
+	partSize := u.opts.partSizeFor(body.Size)
+	resumed := u.resume(ctx, body.Size, partSize)
+
+	// Parts finish out of order, so the whole-object digests come from one sequential
+	// pass over the source that runs alongside the part uploads
+	hashCtx, cancelHash := context.WithCancel(ctx)
+	defer cancelHash()
+	type hashed struct {
+		sums map[string][]byte
+		err  error
+	}
+	hashes := make(chan hashed, 1)
+	if len(body.Checksums.Compute) > 0 {
+		go func() {
+			sums, err := hashSection(hashCtx, src, body.Size, body.Checksums.Compute)
+			hashes <- hashed{sums, err}
+		}()
+	}
+
+	if u.state == nil {
+		if err := u.create(ctx, body, partSize); err != nil {
+			return nil, err
+		}
+	}
+
+	err := u.uploadParts(ctx, body.Size, partSize, func(number int32, offset, length int64) (io.ReadSeeker, error) {
+		return io.NewSectionReader(src, offset, length), nil
+	})
+	if err != nil {
+		if u.stateKey == "" {
+			// Nothing can resume this upload, so release the stored parts
+			u.abort()
+		}
+		return nil, fmt.Errorf("multipart upload %s failed: %w", u.state.UploadID, err)
+	}
+
+	var sums map[string][]byte
+	if len(body.Checksums.Compute) > 0 {
+		result := <-hashes
+		if sums, err = result.sums, result.err; err != nil {
+			u.discard()
+			return nil, err
+		}
+	}
+	return u.complete(ctx, body, sums, resumed)
+}
+
+// uploadStream uploads a body that can only be read once, such as a request body.
+// Parts are read from it in order while earlier ones are still uploading, so at most
+// concurrency+1 parts are held in memory. A streamed upload cannot resume.
+func (u *multipartUploader) uploadStream(ctx context.Context, body *uploadBody) (*uploadResult, error) {
+	partSize := u.opts.partSizeFor(body.Size)
+	if err := u.create(ctx, body, partSize); err != nil {
+		return nil, err
+	}
+
+	// Parts are read in order, so the whole-object digests are computed on the way
+	hashes := newChecksumWriter(body.Checksums.Compute)
+	src := io.TeeReader(body.Stream, hashes)
+	err := u.uploadParts(ctx, body.Size, partSize, func(number int32, offset, length int64) (io.ReadSeeker, error) {
+		data := make([]byte, length)
+		if _, err := io.ReadFull(src, data); err != nil {
+			return nil, fmt.Errorf("failed to read part %d: %w", number, err)
+		}
+		return bytes.NewReader(data), nil
+	})
+	if err != nil {
+		u.abort()
+		return nil, fmt.Errorf("multipart upload %s failed: %w", u.state.UploadID, err)
+	}
+	return u.complete(ctx, body, hashes.sums(), 0)
+}
+
+// create initiates the multipart upload and records it in a fresh state
+func (u *multipartUploader) create(ctx context.Context, body *uploadBody, partSize int64) error {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String(u.bucket),
+		Key:               aws.String(u.key),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	}
+	if body.ContentType != "" {
+		input.ContentType = aws.String(body.ContentType)
+	}
+	body.Attributes.applyToCreate(input)
+	created, err := u.client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+	u.state = &multipartState{
+		UploadID:    aws.ToString(created.UploadId),
+		Bucket:      u.bucket,
+		Key:         u.key,
+		Size:        body.Size,
+		PartSize:    partSize,
+		Created:     time.Now(),
+		Checksummed: true,
+	}
+	u.persist()
+	return nil
+}
+
+// complete verifies the whole-object digests and assembles the uploaded parts
+func (u *multipartUploader) complete(ctx context.Context, body *uploadBody, sums map[string][]byte, resumed int) (*uploadResult, error) {
+	if len(body.Checksums.Compute) > 0 {
+		if err := body.Checksums.verify(sums); err != nil {
+			// Never assemble content that does not match
+			u.discard()
+			return nil, err
+		}
+	} else {
+		sums = nil
+	}
+
+	parts := sortedParts(u.state.Parts)
+	completed, err := completeMultipartUpload(ctx, u.client, u.bucket, u.key, u.state.UploadID, parts)
+	if err != nil {
+		return nil, err
+	}
+	if u.stateKey != "" {
+		multipartStates.Delete(u.stateKey)
+	}
+
+	return &uploadResult{
+		ETag:         aws.ToString(completed.ETag),
+		VersionID:    aws.ToString(completed.VersionId),
+		Multipart:    true,
+		UploadID:     u.state.UploadID,
+		Parts:        len(parts),
+		ResumedParts: resumed,
+		Checksums:    sums,
+	}, nil
+}
+
+// discard aborts the upload and forgets its saved progress
+func (u *multipartUploader) discard() {
+	u.abort()
+	if u.stateKey != "" {
+		multipartStates.Delete(u.stateKey)
+	}
+}
+
+// resume loads persisted progress and reconciles it with the parts S3 still holds.
+// It returns the number of parts that do not need to be uploaded again.
+func (u *multipartUploader) resume(ctx context.Context, size, partSize int64) int {
+	if u.stateKey == "" {
+		return 0
+	}
+	state, ok := multipartStates.Load(u.stateKey)
+	if !ok || state.Size != size || state.PartSize != partSize || !state.Checksummed {
+		return 0
+	}
+
+	// S3 is the source of truth for which parts actually arrived
+	parts, err := listUploadedParts(ctx, u.client, u.bucket, u.key, state.UploadID)
+	if err != nil {
+		multipartStates.Delete(u.stateKey)
+		return 0
+	}
+	state.Parts = parts
+	u.state = state
+	return len(parts)
+}
+
+// partJob is a part waiting for a worker
+type partJob struct {
+	number int32
+	body   io.ReadSeeker
+	length int64
+}
+
+// uploadParts uploads all parts not yet in the state using opts.Concurrency workers.
+// partBody returns the content of a part; it is called in part order.
+func (u *multipartUploader) uploadParts(ctx context.Context, size, partSize int64, partBody func(number int32, offset, length int64) (io.ReadSeeker, error)) error {
+	done := map[int32]bool{}
+	for _, part := range u.state.Parts {
+		done[part.PartNumber] = true
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	partCount := int32((size + partSize - 1) / partSize)
+	jobs := make(chan partJob)
+	errs := make(chan error, u.opts.Concurrency+1)
+	var wg sync.WaitGroup
+
+	for i := 0; i < u.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				number, length := job.number, job.length
+				// The SDK computes each part's SHA-256 while sending it and S3 checks it
+				output, err := u.client.UploadPart(ctx, &s3.UploadPartInput{
+					Bucket:            aws.String(u.bucket),
+					Key:               aws.String(u.key),
+					UploadId:          aws.String(u.state.UploadID),
+					PartNumber:        aws.Int32(number),
+					Body:              job.body,
+					ContentLength:     aws.Int64(length),
+					ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+				})
+				if err != nil {
+					errs <- fmt.Errorf("part %d: %w", number, err)
+					cancel()
+					return
+				}
+				u.recordPart(completedPart{
+					PartNumber:     number,
+					ETag:           aws.ToString(output.ETag),
+					Size:           length,
+					ChecksumSHA256: aws.ToString(output.ChecksumSHA256),
+				})
+				reportProgress(ctx, length, 0)
+			}
+		}()
+	}
+
+feed:
+	for number := int32(1); number <= partCount; number++ {
+		if done[number] {
+			continue
+		}
+		offset := int64(number-1) * partSize
+		length := partSize
+		if offset+length > size {
+			length = size - offset
+		}
+		body, err := partBody(number, offset, length)
+		if err != nil {
+			errs <- err
+			cancel()
+			break
+		}
+		select {
+		case jobs <- partJob{number: number, body: body, length: length}:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// recordPart adds a finished part to the state and persists it
+func (u *multipartUploader) recordPart(part completedPart) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.state.Parts = append(u.state.Parts, part)
+	u.persistLocked()
+}
+
+func (u *multipartUploader) persist() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.persistLocked()
+}
+
+func (u *multipartUploader) persistLocked() {
+	if u.stateKey == "" {
+		return
+	}
+	// Losing a checkpoint only costs re-uploading parts, so failures are not fatal
+	_ = multipartStates.Save(u.stateKey, u.state)
+}
+
+// abort releases an upload's parts, using a fresh context since ctx may be cancelled
+func (u *multipartUploader) abort() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_ = abortMultipartUpload(ctx, u.client, u.bucket, u.key, u.state.UploadID)
+}
+
+// sortedParts orders parts by number as CompleteMultipartUpload requires
+func sortedParts(parts []completedPart) []completedPart {
+	sorted := append([]completedPart(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PartNumber < sorted[j].PartNumber })
+	return sorted
+}
+
+// completeMultipartUpload assembles uploaded parts into the final object
+func completeMultipartUpload(ctx context.Context, client *s3.Client, bucketName, key, uploadID string, parts []completedPart) (*s3.CompleteMultipartUploadOutput, error) {
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, types.CompletedPart{
+			PartNumber:     aws.Int32(part.PartNumber),
+			ETag:           aws.String(part.ETag),
+			ChecksumSHA256: optionalString(part.ChecksumSHA256),
+		})
+	}
+
+	output, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return output, nil
+}
+
+// abortMultipartUpload discards an upload and its parts
+func abortMultipartUpload(ctx context.Context, client *s3.Client, bucketName, key, uploadID string) error {
+	_, err := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	return err
+}
+
+// listUploadedParts returns every part S3 holds for an upload
+func listUploadedParts(ctx context.Context, client *s3.Client, bucketName, key, uploadID string) ([]completedPart, error) {
+	var parts []completedPart
+	paginator := s3.NewListPartsPaginator(client, &s3.ListPartsInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range page.Parts {
+			parts = append(parts, completedPart{
+				PartNumber:     aws.ToInt32(part.PartNumber),
+				ETag:           aws.ToString(part.ETag),
+				Size:           aws.ToInt64(part.Size),
+				ChecksumSHA256: aws.ToString(part.ChecksumSHA256),
+			})
+		}
+	}
+	return parts, nil
+}
+
+// ============================================================================
+// Stale Upload Sweeper
+// ============================================================================
+
+// abortStaleUploads aborts incomplete multipart uploads in a bucket that were
+// initiated more than maxAge ago, returning how many were aborted
+func abortStaleUploads(ctx context.Context, client *s3.Client, bucketName string, maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	aborted := 0
+	var errs []error
+
+	paginator := s3.NewListMultipartUploadsPaginator(client, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(bucketName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return aborted, err
+		}
+		for _, upload := range page.Uploads {
+			if upload.Initiated == nil || upload.Initiated.After(cutoff) {
+				continue
+			}
+			if err := abortMultipartUpload(ctx, client, bucketName, aws.ToString(upload.Key), aws.ToString(upload.UploadId)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", aws.ToString(upload.Key), err))
+				continue
+			}
+			aborted++
+		}
+	}
+	return aborted, errors.Join(errs...)
+}
+
+// multipartSweepSettings reads S3_MULTIPART_SWEEP_INTERVAL (default 1h, "0" disables)
+// and S3_MULTIPART_MAX_AGE (default 24h)
+func multipartSweepSettings() (time.Duration, time.Duration) {
+	interval, maxAge := time.Hour, 24*time.Hour
+	if value := os.Getenv("S3_MULTIPART_SWEEP_INTERVAL"); value != "" {
+		if parsed, err := parseTimeout(value); err == nil {
+			interval = parsed
+		}
+	}
+	if value := os.Getenv("S3_MULTIPART_MAX_AGE"); value != "" {
+		if parsed, err := parseTimeout(value); err == nil && parsed > 0 {
+			maxAge = parsed
+		}
+	}
+	return interval, maxAge
+}
+
+// runMultipartSweeper periodically aborts stale uploads in the default bucket of
+// every storage profile until ctx ends. report is called once per bucket swept.
+func runMultipartSweeper(ctx context.Context, interval, maxAge time.Duration, report func(profile, bucket string, aborted int, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, name := range profiles.Names() {
+			profile, ok := profiles.Get(name)
+			if !ok || profile.Bucket == "" {
+				continue
+			}
+			client, err := createS3Client(ctx, profile.target(profile.Bucket))
+			if err != nil {
+				report(name, profile.Bucket, 0, err)
+				continue
+			}
+			aborted, err := abortStaleUploads(ctx, client, profile.Bucket, maxAge)
+			report(name, profile.Bucket, aborted, err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/labstack/echo/v4"
+)
+
+// executeMultipartActionImpl runs a client-driven multipart step selected by the
+// "multipart" option: initiate, uploadPart, complete or abort. Clients that push parts
+// themselves use these instead of a single CreateAction upload.
+func executeMultipartActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	// Resolve the storage profile (or inline target credentials) and bucket
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+	bucketName := target.Bucket
+
+	object, err := semantic.GetS3ObjectFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to extract S3 object", err)
+	}
+	s3Key := object.Identifier
+	if s3Key == "" {
+		return returnActionError(c, action, "Object identifier (S3 key) is required", nil)
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	operation := stringOption(action, "multipart")
+	uploadID := stringOption(action, "uploadId")
+	if operation != "initiate" && uploadID == "" {
+		return returnActionError(c, action, "uploadId is required", nil)
+	}
+
+	value := map[string]interface{}{
+		"identifier": s3Key,
+		"contentUrl": fmt.Sprintf("s3://%s/%s", bucketName, s3Key),
+		"uploadId":   uploadID,
+	}
+
+	switch operation {
+	case "initiate":
+		input := &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(s3Key),
+		}
+		// There is no content to sniff yet, so only the key's extension can name a type
+		contentType := object.EncodingFormat
+		if contentType == "" {
+			contentType = mime.TypeByExtension(path.Ext(s3Key))
+		}
+		if contentType != "" {
+			input.ContentType = aws.String(contentType)
+		}
+		attrs, err := objectMetadataFromAction(action)
+		if err != nil {
+			return returnActionError(c, action, "Invalid object metadata", err)
+		}
+		attrs.applyToCreate(input)
+		created, err := client.CreateMultipartUpload(ctx, input)
+		if err != nil {
+			return returnActionError(c, action, "Failed to initiate multipart upload", err)
+		}
+		value["uploadId"] = aws.ToString(created.UploadId)
+
+	case "uploadPart":
+		partNumber, err := intOption(action, "partNumber", 0)
+		if err != nil || partNumber < 1 || partNumber > maxUploadParts {
+			return returnActionError(c, action, fmt.Sprintf("partNumber must be between 1 and %d", maxUploadParts), err)
+		}
+		body := requestUploadBody(c)
+		if body == nil {
+			body, err = inlineUploadBody(action)
+			if err != nil {
+				return returnActionError(c, action, "Failed to decode object text", err)
+			}
+		}
+		if body == nil {
+			return returnActionError(c, action, "Part content is required", nil)
+		}
+
+		input := &s3.UploadPartInput{
+			Bucket:        aws.String(bucketName),
+			Key:           aws.String(s3Key),
+			UploadId:      aws.String(uploadID),
+			PartNumber:    aws.Int32(int32(partNumber)),
+			Body:          body.Reader,
+			ContentLength: aws.Int64(body.Size),
+		}
+		var optFns []func(*s3.Options)
+		if body.Stream != nil {
+			input.Body = body.Stream
+			optFns = unseekableBodyOptions(target.Endpoint)
+		}
+		output, err := client.UploadPart(ctx, input, optFns...)
+		if err != nil {
+			return returnActionError(c, action, "Failed to upload part", err)
+		}
+		value["partNumber"] = partNumber
+		value["etag"] = aws.ToString(output.ETag)
+		value["contentSize"] = body.Size
+
+	case "complete":
+		parts, err := partsOption(action)
+		if err != nil {
+			return returnActionError(c, action, "Invalid parts", err)
+		}
+		if len(parts) == 0 {
+			// Without an explicit list, complete with every part S3 received
+			parts, err = listUploadedParts(ctx, client, bucketName, s3Key, uploadID)
+			if err != nil {
+				return returnActionError(c, action, "Failed to list uploaded parts", err)
+			}
+		}
+		completed, err := completeMultipartUpload(ctx, client, bucketName, s3Key, uploadID, sortedParts(parts))
+		if err != nil {
+			return returnActionError(c, action, "Failed to complete multipart upload", err)
+		}
+		value["etag"] = aws.ToString(completed.ETag)
+		value["parts"] = len(parts)
+		if version := aws.ToString(completed.VersionId); version != "" {
+			value["version"] = version
+		}
+
+	case "abort":
+		if err := abortMultipartUpload(ctx, client, bucketName, s3Key, uploadID); err != nil {
+			return returnActionError(c, action, "Failed to abort multipart upload", err)
+		}
+
+	default:
+		return returnActionError(c, action, fmt.Sprintf("Unknown multipart operation %q", operation), nil)
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "DigitalDocument",
+		Format: "application/json",
+		Value:  value,
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
+
+// partsOption reads the "parts" option: a list of {"partNumber": n, "etag": "..."}
+func partsOption(action *semantic.SemanticAction) ([]completedPart, error) {
+	value, ok := actionOption(action, "parts")
+	if !ok {
+		return nil, nil
+	}
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("parts must be a list")
+	}
+
+	parts := make([]completedPart, 0, len(entries))
+	for _, entry := range entries {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid part %v", entry)
+		}
+		number, err := asInt(fields["partNumber"])
+		if err != nil || number < 1 {
+			return nil, fmt.Errorf("invalid partNumber in %v", entry)
+		}
+		etag := asString(fields["etag"])
+		if etag == "" {
+			return nil, fmt.Errorf("missing etag for part %d", number)
+		}
+		parts = append(parts, completedPart{PartNumber: int32(number), ETag: etag})
+	}
+	return parts, nil
+}
+
+// unseekableBodyOptions lets the SDK send a body it cannot rewind. The payload is
+// not signed, and over HTTPS its checksum follows the content as a trailer. Plain
+// HTTP endpoints (local test servers) get no checksum, since computing it upfront
+// would need a second pass over the body.
+func unseekableBodyOptions(endpoint string) []func(*s3.Options) {
+	optFns := []func(*s3.Options){s3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware)}
+	if endpoint != "" && !strings.HasPrefix(strings.ToLower(endpoint), "https://") {
+		optFns = append(optFns, func(o *s3.Options) {
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		})
+	}
+	return optFns
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func testMultipartOptions() multipartOptions {
+	return multipartOptions{PartSize: minPartSize, Concurrency: 3, Threshold: minPartSize}
+}
+
+func testPayload(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 31)
+	}
+	return data
+}
+
+func TestUploadObject_Multipart(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "multipart")
+	client, err := newClientPool(defaultClientPoolConfig).Get(context.Background(), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := testPayload(3*minPartSize + 1234)
+	body := &uploadBody{Reader: bytes.NewReader(data), Size: int64(len(data))}
+
+	result, err := uploadObject(context.Background(), client, "bucket", "big.bin", body, "", testMultipartOptions())
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if !result.Multipart || result.Parts != 4 {
+		t.Errorf("expected 4-part multipart upload, got %+v", result)
+	}
+
+	stored, ok := fake.object("bucket", "big.bin")
+	if !ok || !bytes.Equal(stored, data) {
+		t.Fatalf("stored object does not match upload (%d of %d bytes)", len(stored), len(data))
+	}
+	fake.mu.Lock()
+	checksummed := len(fake.partChecksums)
+	fake.mu.Unlock()
+	if checksummed != 4 {
+		t.Errorf("expected every part to carry a SHA-256, got %d", checksummed)
+	}
+}
+
+func TestUploadObject_Stream(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "stream-upload"))
+
+	for _, tc := range []struct {
+		key       string
+		data      []byte
+		multipart bool
+	}{
+		{"small.bin", testPayload(1000), false},
+		{"big.bin", testPayload(3*minPartSize + 1234), true},
+	} {
+		sum := sha256.Sum256(tc.data)
+		body := &uploadBody{
+			Stream:    io.MultiReader(bytes.NewReader(tc.data)),
+			Size:      int64(len(tc.data)),
+			Checksums: checksumSpec{Expected: map[string][]byte{checksumSHA256: sum[:]}, Compute: []string{checksumSHA256}},
+		}
+		result, err := uploadObject(context.Background(), client, "bucket", tc.key, body, "", testMultipartOptions())
+		if err != nil {
+			t.Fatalf("%s: upload failed: %v", tc.key, err)
+		}
+		if result.Multipart != tc.multipart || !bytes.Equal(result.Checksums[checksumSHA256], sum[:]) {
+			t.Errorf("%s: unexpected result %+v", tc.key, result)
+		}
+		if stored, ok := fake.object("bucket", tc.key); !ok || !bytes.Equal(stored, tc.data) {
+			t.Errorf("%s: stored object does not match the stream", tc.key)
+		}
+	}
+
+	// A stream shorter than its announced size fails and leaves nothing behind
+	short := &uploadBody{Stream: bytes.NewBufferString("too short"), Size: 2 * minPartSize}
+	if _, err := uploadObject(context.Background(), client, "bucket", "short.bin", short, "", testMultipartOptions()); err == nil {
+		t.Error("expected an error for a truncated stream")
+	}
+	if _, ok := fake.object("bucket", "short.bin"); ok {
+		t.Error("a truncated stream must not be stored")
+	}
+}
+
+func TestUploadPart_StreamsUnseekableBody(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, err := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "stream-part"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := client.CreateMultipartUpload(context.Background(), &s3.CreateMultipartUploadInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("streamed.bin"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A request body cannot seek; io.MultiReader hides bytes.Reader's Seek the same way
+	data := testPayload(minPartSize)
+	_, err = client.UploadPart(context.Background(), &s3.UploadPartInput{
+		Bucket:        aws.String("bucket"),
+		Key:           aws.String("streamed.bin"),
+		UploadId:      created.UploadId,
+		PartNumber:    aws.Int32(1),
+		Body:          io.MultiReader(bytes.NewReader(data)),
+		ContentLength: aws.Int64(int64(len(data))),
+	}, unseekableBodyOptions(server.URL)...)
+	if err != nil {
+		t.Fatalf("streamed part failed: %v", err)
+	}
+
+	fake.mu.Lock()
+	stored := fake.uploads[aws.ToString(created.UploadId)][1]
+	fake.mu.Unlock()
+	if !bytes.Equal(stored, data) {
+		t.Errorf("stored part does not match the stream (%d of %d bytes)", len(stored), len(data))
+	}
+}
+
+func TestUploadObject_VerifiesChecksums(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "verify"))
+	wrong := sha256.Sum256([]byte("something else"))
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"small.txt", []byte("hello")},
+		{"big.bin", testPayload(2*minPartSize + 10)},
+	} {
+		// Requested digests are computed while the content is sent
+		want := sha256.Sum256(tc.data)
+		body := &uploadBody{Reader: bytes.NewReader(tc.data), Size: int64(len(tc.data)), Checksums: checksumSpec{Compute: []string{checksumSHA256}}}
+		result, err := uploadObject(context.Background(), client, "bucket", tc.name, body, "", testMultipartOptions())
+		if err != nil {
+			t.Fatalf("%s: upload failed: %v", tc.name, err)
+		}
+		if !bytes.Equal(result.Checksums[checksumSHA256], want[:]) {
+			t.Errorf("%s: unexpected sha256 %x", tc.name, result.Checksums[checksumSHA256])
+		}
+
+		// Content that does not match an expected digest is not kept
+		spec := checksumSpec{Expected: map[string][]byte{checksumMD5: wrong[:16]}, Compute: []string{checksumMD5}}
+		body = &uploadBody{Reader: bytes.NewReader(tc.data), Size: int64(len(tc.data)), Checksums: spec}
+		if _, err := uploadObject(context.Background(), client, "bucket", "wrong-"+tc.name, body, "", testMultipartOptions()); !errors.Is(err, errChecksumMismatch) {
+			t.Errorf("%s: expected a checksum mismatch, got %v", tc.name, err)
+		}
+		if _, ok := fake.object("bucket", "wrong-"+tc.name); ok {
+			t.Errorf("%s: expected the mismatching object not to be kept", tc.name)
+		}
+	}
+}
+
+func TestUploadObject_SmallUsesPutObject(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "small")
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), target)
+
+	data := []byte("hello")
+	body := &uploadBody{Reader: bytes.NewReader(data), Size: int64(len(data))}
+	result, err := uploadObject(context.Background(), client, "bucket", "small.txt", body, "", testMultipartOptions())
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if result.Multipart {
+		t.Error("expected a single PutObject")
+	}
+	if fake.count("POST /bucket/small.txt?uploads") != 0 {
+		t.Error("unexpected multipart initiation")
+	}
+}
+
+func TestUploadObject_ResumesAfterFailure(t *testing.T) {
+	multipartStates = &multipartStore{dir: t.TempDir()}
+
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "resume")
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), target)
+
+	data := testPayload(4 * minPartSize)
+	source := filepath.Join(t.TempDir(), "backup.tar")
+	if err := os.WriteFile(source, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stateKey := multipartStateKey(target, "backup.tar", source, int64(len(data)), time.Unix(1, 0))
+
+	opts := testMultipartOptions()
+	opts.Concurrency = 1
+	fake.failPart = 3
+
+	body := &uploadBody{Reader: bytes.NewReader(data), Size: int64(len(data))}
+	if _, err := uploadObject(context.Background(), client, "bucket", "backup.tar", body, stateKey, opts); err == nil {
+		t.Fatal("expected the first attempt to fail")
+	}
+	if _, ok := multipartStates.Load(stateKey); !ok {
+		t.Fatal("expected resumable state to be kept after failure")
+	}
+
+	result, err := uploadObject(context.Background(), client, "bucket", "backup.tar", body, stateKey, opts)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if result.ResumedParts != 2 {
+		t.Errorf("expected 2 resumed parts, got %d", result.ResumedParts)
+	}
+	if got := fake.count("POST /bucket/backup.tar?uploads"); got != 1 {
+		t.Errorf("expected a single initiation, got %d", got)
+	}
+
+	stored, _ := fake.object("bucket", "backup.tar")
+	if !bytes.Equal(stored, data) {
+		t.Fatal("resumed object does not match source")
+	}
+	if _, ok := multipartStates.Load(stateKey); ok {
+		t.Error("expected state to be removed after completion")
+	}
+}
+
+func TestMultipartOptions_PartSizeFor(t *testing.T) {
+	opts := multipartOptions{PartSize: minPartSize}
+	size := int64(maxUploadParts) * minPartSize * 2
+	if got := opts.partSizeFor(size); got*maxUploadParts < size {
+		t.Errorf("part size %d cannot fit %d bytes in %d parts", got, size, maxUploadParts)
+	}
+	if got := opts.partSizeFor(10 * minPartSize); got != minPartSize {
+		t.Errorf("expected configured part size, got %d", got)
+	}
+}
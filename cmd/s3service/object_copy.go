@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ============================================================================
+// Object Copy
+// ============================================================================
+
+const (
+	maxCopyObjectSize = 5 << 30   // largest object a single CopyObject can copy
+	copyPartSize      = 512 << 20 // part size for server-side UploadPartCopy
+)
+
+// copyOptions controls what a copy carries over from the source object
+type copyOptions struct {
+	// Metadata replaces the source's user metadata when non-nil
+	Metadata map[string]string
+	// ContentType replaces the source's content type when set
+	ContentType string
+	// CacheControl, ContentDisposition and ContentEncoding replace stored headers when set
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	// MergeMetadata merges Metadata into the source's metadata instead of replacing it
+	MergeMetadata bool
+	// Tags replace the source's tags when non-nil
+	Tags         map[string]string
+	DeleteSource bool
+}
+
+// copyOptionsFromAction reads metadata, contentType, tags and deleteSource options.
+// metadataDirective/taggingDirective "REPLACE" without values clears metadata or tags.
+// MoveAction always deletes the source.
+func copyOptionsFromAction(action *semantic.SemanticAction) (copyOptions, error) {
+	opts := copyOptions{
+		ContentType:  stringOption(action, "contentType"),
+		DeleteSource: actionType(action) == "MoveAction" || boolOption(action, "deleteSource"),
+	}
+
+	metadata, ok, err := stringMapOption(action, "metadata")
+	if err != nil {
+		return copyOptions{}, err
+	}
+	if ok {
+		opts.Metadata = metadata
+	} else if strings.EqualFold(stringOption(action, "metadataDirective"), "REPLACE") {
+		opts.Metadata = map[string]string{}
+	}
+
+	tags, ok, err := stringMapOption(action, "tags")
+	if err != nil {
+		return copyOptions{}, err
+	}
+	if ok {
+		opts.Tags = tags
+	} else if strings.EqualFold(stringOption(action, "taggingDirective"), "REPLACE") {
+		opts.Tags = map[string]string{}
+	}
+
+	return opts, nil
+}
+
+// replacesHeaders reports whether the copy changes stored headers or metadata
+func (o copyOptions) replacesHeaders() bool {
+	return o.Metadata != nil || o.ContentType != "" || o.CacheControl != "" ||
+		o.ContentDisposition != "" || o.ContentEncoding != ""
+}
+
+// copyResult describes a finished copy
+type copyResult struct {
+	Size          int64
+	ETag          string
+	VersionID     string // version of the destination object, when versioned
+	Method        string // CopyObject, UploadPartCopy or stream
+	Parts         int
+	DeletedSource bool
+}
+
+// objectCopy copies one object between two storage targets. Targets on the same
+// service are copied server-side; otherwise the content streams through the
+// service in bounded part-sized buffers without touching disk. A non-empty
+// srcVersion copies that version of the source instead of the latest.
+type objectCopy struct {
+	src, dst             *storageTarget
+	srcClient, dstClient *s3.Client
+	srcKey, dstKey       string
+	srcVersion           string
+	opts                 copyOptions
+	parts                multipartOptions
+}
+
+func (o *objectCopy) run(ctx context.Context) (*copyResult, error) {
+	head, err := o.srcClient.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:    aws.String(o.src.Bucket),
+		Key:       aws.String(o.srcKey),
+		VersionId: optionalString(o.srcVersion),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source object: %w", err)
+	}
+
+	var result *copyResult
+	switch size := aws.ToInt64(head.ContentLength); {
+	case o.src.sameService(o.dst) && size <= maxCopyObjectSize:
+		result, err = o.copyObject(ctx, head)
+	case o.src.sameService(o.dst):
+		result, err = o.copyParts(ctx, head)
+	default:
+		result, err = o.streamCopy(ctx, head)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if o.opts.DeleteSource {
+		_, err := o.srcClient.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket:    aws.String(o.src.Bucket),
+			Key:       aws.String(o.srcKey),
+			VersionId: optionalString(o.srcVersion),
+		})
+		if err != nil {
+			return result, fmt.Errorf("object copied but failed to delete source: %w", err)
+		}
+		result.DeletedSource = true
+	}
+	return result, nil
+}
+
+// copyObject copies up to 5 GiB with a single server-side CopyObject
+func (o *objectCopy) copyObject(ctx context.Context, head *s3.HeadObjectOutput) (*copyResult, error) {
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(o.dst.Bucket),
+		Key:               aws.String(o.dstKey),
+		CopySource:        aws.String(copySource(o.src.Bucket, o.srcKey, o.srcVersion)),
+		CopySourceIfMatch: head.ETag,
+	}
+	if o.opts.replacesHeaders() {
+		// REPLACE drops every stored header, so carry over the ones not being changed
+		attrs := o.attributes(head)
+		input.MetadataDirective = types.MetadataDirectiveReplace
+		input.Metadata = attrs.Metadata
+		input.ContentType = attrs.ContentType
+		input.CacheControl = attrs.CacheControl
+		input.ContentDisposition = attrs.ContentDisposition
+		input.ContentEncoding = attrs.ContentEncoding
+	}
+	if o.opts.Tags != nil {
+		input.TaggingDirective = types.TaggingDirectiveReplace
+		input.Tagging = aws.String(encodeTags(o.opts.Tags))
+	}
+
+	output, err := o.dstClient.CopyObject(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy object: %w", err)
+	}
+
+	etag := ""
+	if output.CopyObjectResult != nil {
+		etag = aws.ToString(output.CopyObjectResult.ETag)
+	}
+	return &copyResult{Size: aws.ToInt64(head.ContentLength), ETag: etag, VersionID: aws.ToString(output.VersionId), Method: "CopyObject"}, nil
+}
+
+// copyParts copies objects over 5 GiB server-side with UploadPartCopy
+func (o *objectCopy) copyParts(ctx context.Context, head *s3.HeadObjectOutput) (*copyResult, error) {
+	size := aws.ToInt64(head.ContentLength)
+	partSize := multipartOptions{PartSize: copyPartSize}.partSizeFor(size)
+	source := copySource(o.src.Bucket, o.srcKey, o.srcVersion)
+
+	return o.multipartCopy(ctx, head, partSize, "UploadPartCopy", func(ctx context.Context, uploadID string, number int32, r byteRange) (string, error) {
+		output, err := o.dstClient.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:            aws.String(o.dst.Bucket),
+			Key:               aws.String(o.dstKey),
+			UploadId:          aws.String(uploadID),
+			PartNumber:        aws.Int32(number),
+			CopySource:        aws.String(source),
+			CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", r.Start, r.End)),
+			CopySourceIfMatch: head.ETag,
+		})
+		if err != nil {
+			return "", err
+		}
+		if output.CopyPartResult == nil {
+			return "", fmt.Errorf("missing copy result")
+		}
+		return aws.ToString(output.CopyPartResult.ETag), nil
+	})
+}
+
+// streamCopy copies between services by reading ranges from the source and
+// uploading them to the destination. At most one part per worker is held in memory.
+func (o *objectCopy) streamCopy(ctx context.Context, head *s3.HeadObjectOutput) (*copyResult, error) {
+	size := aws.ToInt64(head.ContentLength)
+	partOpts := o.parts
+	partOpts.PartSize = o.parts.partSizeFor(size)
+	downloader := newRangedDownloader(o.srcClient, o.src.Bucket, o.srcKey, o.srcVersion, size, aws.ToString(head.ETag), partOpts)
+
+	if size <= partOpts.PartSize {
+		var buf bytes.Buffer
+		if size > 0 {
+			if err := downloader.fetchRange(ctx, byteRange{Start: 0, End: size - 1}, &buf); err != nil {
+				return nil, fmt.Errorf("failed to read source object: %w", err)
+			}
+		}
+
+		attrs, err := o.streamAttributes(ctx, head)
+		if err != nil {
+			return nil, err
+		}
+		input := &s3.PutObjectInput{
+			Bucket:        aws.String(o.dst.Bucket),
+			Key:           aws.String(o.dstKey),
+			Body:          bytes.NewReader(buf.Bytes()),
+			ContentLength: aws.Int64(size),
+		}
+		attrs.applyToPut(input)
+		output, err := o.dstClient.PutObject(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write destination object: %w", err)
+		}
+		return &copyResult{Size: size, ETag: aws.ToString(output.ETag), VersionID: aws.ToString(output.VersionId), Method: "stream"}, nil
+	}
+
+	return o.multipartCopy(ctx, head, partOpts.PartSize, "stream", func(ctx context.Context, uploadID string, number int32, r byteRange) (string, error) {
+		var buf bytes.Buffer
+		if err := downloader.fetchRange(ctx, r, &buf); err != nil {
+			return "", err
+		}
+		output, err := o.dstClient.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(o.dst.Bucket),
+			Key:           aws.String(o.dstKey),
+			UploadId:      aws.String(uploadID),
+			PartNumber:    aws.Int32(number),
+			Body:          bytes.NewReader(buf.Bytes()),
+			ContentLength: aws.Int64(int64(buf.Len())),
+		})
+		if err != nil {
+			return "", err
+		}
+		return aws.ToString(output.ETag), nil
+	})
+}
+
+// copyPartFunc transfers one part of a multipart copy and returns its ETag
+type copyPartFunc func(ctx context.Context, uploadID string, number int32, r byteRange) (string, error)
+
+// multipartCopy creates a destination upload, transfers every part with copyPart
+// using the configured concurrency, and completes it. The upload is aborted on failure.
+func (o *objectCopy) multipartCopy(ctx context.Context, head *s3.HeadObjectOutput, partSize int64, method string, copyPart copyPartFunc) (*copyResult, error) {
+	attrs, err := o.streamAttributes(ctx, head)
+	if err != nil {
+		return nil, err
+	}
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(o.dst.Bucket),
+		Key:    aws.String(o.dstKey),
+	}
+	attrs.applyToCreate(input)
+	created, err := o.dstClient.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate multipart copy: %w", err)
+	}
+	uploadID := aws.ToString(created.UploadId)
+
+	size := aws.ToInt64(head.ContentLength)
+	parts, err := forEachPart(ctx, o.parts.Concurrency, size, partSize, func(ctx context.Context, number int32, r byteRange) (string, error) {
+		return copyPart(ctx, uploadID, number, r)
+	})
+	if err == nil {
+		var completed *s3.CompleteMultipartUploadOutput
+		completed, err = completeMultipartUpload(ctx, o.dstClient, o.dst.Bucket, o.dstKey, uploadID, parts)
+		if err == nil {
+			return &copyResult{Size: size, ETag: aws.ToString(completed.ETag), VersionID: aws.ToString(completed.VersionId), Method: method, Parts: len(parts)}, nil
+		}
+	}
+
+	// Release stored parts with a fresh context since ctx may be cancelled
+	abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_ = abortMultipartUpload(abortCtx, o.dstClient, o.dst.Bucket, o.dstKey, uploadID)
+	return nil, fmt.Errorf("multipart copy failed: %w", err)
+}
+
+// forEachPart runs fn for every part-sized range of an object with up to concurrency
+// workers and returns the parts ordered by number
+func forEachPart(ctx context.Context, concurrency int, size, partSize int64, fn func(ctx context.Context, number int32, r byteRange) (string, error)) ([]completedPart, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int32)
+	errs := make(chan error, concurrency)
+	var mu sync.Mutex
+	var parts []completedPart
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range jobs {
+				r := byteRange{Start: int64(number-1) * partSize}
+				r.End = r.Start + partSize - 1
+				if r.End > size-1 {
+					r.End = size - 1
+				}
+				etag, err := fn(ctx, number, r)
+				if err != nil {
+					errs <- fmt.Errorf("part %d: %w", number, err)
+					cancel()
+					return
+				}
+				mu.Lock()
+				parts = append(parts, completedPart{PartNumber: number, ETag: etag, Size: r.End - r.Start + 1})
+				mu.Unlock()
+			}
+		}()
+	}
+
+	partCount := int32((size + partSize - 1) / partSize)
+feed:
+	for number := int32(1); number <= partCount; number++ {
+		select {
+		case jobs <- number:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return sortedParts(parts), nil
+}
+
+// objectAttributes are the stored headers, metadata and tags written with an upload or copy
+type objectAttributes struct {
+	ContentType        *string
+	CacheControl       *string
+	ContentDisposition *string
+	ContentEncoding    *string
+	Metadata           map[string]string
+	Tagging            *string
+}
+
+// attributes merges the source object's headers and metadata with the replacements in opts
+func (o *objectCopy) attributes(head *s3.HeadObjectOutput) objectAttributes {
+	attrs := headAttributes(head)
+	if o.opts.ContentType != "" {
+		attrs.ContentType = aws.String(o.opts.ContentType)
+	}
+	if o.opts.CacheControl != "" {
+		attrs.CacheControl = aws.String(o.opts.CacheControl)
+	}
+	if o.opts.ContentDisposition != "" {
+		attrs.ContentDisposition = aws.String(o.opts.ContentDisposition)
+	}
+	if o.opts.ContentEncoding != "" {
+		attrs.ContentEncoding = aws.String(o.opts.ContentEncoding)
+	}
+	switch {
+	case o.opts.MergeMetadata:
+		// Merged values overwrite stored ones; an empty value removes the entry
+		merged := map[string]string{}
+		for name, value := range head.Metadata {
+			merged[strings.ToLower(name)] = value
+		}
+		for name, value := range o.opts.Metadata {
+			if value == "" {
+				delete(merged, name)
+			} else {
+				merged[name] = value
+			}
+		}
+		attrs.Metadata = merged
+	case o.opts.Metadata != nil:
+		attrs.Metadata = o.opts.Metadata
+	}
+	return attrs
+}
+
+// streamAttributes returns the attributes for a new destination upload. Unlike
+// CopyObject, uploads do not inherit tags, so the source tags are read and carried over.
+func (o *objectCopy) streamAttributes(ctx context.Context, head *s3.HeadObjectOutput) (objectAttributes, error) {
+	attrs := o.attributes(head)
+	tags := o.opts.Tags
+	if tags == nil {
+		stored, err := getObjectTags(ctx, o.srcClient, o.src.Bucket, o.srcKey, o.srcVersion)
+		switch {
+		case err == nil:
+			tags = stored
+		case s3StatusCode(err) == http.StatusNotImplemented:
+			// Some S3-compatible stores have no tagging; there is nothing to carry over
+		default:
+			return objectAttributes{}, fmt.Errorf("failed to read source tags: %w", err)
+		}
+	}
+	if len(tags) > 0 {
+		attrs.Tagging = aws.String(encodeTags(tags))
+	}
+	return attrs, nil
+}
+
+// copySource formats the x-amz-copy-source value for an object or one of its versions
+func copySource(bucketName, key, versionID string) string {
+	source := bucketName + "/" + url.PathEscape(key)
+	if versionID != "" {
+		source += "?versionId=" + url.QueryEscape(versionID)
+	}
+	return source
+}
+
+// encodeTags renders tags as the URL query string S3 expects in x-amz-tagging
+func encodeTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := url.Values{}
+	for _, key := range keys {
+		values.Set(key, tags[key])
+	}
+	return values.Encode()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func newTestCopy(t *testing.T, src, dst *storageTarget, srcKey, dstKey string) *objectCopy {
+	t.Helper()
+	pool := newClientPool(defaultClientPoolConfig)
+	srcClient, err := pool.Get(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstClient, err := pool.Get(context.Background(), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &objectCopy{
+		src:       src,
+		dst:       dst,
+		srcClient: srcClient,
+		dstClient: dstClient,
+		srcKey:    srcKey,
+		dstKey:    dstKey,
+		parts:     testMultipartOptions(),
+	}
+}
+
+func TestObjectCopy_ServerSide(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "copy")
+	fake.objects["bucket/reports/2024 q1.csv"] = []byte("a,b,c")
+
+	dst := *target
+	dst.Bucket = "archive"
+	result, err := newTestCopy(t, target, &dst, "reports/2024 q1.csv", "2024/q1.csv").run(context.Background())
+	if err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	if result.Method != "CopyObject" || result.DeletedSource {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if copied, _ := fake.object("archive", "2024/q1.csv"); string(copied) != "a,b,c" {
+		t.Errorf("copied content %q", copied)
+	}
+	if _, ok := fake.object("bucket", "reports/2024 q1.csv"); !ok {
+		t.Error("expected the source to be kept")
+	}
+}
+
+func TestObjectCopy_MoveDeletesSource(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "move")
+	fake.objects["bucket/old.txt"] = []byte("data")
+
+	move := newTestCopy(t, target, target, "old.txt", "new.txt")
+	move.opts.DeleteSource = true
+	result, err := move.run(context.Background())
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if !result.DeletedSource {
+		t.Error("expected the source to be deleted")
+	}
+	if _, ok := fake.object("bucket", "old.txt"); ok {
+		t.Error("source still exists")
+	}
+	if moved, _ := fake.object("bucket", "new.txt"); string(moved) != "data" {
+		t.Errorf("moved content %q", moved)
+	}
+}
+
+func TestObjectCopy_UploadPartCopy(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "part-copy")
+	data := testPayload(3 * minPartSize)
+	fake.objects["bucket/big.bin"] = data
+
+	copier := newTestCopy(t, target, target, "big.bin", "big-copy.bin")
+	head, err := copier.srcClient.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("big.bin"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := copier.copyParts(context.Background(), head)
+	if err != nil {
+		t.Fatalf("part copy failed: %v", err)
+	}
+	if result.Method != "UploadPartCopy" {
+		t.Errorf("unexpected method %s", result.Method)
+	}
+	if copied, _ := fake.object("bucket", "big-copy.bin"); !bytes.Equal(copied, data) {
+		t.Error("copied content does not match source")
+	}
+}
+
+func TestObjectCopy_CrossServiceStreams(t *testing.T) {
+	srcFake, srcServer := newFakeS3Server(t)
+	dstFake, dstServer := newFakeS3Server(t)
+	data := testPayload(3*minPartSize + 5)
+	srcFake.objects["bucket/big.bin"] = data
+
+	copier := newTestCopy(t, fakeTarget(srcServer.URL, "src"), fakeTarget(dstServer.URL, "dst"), "big.bin", "big.bin")
+	result, err := copier.run(context.Background())
+	if err != nil {
+		t.Fatalf("stream copy failed: %v", err)
+	}
+	if result.Method != "stream" || result.Parts != 4 {
+		t.Errorf("expected a 4-part streamed copy, got %+v", result)
+	}
+	if copied, _ := dstFake.object("bucket", "big.bin"); !bytes.Equal(copied, data) {
+		t.Error("copied content does not match source")
+	}
+}
+
+func TestEncodeTags(t *testing.T) {
+	got := encodeTags(map[string]string{"team": "data ops", "env": "prod"})
+	if got != "env=prod&team=data+ops" {
+		t.Errorf("encodeTags = %q", got)
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/labstack/echo/v4"
+)
+
+// maxByteRanges bounds the ranges of a multi-range request, each of which is a separate
+// S3 request; a Range header with more is ignored and the whole object is sent
+const maxByteRanges = 16
+
+// downloadConditions carries the Range and conditional request headers of a download,
+// and the object version to read
+type downloadConditions struct {
+	VersionID         string
+	Range             string
+	IfMatch           string
+	IfNoneMatch       string
+	IfModifiedSince   time.Time
+	IfUnmodifiedSince time.Time
+}
+
+// byteRange is a resolved, inclusive byte range of an object
+type byteRange struct {
+	Start int64
+	End   int64
+}
+
+// downloadConditionsFromRequest reads Range and If-* headers from the HTTP request.
+// Semantic callers can set the same values as action options (range, ifMatch, ...).
+func downloadConditionsFromRequest(c echo.Context, action *semantic.SemanticAction) downloadConditions {
+	header := c.Request().Header
+	conditions := downloadConditions{
+		VersionID:         versionIDOption(action),
+		Range:             header.Get("Range"),
+		IfMatch:           header.Get("If-Match"),
+		IfNoneMatch:       header.Get("If-None-Match"),
+		IfModifiedSince:   httpTime(header.Get("If-Modified-Since")),
+		IfUnmodifiedSince: httpTime(header.Get("If-Unmodified-Since")),
+	}
+
+	if value := stringOption(action, "range"); value != "" {
+		conditions.Range = value
+	}
+	if value := stringOption(action, "ifMatch"); value != "" {
+		conditions.IfMatch = value
+	}
+	if value := stringOption(action, "ifNoneMatch"); value != "" {
+		conditions.IfNoneMatch = value
+	}
+	if value := stringOption(action, "ifModifiedSince"); value != "" {
+		conditions.IfModifiedSince = httpTime(value)
+	}
+	if value := stringOption(action, "ifUnmodifiedSince"); value != "" {
+		conditions.IfUnmodifiedSince = httpTime(value)
+	}
+
+	return conditions
+}
+
+// applyToGet sets the conditions on a GetObject request
+func (d downloadConditions) applyToGet(input *s3.GetObjectInput) {
+	input.VersionId = optionalString(d.VersionID)
+	if d.Range != "" {
+		input.Range = aws.String(d.Range)
+	}
+	if d.IfMatch != "" {
+		input.IfMatch = aws.String(d.IfMatch)
+	}
+	if d.IfNoneMatch != "" {
+		input.IfNoneMatch = aws.String(d.IfNoneMatch)
+	}
+	if !d.IfModifiedSince.IsZero() {
+		input.IfModifiedSince = aws.Time(d.IfModifiedSince)
+	}
+	if !d.IfUnmodifiedSince.IsZero() {
+		input.IfUnmodifiedSince = aws.Time(d.IfUnmodifiedSince)
+	}
+}
+
+// applyToHead sets the conditional part of the conditions on a HeadObject request
+func (d downloadConditions) applyToHead(input *s3.HeadObjectInput) {
+	input.VersionId = optionalString(d.VersionID)
+	if d.IfMatch != "" {
+		input.IfMatch = aws.String(d.IfMatch)
+	}
+	if d.IfNoneMatch != "" {
+		input.IfNoneMatch = aws.String(d.IfNoneMatch)
+	}
+	if !d.IfModifiedSince.IsZero() {
+		input.IfModifiedSince = aws.Time(d.IfModifiedSince)
+	}
+	if !d.IfUnmodifiedSince.IsZero() {
+		input.IfUnmodifiedSince = aws.Time(d.IfUnmodifiedSince)
+	}
+}
+
+// streamObject writes an S3 object straight to the HTTP response without staging it on disk.
+// It honors Range (single and multi-range), If-Match, If-None-Match, If-Modified-Since and
+// If-Unmodified-Since, and answers HEAD requests with metadata only.
+// The action option "disposition" selects "attachment" (default) or "inline".
+func streamObject(ctx context.Context, c echo.Context, action *semantic.SemanticAction, client *s3.Client, bucketName, s3Key, fallbackType string) error {
+	conditions := downloadConditionsFromRequest(c, action)
+	disposition := contentDisposition(stringOption(action, "disposition"), path.Base(s3Key))
+
+	if c.Request().Method == http.MethodHead {
+		return headObject(ctx, c, action, client, bucketName, s3Key, fallbackType, disposition, conditions)
+	}
+	if strings.Contains(conditions.Range, ",") {
+		if strings.Count(conditions.Range, ",") < maxByteRanges {
+			return streamObjectRanges(ctx, c, action, client, bucketName, s3Key, fallbackType, disposition, conditions)
+		}
+		conditions.Range = ""
+	}
+
+	// Whole-object downloads of large objects are fetched as parallel ranges. The HEAD
+	// that finds their size also supplies their headers and stored checksums.
+	if conditions.Range == "" {
+		opts, err := multipartOptionsFromAction(action)
+		if err != nil {
+			return returnActionError(c, action, "Invalid download options", err)
+		}
+		spec, err := checksumSpecFromAction(action)
+		if err != nil {
+			return returnActionError(c, action, "Invalid checksum", err)
+		}
+		headInput := &s3.HeadObjectInput{
+			Bucket:       aws.String(bucketName),
+			Key:          aws.String(s3Key),
+			ChecksumMode: types.ChecksumModeEnabled,
+		}
+		conditions.applyToHead(headInput)
+
+		head, err := client.HeadObject(ctx, headInput)
+		if err != nil {
+			if status := conditionalStatus(err); status != 0 {
+				return c.NoContent(status)
+			}
+			return returnActionError(c, action, "Failed to download file", err)
+		}
+		if aws.ToInt64(head.ContentLength) >= opts.Threshold {
+			return streamObjectParallel(ctx, c, client, bucketName, s3Key, conditions.VersionID, fallbackType, disposition, head, opts, spec)
+		}
+	}
+
+	// With checksum mode the SDK validates full-object bodies against the stored checksum
+	input := &s3.GetObjectInput{
+		Bucket:       aws.String(bucketName),
+		Key:          aws.String(s3Key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	}
+	conditions.applyToGet(input)
+
+	result, err := client.GetObject(ctx, input)
+	if err != nil {
+		if status := conditionalStatus(err); status != 0 {
+			return c.NoContent(status)
+		}
+		return returnActionError(c, action, "Failed to download file", err)
+	}
+	defer func() { _ = result.Body.Close() }()
+
+	header := c.Response().Header()
+	setObjectHeaders(header, aws.ToString(result.ContentType), fallbackType, result.ETag, result.LastModified, disposition)
+	setStoredHeaders(header, result.VersionId, result.CacheControl, result.ContentEncoding, result.Metadata)
+	if result.ContentLength != nil {
+		header.Set(echo.HeaderContentLength, strconv.FormatInt(*result.ContentLength, 10))
+	}
+
+	status := http.StatusOK
+	if result.ContentRange != nil {
+		header.Set("Content-Range", *result.ContentRange)
+		status = http.StatusPartialContent
+	}
+
+	c.Response().WriteHeader(status)
+	if _, err := io.Copy(c.Response(), result.Body); err != nil {
+		// Headers are already sent, so the client sees a truncated body
+		return fmt.Errorf("failed to stream s3://%s/%s: %w", bucketName, s3Key, err)
+	}
+	return nil
+}
+
+// headObject answers a HEAD request with the object's headers and no body
+func headObject(ctx context.Context, c echo.Context, action *semantic.SemanticAction, client *s3.Client, bucketName, s3Key, fallbackType, disposition string, conditions downloadConditions) error {
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(s3Key),
+	}
+	conditions.applyToHead(input)
+
+	result, err := client.HeadObject(ctx, input)
+	if err != nil {
+		if status := conditionalStatus(err); status != 0 {
+			return c.NoContent(status)
+		}
+		if s3StatusCode(err) == http.StatusNotFound {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return returnActionError(c, action, "Failed to read object metadata", err)
+	}
+
+	header := c.Response().Header()
+	setObjectHeaders(header, aws.ToString(result.ContentType), fallbackType, result.ETag, result.LastModified, disposition)
+	setStoredHeaders(header, result.VersionId, result.CacheControl, result.ContentEncoding, result.Metadata)
+	if result.ContentLength != nil {
+		header.Set(echo.HeaderContentLength, strconv.FormatInt(*result.ContentLength, 10))
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// streamObjectRanges serves a multi-range request as multipart/byteranges.
+// S3 only supports a single range per GetObject, so each range is fetched separately
+// and pinned to the ETag seen by the initial HeadObject.
+func streamObjectRanges(ctx context.Context, c echo.Context, action *semantic.SemanticAction, client *s3.Client, bucketName, s3Key, fallbackType, disposition string, conditions downloadConditions) error {
+	headInput := &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(s3Key),
+	}
+	conditions.applyToHead(headInput)
+
+	head, err := client.HeadObject(ctx, headInput)
+	if err != nil {
+		if status := conditionalStatus(err); status != 0 {
+			return c.NoContent(status)
+		}
+		return returnActionError(c, action, "Failed to read object metadata", err)
+	}
+
+	size := aws.ToInt64(head.ContentLength)
+	ranges, err := parseByteRanges(conditions.Range, size)
+	if err != nil {
+		c.Response().Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		return c.NoContent(http.StatusRequestedRangeNotSatisfiable)
+	}
+
+	contentType := aws.ToString(head.ContentType)
+	if contentType == "" {
+		contentType = fallbackType
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	body := multipart.NewWriter(c.Response())
+	header := c.Response().Header()
+	setObjectHeaders(header, contentType, fallbackType, head.ETag, head.LastModified, disposition)
+	// The multipart envelope is not encoded, so Content-Encoding would mislabel it
+	setStoredHeaders(header, head.VersionId, head.CacheControl, nil, head.Metadata)
+	header.Set(echo.HeaderContentType, "multipart/byteranges; boundary="+body.Boundary())
+	c.Response().WriteHeader(http.StatusPartialContent)
+
+	for _, r := range ranges {
+		result, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket:    aws.String(bucketName),
+			Key:       aws.String(s3Key),
+			VersionId: optionalString(conditions.VersionID),
+			Range:     aws.String(fmt.Sprintf("bytes=%d-%d", r.Start, r.End)),
+			IfMatch:   head.ETag,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to fetch range %d-%d of s3://%s/%s: %w", r.Start, r.End, bucketName, s3Key, err)
+		}
+
+		part, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  {contentType},
+			"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, size)},
+		})
+		if err == nil {
+			_, err = io.Copy(part, result.Body)
+		}
+		_ = result.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to stream range %d-%d of s3://%s/%s: %w", r.Start, r.End, bucketName, s3Key, err)
+		}
+	}
+
+	return body.Close()
+}
+
+// streamObjectParallel streams a large object in order from concurrent ranged reads.
+// Memory stays bounded by the read-ahead window instead of the object size. The
+// content is hashed on the way and checked against spec and the checksums S3 stores
+// for the object; the headers are sent by then, so a mismatch fails the action and is
+// logged but the client only sees it if it checks the content itself.
+func streamObjectParallel(ctx context.Context, c echo.Context, client *s3.Client, bucketName, s3Key, versionID, fallbackType, disposition string, head *s3.HeadObjectOutput, opts multipartOptions, spec checksumSpec) error {
+	size := aws.ToInt64(head.ContentLength)
+	downloader := newRangedDownloader(client, bucketName, s3Key, versionID, size, aws.ToString(head.ETag), opts)
+	spec.expectStored(head)
+	hashes := newChecksumWriter(spec.Compute)
+
+	header := c.Response().Header()
+	setObjectHeaders(header, aws.ToString(head.ContentType), fallbackType, head.ETag, head.LastModified, disposition)
+	setStoredHeaders(header, head.VersionId, head.CacheControl, head.ContentEncoding, head.Metadata)
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(size, 10))
+	c.Response().WriteHeader(http.StatusOK)
+
+	if _, err := downloader.streamTo(ctx, io.MultiWriter(c.Response(), hashes)); err != nil {
+		// Headers are already sent, so the client sees a truncated body
+		return fmt.Errorf("failed to stream s3://%s/%s: %w", bucketName, s3Key, err)
+	}
+	if err := spec.verify(hashes.sums()); err != nil {
+		c.Logger().Errorf("Streamed s3://%s/%s does not match its checksum: %v", bucketName, s3Key, err)
+		return fmt.Errorf("streamed s3://%s/%s: %w", bucketName, s3Key, err)
+	}
+	return nil
+}
+
+// setObjectHeaders sets the representation headers shared by GET and HEAD responses
+func setObjectHeaders(header http.Header, contentType, fallbackType string, etag *string, lastModified *time.Time, disposition string) {
+	if contentType == "" {
+		contentType = fallbackType
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set("Accept-Ranges", "bytes")
+	if etag != nil {
+		header.Set("ETag", *etag)
+	}
+	if lastModified != nil {
+		header.Set(echo.HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+	}
+	header.Set(echo.HeaderContentDisposition, disposition)
+}
+
+// parseByteRanges resolves an HTTP Range header ("bytes=0-99,200-,-500") against an object size
+func parseByteRanges(header string, size int64) ([]byteRange, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok {
+		return nil, fmt.Errorf("unsupported range unit in %q", header)
+	}
+
+	var ranges []byteRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		startText, endText, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid range %q", part)
+		}
+
+		var r byteRange
+		switch {
+		case startText == "":
+			// Suffix range: the last N bytes
+			n, err := strconv.ParseInt(endText, 10, 64)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+			if n > size {
+				n = size
+			}
+			r = byteRange{Start: size - n, End: size - 1}
+		default:
+			start, err := strconv.ParseInt(startText, 10, 64)
+			if err != nil || start < 0 {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+			end := size - 1
+			if endText != "" {
+				end, err = strconv.ParseInt(endText, 10, 64)
+				if err != nil || end < start {
+					return nil, fmt.Errorf("invalid range %q", part)
+				}
+				if end > size-1 {
+					end = size - 1
+				}
+			}
+			r = byteRange{Start: start, End: end}
+		}
+
+		// Unsatisfiable ranges are skipped; the request fails only if none remain
+		if r.Start >= size || r.Start > r.End {
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no satisfiable range in %q", header)
+	}
+	return mergeByteRanges(ranges), nil
+}
+
+// mergeByteRanges sorts ranges and coalesces overlapping and adjacent ones, so no
+// byte is fetched twice
+func mergeByteRanges(ranges []byteRange) []byteRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.Start > last.End+1 {
+			merged = append(merged, r)
+			continue
+		}
+		if r.End > last.End {
+			last.End = r.End
+		}
+	}
+	return merged
+}
+
+// s3StatusCode returns the HTTP status code of an S3 error, or 0 when unknown
+func s3StatusCode(err error) int {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode()
+	}
+	return 0
+}
+
+// conditionalStatus maps S3 precondition and range errors to the status the client expects
+func conditionalStatus(err error) int {
+	switch status := s3StatusCode(err); status {
+	case http.StatusNotModified, http.StatusPreconditionFailed, http.StatusRequestedRangeNotSatisfiable:
+		return status
+	default:
+		return 0
+	}
+}
+
+// contentDisposition builds a Content-Disposition header value for a file name
+func contentDisposition(disposition, filename string) string {
+	if disposition != "inline" {
+		disposition = "attachment"
+	}
+	return mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+}
+
+// httpTime parses an HTTP date (or RFC 3339) value, returning the zero time when absent or invalid
+func httpTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseByteRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		size    int64
+		want    []byteRange
+		wantErr bool
+	}{
+		{name: "single", header: "bytes=0-99", size: 1000, want: []byteRange{{0, 99}}},
+		{name: "open ended", header: "bytes=900-", size: 1000, want: []byteRange{{900, 999}}},
+		{name: "suffix", header: "bytes=-100", size: 1000, want: []byteRange{{900, 999}}},
+		{name: "suffix larger than object", header: "bytes=-5000", size: 1000, want: []byteRange{{0, 999}}},
+		{name: "end clamped", header: "bytes=500-5000", size: 1000, want: []byteRange{{500, 999}}},
+		{name: "multi", header: "bytes=0-9, 20-29", size: 100, want: []byteRange{{0, 9}, {20, 29}}},
+		{name: "merged", header: "bytes=20-29,0-9,5-14,30-39", size: 100, want: []byteRange{{0, 14}, {20, 39}}},
+		{name: "unsatisfiable skipped", header: "bytes=0-9,500-600", size: 100, want: []byteRange{{0, 9}}},
+		{name: "all unsatisfiable", header: "bytes=500-600", size: 100, wantErr: true},
+		{name: "wrong unit", header: "items=0-1", size: 100, wantErr: true},
+		{name: "reversed", header: "bytes=10-5", size: 100, wantErr: true},
+		{name: "garbage", header: "bytes=abc", size: 100, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseByteRanges(tt.header, tt.size)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	if got := contentDisposition("", "report.pdf"); got != "attachment; filename=report.pdf" {
+		t.Errorf("unexpected attachment header %q", got)
+	}
+	if got := contentDisposition("inline", "image.png"); got != "inline; filename=image.png" {
+		t.Errorf("unexpected inline header %q", got)
+	}
+}
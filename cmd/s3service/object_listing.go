@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// maxListAllObjects caps how many entries an "allPages" listing collects in memory
+const maxListAllObjects = 100000
+
+// listOptions are the SearchAction paging options for ListObjectsV2
+type listOptions struct {
+	Prefix            string
+	Delimiter         string
+	StartAfter        string
+	ContinuationToken string
+	MaxKeys           int32
+	AllPages          bool
+}
+
+// listPage is one page (or, with AllPages, the concatenation of all pages) of a listing
+type listPage struct {
+	Objects               []interface{}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// input builds the ListObjectsV2 request for the options
+func (o listOptions) input(bucketName string) *s3.ListObjectsV2Input {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+	}
+	if o.Prefix != "" {
+		input.Prefix = aws.String(o.Prefix)
+	}
+	if o.Delimiter != "" {
+		input.Delimiter = aws.String(o.Delimiter)
+	}
+	if o.StartAfter != "" {
+		input.StartAfter = aws.String(o.StartAfter)
+	}
+	if o.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(o.ContinuationToken)
+	}
+	if o.MaxKeys > 0 {
+		input.MaxKeys = aws.Int32(o.MaxKeys)
+	}
+	return input
+}
+
+// listObjects lists one page, or every page when AllPages is set
+func listObjects(ctx context.Context, client *s3.Client, bucketName string, opts listOptions) (*listPage, error) {
+	page := &listPage{Objects: []interface{}{}}
+
+	if !opts.AllPages {
+		result, err := client.ListObjectsV2(ctx, opts.input(bucketName))
+		if err != nil {
+			return nil, err
+		}
+		page.add(bucketName, result)
+		page.IsTruncated = aws.ToBool(result.IsTruncated)
+		page.NextContinuationToken = aws.ToString(result.NextContinuationToken)
+		return page, nil
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(client, opts.input(bucketName))
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		page.add(bucketName, result)
+
+		// Stop collecting at the cap and hand the caller a token to continue from
+		if len(page.Objects) >= maxListAllObjects && paginator.HasMorePages() {
+			page.IsTruncated = true
+			page.NextContinuationToken = aws.ToString(result.NextContinuationToken)
+			break
+		}
+	}
+	return page, nil
+}
+
+// add appends the folders (common prefixes) and objects of a ListObjectsV2 page
+func (p *listPage) add(bucketName string, result *s3.ListObjectsV2Output) {
+	for _, prefix := range result.CommonPrefixes {
+		p.Objects = append(p.Objects, folderEntry(bucketName, aws.ToString(prefix.Prefix)))
+	}
+	for _, obj := range result.Contents {
+		p.Objects = append(p.Objects, objectEntry(bucketName, obj))
+	}
+}
+
+// objectEntry describes a listed object as a DigitalDocument
+func objectEntry(bucketName string, obj types.Object) map[string]interface{} {
+	key := aws.ToString(obj.Key)
+	entry := map[string]interface{}{
+		"@type":       "DigitalDocument",
+		"identifier":  key,
+		"contentUrl":  fmt.Sprintf("s3://%s/%s", bucketName, key),
+		"name":        path.Base(key),
+		"contentSize": aws.ToInt64(obj.Size),
+	}
+	if obj.LastModified != nil {
+		entry["uploadDate"] = obj.LastModified.Format(time.RFC3339)
+	}
+	if obj.ETag != nil {
+		entry["etag"] = aws.ToString(obj.ETag)
+	}
+	return entry
+}
+
+// folderEntry describes a common prefix as a Collection ("folder")
+func folderEntry(bucketName, prefix string) map[string]interface{} {
+	return map[string]interface{}{
+		"@type":      "Collection",
+		"identifier": prefix,
+		"contentUrl": fmt.Sprintf("s3://%s/%s", bucketName, prefix),
+		"name":       path.Base(strings.TrimSuffix(prefix, "/")) + "/",
+	}
+}
+
+// datasetValue presents a listing page as a Schema.org Dataset
+func (p *listPage) datasetValue(bucketName string, opts listOptions) map[string]interface{} {
+	value := map[string]interface{}{
+		"@type":       "Dataset",
+		"name":        bucketName,
+		"hasPart":     p.Objects,
+		"keyCount":    len(p.Objects),
+		"isTruncated": p.IsTruncated,
+	}
+	if opts.Prefix != "" {
+		value["prefix"] = opts.Prefix
+	}
+	if opts.Delimiter != "" {
+		value["delimiter"] = opts.Delimiter
+	}
+	if p.NextContinuationToken != "" {
+		value["nextContinuationToken"] = p.NextContinuationToken
+	}
+	return value
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/labstack/echo/v4"
+)
+
+// ============================================================================
+// Object Metadata
+// ============================================================================
+
+// metadataHeaderPrefix marks user metadata in HTTP headers and additionalProperty names
+const metadataHeaderPrefix = "x-amz-meta-"
+
+// listMetadataConcurrency bounds the per-object metadata and tag reads of a listing
+const listMetadataConcurrency = 8
+
+// objectMetadataFromAction reads the stored headers, user metadata and tags of an upload:
+// cacheControl, contentDisposition, contentEncoding, metadata and tags (each map an
+// object or a PropertyValue list). PropertyValue entries of object.additionalProperty
+// named "x-amz-meta-<name>" are user metadata as well.
+func objectMetadataFromAction(action *semantic.SemanticAction) (objectAttributes, error) {
+	attrs := objectAttributes{
+		CacheControl:       optionalString(stringOption(action, "cacheControl")),
+		ContentDisposition: optionalString(stringOption(action, "contentDisposition")),
+		ContentEncoding:    optionalString(stringOption(action, "contentEncoding")),
+	}
+
+	tags, ok, err := tagsOption(action)
+	if err != nil {
+		return objectAttributes{}, err
+	}
+	if ok && len(tags) > 0 {
+		attrs.Tagging = aws.String(encodeTags(tags))
+	}
+
+	metadata, _, err := stringMapOption(action, "metadata")
+	if err != nil {
+		return objectAttributes{}, err
+	}
+	if entries, ok := actionNode(action, "object")["additionalProperty"].([]interface{}); ok {
+		for _, entry := range entries {
+			pv, _ := entry.(map[string]interface{})
+			name, ok := strings.CutPrefix(strings.ToLower(asString(pv["name"])), metadataHeaderPrefix)
+			if !ok || name == "" {
+				continue
+			}
+			if metadata == nil {
+				metadata = map[string]string{}
+			}
+			metadata[name] = asString(pv["value"])
+		}
+	}
+	if metadata != nil {
+		attrs.Metadata = normalizeMetadata(metadata)
+	}
+	return attrs, nil
+}
+
+// normalizeMetadata lowercases metadata names the way S3 stores them
+func normalizeMetadata(metadata map[string]string) map[string]string {
+	normalized := make(map[string]string, len(metadata))
+	for name, value := range metadata {
+		normalized[strings.ToLower(name)] = value
+	}
+	return normalized
+}
+
+// optionalString returns nil for an empty string so unset headers are not sent
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
+
+// applyToPut sets the stored headers and metadata on a PutObject request
+func (a objectAttributes) applyToPut(input *s3.PutObjectInput) {
+	if a.ContentType != nil {
+		input.ContentType = a.ContentType
+	}
+	input.CacheControl = a.CacheControl
+	input.ContentDisposition = a.ContentDisposition
+	input.ContentEncoding = a.ContentEncoding
+	input.Metadata = a.Metadata
+	input.Tagging = a.Tagging
+}
+
+// applyToCreate sets the stored headers and metadata on a CreateMultipartUpload request
+func (a objectAttributes) applyToCreate(input *s3.CreateMultipartUploadInput) {
+	if a.ContentType != nil {
+		input.ContentType = a.ContentType
+	}
+	input.CacheControl = a.CacheControl
+	input.ContentDisposition = a.ContentDisposition
+	input.ContentEncoding = a.ContentEncoding
+	input.Metadata = a.Metadata
+	input.Tagging = a.Tagging
+}
+
+// headAttributes returns the stored headers and metadata reported by HeadObject
+func headAttributes(head *s3.HeadObjectOutput) objectAttributes {
+	return objectAttributes{
+		ContentType:        head.ContentType,
+		CacheControl:       head.CacheControl,
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		Metadata:           head.Metadata,
+	}
+}
+
+// describe adds the stored headers and metadata to a DigitalDocument entry.
+// User metadata is listed as PropertyValue entries in additionalProperty.
+func (a objectAttributes) describe(entry map[string]interface{}) {
+	if a.ContentType != nil {
+		entry["encodingFormat"] = aws.ToString(a.ContentType)
+	}
+	if a.CacheControl != nil {
+		entry["cacheControl"] = aws.ToString(a.CacheControl)
+	}
+	if a.ContentDisposition != nil {
+		entry["contentDisposition"] = aws.ToString(a.ContentDisposition)
+	}
+	if a.ContentEncoding != nil {
+		entry["contentEncoding"] = aws.ToString(a.ContentEncoding)
+	}
+	if len(a.Metadata) > 0 {
+		entry["additionalProperty"] = propertyValues(a.Metadata)
+	}
+}
+
+// propertyValues presents string pairs as PropertyValue entries sorted by name
+func propertyValues(pairs map[string]string) []interface{} {
+	names := make([]string, 0, len(pairs))
+	for name := range pairs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		values = append(values, map[string]interface{}{
+			"@type": "PropertyValue",
+			"name":  name,
+			"value": pairs[name],
+		})
+	}
+	return values
+}
+
+// setStoredHeaders sets the x-amz-version-id, Cache-Control, Content-Encoding and
+// x-amz-meta-* headers of a stored object on a GET or HEAD response
+func setStoredHeaders(header http.Header, versionID, cacheControl, contentEncoding *string, metadata map[string]string) {
+	if versionID != nil {
+		header.Set("X-Amz-Version-Id", *versionID)
+	}
+	if cacheControl != nil {
+		header.Set(echo.HeaderCacheControl, *cacheControl)
+	}
+	if contentEncoding != nil {
+		header.Set(echo.HeaderContentEncoding, *contentEncoding)
+	}
+	for name, value := range metadata {
+		header.Set(metadataHeaderPrefix+name, value)
+	}
+}
+
+// detectContentType picks a content type for an upload without one: from the
+// extension of the key (or file name), else by sniffing the first 512 bytes
+func detectContentType(body *uploadBody, key string) (string, error) {
+	for _, name := range []string{key, body.Name} {
+		if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+			return contentType, nil
+		}
+	}
+
+	var head []byte
+	if body.Stream != nil {
+		// A stream cannot seek back, so its first bytes are peeked through a buffer
+		buffered := bufio.NewReaderSize(body.Stream, 512)
+		body.Stream = buffered
+		peeked, err := buffered.Peek(512)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return "", err
+		}
+		head = peeked
+	} else {
+		head = make([]byte, 512)
+		n, err := io.ReadFull(body.Reader, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return "", err
+		}
+		if _, err := body.Reader.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		head = head[:n]
+	}
+	if len(head) == 0 {
+		return "application/octet-stream", nil
+	}
+	return http.DetectContentType(head), nil
+}
+
+// describeObjects fills the listed DigitalDocument entries with the content type,
+// stored headers and user metadata from HeadObject. Objects deleted since the
+// listing are left as listed.
+func describeObjects(ctx context.Context, client *s3.Client, bucketName string, entries []interface{}) error {
+	return forEachObjectEntry(ctx, entries, func(ctx context.Context, entry map[string]interface{}) error {
+		key := asString(entry["identifier"])
+		head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		switch {
+		case err == nil:
+			headAttributes(head).describe(entry)
+		case s3StatusCode(err) == http.StatusNotFound:
+			// Deleted after it was listed
+		default:
+			return fmt.Errorf("failed to read metadata of %s: %w", key, err)
+		}
+		return nil
+	})
+}
+
+// forEachObjectEntry runs fn for every DigitalDocument entry of a listing with up to
+// listMetadataConcurrency workers and stops at the first error
+func forEachObjectEntry(ctx context.Context, entries []interface{}, fn func(ctx context.Context, entry map[string]interface{}) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan map[string]interface{})
+	errs := make(chan error, listMetadataConcurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < listMetadataConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				if err := fn(ctx, entry); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, item := range entries {
+		entry, ok := item.(map[string]interface{})
+		if !ok || entry["@type"] != "DigitalDocument" {
+			continue
+		}
+		select {
+		case jobs <- entry:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestDetectContentType(t *testing.T) {
+	cases := []struct {
+		key, name string
+		data      []byte
+		want      string
+	}{
+		{"reports/q1.json", "", []byte("{}"), "application/json"},
+		{"upload", "photo.png", nil, "image/png"},
+		{"blob", "", []byte("\x89PNG\r\n\x1a\n0000"), "image/png"},
+		{"blob", "", []byte("plain words"), "text/plain; charset=utf-8"},
+		{"blob", "", nil, "application/octet-stream"},
+	}
+	for _, tc := range cases {
+		body := &uploadBody{Reader: bytes.NewReader(tc.data), Size: int64(len(tc.data)), Name: tc.name}
+		got, err := detectContentType(body, tc.key)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.key, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s/%s: expected %q, got %q", tc.key, tc.name, tc.want, got)
+		}
+		if rest, _ := io.ReadAll(body.Reader); !bytes.Equal(rest, tc.data) {
+			t.Errorf("%s: reader was not rewound", tc.key)
+		}
+
+		// Streams are peeked without losing the bytes read
+		stream := &uploadBody{Stream: io.MultiReader(bytes.NewReader(tc.data)), Size: int64(len(tc.data)), Name: tc.name}
+		if got, err := detectContentType(stream, tc.key); err != nil || got != tc.want {
+			t.Errorf("%s/%s: expected %q for a stream, got %q (%v)", tc.key, tc.name, tc.want, got, err)
+		}
+		if rest, _ := io.ReadAll(stream.Stream); !bytes.Equal(rest, tc.data) {
+			t.Errorf("%s: stream lost its first bytes", tc.key)
+		}
+	}
+}
+
+func TestPutObject_StoresMetadata(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "metadata"))
+
+	body := &uploadBody{
+		Reader:      bytes.NewReader([]byte("<p>hi</p>")),
+		Size:        9,
+		ContentType: "text/html",
+		Attributes: objectAttributes{
+			CacheControl: aws.String("max-age=60"),
+			Metadata:     map[string]string{"owner": "ops"},
+		},
+	}
+	if _, err := putObject(context.Background(), client, "bucket", "site/index.html", body); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if got := fake.storedHeader("bucket", "site/index.html", "X-Amz-Meta-Owner"); got != "ops" {
+		t.Errorf("expected stored metadata, got %q", got)
+	}
+
+	entries := []interface{}{
+		map[string]interface{}{"@type": "DigitalDocument", "identifier": "site/index.html"},
+		map[string]interface{}{"@type": "DigitalDocument", "identifier": "site/deleted.html"},
+		map[string]interface{}{"@type": "Collection", "identifier": "site/img/"},
+	}
+	if err := describeObjects(context.Background(), client, "bucket", entries); err != nil {
+		t.Fatalf("describe failed: %v", err)
+	}
+	entry := entries[0].(map[string]interface{})
+	if entry["encodingFormat"] != "text/html" || entry["cacheControl"] != "max-age=60" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+	props, _ := entry["additionalProperty"].([]interface{})
+	if len(props) != 1 || props[0].(map[string]interface{})["value"] != "ops" {
+		t.Errorf("unexpected metadata %v", entry["additionalProperty"])
+	}
+	if _, ok := entries[1].(map[string]interface{})["encodingFormat"]; ok {
+		t.Error("a missing object must be left as listed")
+	}
+}
+
+func TestObjectCopy_UpdateMergesMetadata(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "update")
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), target)
+
+	body := &uploadBody{
+		Reader:      bytes.NewReader([]byte("a,b")),
+		Size:        3,
+		ContentType: "text/csv",
+		Attributes:  objectAttributes{Metadata: map[string]string{"keep": "1", "drop": "2"}},
+	}
+	if _, err := putObject(context.Background(), client, "bucket", "data.csv", body); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+
+	update := newTestCopy(t, target, target, "data.csv", "data.csv")
+	update.opts = copyOptions{
+		CacheControl:  "no-cache",
+		Metadata:      map[string]string{"drop": "", "added": "3"},
+		MergeMetadata: true,
+	}
+	if _, err := update.run(context.Background()); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"Content-Type":     "text/csv",
+		"Cache-Control":    "no-cache",
+		"X-Amz-Meta-Keep":  "1",
+		"X-Amz-Meta-Drop":  "",
+		"X-Amz-Meta-Added": "3",
+	} {
+		if got := fake.storedHeader("bucket", "data.csv", name); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+	if content, _ := fake.object("bucket", "data.csv"); string(content) != "a,b" {
+		t.Errorf("content changed to %q", content)
+	}
+}
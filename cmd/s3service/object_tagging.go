@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/labstack/echo/v4"
+)
+
+// ============================================================================
+// Object Tagging
+// ============================================================================
+
+const (
+	maxObjectTags     = 10  // S3 limit on tags per object
+	maxTagKeyLength   = 128 // S3 limit on tag key length (characters)
+	maxTagValueLength = 256 // S3 limit on tag value length (characters)
+)
+
+// tagsOption reads the "tags" option (an object or a PropertyValue list) and checks it
+// against the S3 tag limits
+func tagsOption(action *semantic.SemanticAction) (map[string]string, bool, error) {
+	tags, ok, err := stringMapOption(action, "tags")
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	return tags, true, validateTags(tags)
+}
+
+// validateTags checks a tag set against the S3 limits
+func validateTags(tags map[string]string) error {
+	if len(tags) > maxObjectTags {
+		return fmt.Errorf("%d tags exceed the limit of %d per object", len(tags), maxObjectTags)
+	}
+	for key, value := range tags {
+		switch {
+		case key == "":
+			return fmt.Errorf("tag keys must not be empty")
+		case len([]rune(key)) > maxTagKeyLength:
+			return fmt.Errorf("tag key %q exceeds %d characters", key, maxTagKeyLength)
+		case len([]rune(value)) > maxTagValueLength:
+			return fmt.Errorf("value of tag %q exceeds %d characters", key, maxTagValueLength)
+		}
+	}
+	return nil
+}
+
+// tagSet converts tags to the S3 TagSet, sorted by key
+func tagSet(tags map[string]string) []types.Tag {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	set := make([]types.Tag, 0, len(keys))
+	for _, key := range keys {
+		set = append(set, types.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return set
+}
+
+// getObjectTags reads the tags of an object, or of one version when versionID is set
+func getObjectTags(ctx context.Context, client *s3.Client, bucketName, key, versionID string) (map[string]string, error) {
+	output, err := client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(key),
+		VersionId: optionalString(versionID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(output.TagSet))
+	for _, tag := range output.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+// updateObjectTags replaces an object's tags, or with merge adds them to the stored
+// ones (an empty value removes a tag). It returns the tags now stored.
+func updateObjectTags(ctx context.Context, client *s3.Client, bucketName, key string, tags map[string]string, merge bool) (map[string]string, error) {
+	if merge {
+		stored, err := getObjectTags(ctx, client, bucketName, key, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tags: %w", err)
+		}
+		for name, value := range tags {
+			if value == "" {
+				delete(stored, name)
+			} else {
+				stored[name] = value
+			}
+		}
+		tags = stored
+		if err := validateTags(tags); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err := client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(bucketName),
+		Key:     aws.String(key),
+		Tagging: &types.Tagging{TagSet: tagSet(tags)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write tags: %w", err)
+	}
+	return tags, nil
+}
+
+// matchesTags reports whether tags hold every key/value pair of filter
+func matchesTags(tags, filter map[string]string) bool {
+	for key, value := range filter {
+		if stored, ok := tags[key]; !ok || stored != value {
+			return false
+		}
+	}
+	return true
+}
+
+// tagObjects adds each listed object's tags to its entry and, with a non-empty filter,
+// drops the objects whose tags do not match. Folder entries are kept.
+func tagObjects(ctx context.Context, client *s3.Client, bucketName string, entries []interface{}, filter map[string]string) ([]interface{}, error) {
+	var mu sync.Mutex
+	excluded := map[string]bool{}
+
+	err := forEachObjectEntry(ctx, entries, func(ctx context.Context, entry map[string]interface{}) error {
+		key := asString(entry["identifier"])
+		tags, err := getObjectTags(ctx, client, bucketName, key, "")
+		switch {
+		case err == nil:
+		case s3StatusCode(err) == http.StatusNotFound:
+			// Deleted after it was listed
+			tags = map[string]string{}
+		default:
+			return fmt.Errorf("failed to read tags of %s: %w", key, err)
+		}
+
+		entry["tags"] = propertyValues(tags)
+		if !matchesTags(tags, filter) {
+			mu.Lock()
+			excluded[key] = true
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]interface{}, 0, len(entries))
+	for _, item := range entries {
+		if entry, ok := item.(map[string]interface{}); ok && entry["@type"] == "DigitalDocument" && excluded[asString(entry["identifier"])] {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept, nil
+}
+
+// tagFilterFromQuery parses REST tag filters given as repeated "key=value" parameters
+func tagFilterFromQuery(values []string) (map[string]string, error) {
+	filter := map[string]string{}
+	for _, value := range values {
+		key, tagValue, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("tag filter %q must be key=value", value)
+		}
+		filter[key] = tagValue
+	}
+	return filter, nil
+}
+
+// executeTaggingActionImpl reads (DownloadAction) or removes (DeleteAction) the tags
+// of object.identifier when the "tagging" option is set. Tags are written with an
+// UpdateAction carrying "tags".
+func executeTaggingActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	// Resolve the storage profile (or inline target credentials) and bucket
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+	bucketName := target.Bucket
+
+	object, err := semantic.GetS3ObjectFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to extract S3 object", err)
+	}
+	s3Key := object.Identifier
+	if s3Key == "" {
+		s3Key = object.Name
+	}
+	if s3Key == "" {
+		return returnActionError(c, action, "Object identifier (S3 key) is required", nil)
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	versionID := versionIDOption(action)
+	tags := map[string]string{}
+	if actionType(action) == "DeleteAction" {
+		_, err = client.DeleteObjectTagging(ctx, &s3.DeleteObjectTaggingInput{
+			Bucket:    aws.String(bucketName),
+			Key:       aws.String(s3Key),
+			VersionId: optionalString(versionID),
+		})
+		if err != nil {
+			return returnActionError(c, action, "Failed to delete tags", err)
+		}
+	} else {
+		tags, err = getObjectTags(ctx, client, bucketName, s3Key, versionID)
+		if err != nil {
+			return returnActionError(c, action, "Failed to read tags", err)
+		}
+	}
+
+	value := taggedDocument(bucketName, s3Key, tags)
+	if versionID != "" {
+		value["version"] = versionID
+	}
+	action.Result = &semantic.SemanticResult{
+		Type:   "DigitalDocument",
+		Format: "application/json",
+		Value:  value,
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
+
+// taggedDocument describes an object's tags as a DigitalDocument with PropertyValue tags
+func taggedDocument(bucketName, key string, tags map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"@type":      "DigitalDocument",
+		"identifier": key,
+		"contentUrl": fmt.Sprintf("s3://%s/%s", bucketName, key),
+		"tags":       propertyValues(tags),
+	}
+}
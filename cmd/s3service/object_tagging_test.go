@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func putTaggedObject(t *testing.T, client *s3.Client, key string, tags map[string]string) {
+	t.Helper()
+	body := &uploadBody{Reader: bytes.NewReader([]byte(key)), Size: int64(len(key))}
+	if len(tags) > 0 {
+		body.Attributes.Tagging = aws.String(encodeTags(tags))
+	}
+	if _, err := putObject(context.Background(), client, "bucket", key, body); err != nil {
+		t.Fatalf("put %s failed: %v", key, err)
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := 0; i <= maxObjectTags; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+	for name, tags := range map[string]map[string]string{
+		"too many":   tooMany,
+		"empty key":  {"": "v"},
+		"long key":   {strings.Repeat("k", maxTagKeyLength+1): "v"},
+		"long value": {"k": strings.Repeat("v", maxTagValueLength+1)},
+	} {
+		if err := validateTags(tags); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if err := validateTags(map[string]string{"project": "apollo", "retention": ""}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUpdateObjectTags(t *testing.T) {
+	_, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "tags"))
+	putTaggedObject(t, client, "report.pdf", map[string]string{"project": "apollo", "retention": "1y"})
+
+	tags, err := updateObjectTags(context.Background(), client, "bucket", "report.pdf", map[string]string{"retention": "", "owner": "ops"}, true)
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	stored, err := getObjectTags(context.Background(), client, "bucket", "report.pdf", "")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	want := map[string]string{"project": "apollo", "owner": "ops"}
+	if len(stored) != len(want) || !matchesTags(stored, want) || !matchesTags(tags, want) {
+		t.Errorf("expected %v, stored %v, returned %v", want, stored, tags)
+	}
+
+	if _, err := updateObjectTags(context.Background(), client, "bucket", "report.pdf", map[string]string{"class": "a"}, false); err != nil {
+		t.Fatalf("replace failed: %v", err)
+	}
+	if stored, _ := getObjectTags(context.Background(), client, "bucket", "report.pdf", ""); len(stored) != 1 || stored["class"] != "a" {
+		t.Errorf("expected the tags to be replaced, got %v", stored)
+	}
+}
+
+func TestTagObjects_Filter(t *testing.T) {
+	_, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "tag-filter"))
+	putTaggedObject(t, client, "a.txt", map[string]string{"project": "apollo", "retention": "1y"})
+	putTaggedObject(t, client, "b.txt", map[string]string{"project": "gemini"})
+	putTaggedObject(t, client, "c.txt", nil)
+
+	entries := []interface{}{
+		map[string]interface{}{"@type": "Collection", "identifier": "docs/"},
+		map[string]interface{}{"@type": "DigitalDocument", "identifier": "a.txt"},
+		map[string]interface{}{"@type": "DigitalDocument", "identifier": "b.txt"},
+		map[string]interface{}{"@type": "DigitalDocument", "identifier": "c.txt"},
+	}
+	kept, err := tagObjects(context.Background(), client, "bucket", entries, map[string]string{"project": "apollo"})
+	if err != nil {
+		t.Fatalf("filter failed: %v", err)
+	}
+	if len(kept) != 2 || kept[1].(map[string]interface{})["identifier"] != "a.txt" {
+		t.Fatalf("expected the folder and a.txt, got %v", kept)
+	}
+	tags := kept[1].(map[string]interface{})["tags"].([]interface{})
+	if len(tags) != 2 || tags[0].(map[string]interface{})["name"] != "project" {
+		t.Errorf("expected sorted PropertyValue tags, got %v", tags)
+	}
+
+	all, err := tagObjects(context.Background(), client, "bucket", entries, nil)
+	if err != nil || len(all) != len(entries) {
+		t.Errorf("without a filter every entry is kept, got %d (%v)", len(all), err)
+	}
+}
+
+func TestTagFilterFromQuery(t *testing.T) {
+	filter, err := tagFilterFromQuery([]string{"project=apollo", "note=a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter["project"] != "apollo" || filter["note"] != "a=b" {
+		t.Errorf("unexpected filter %v", filter)
+	}
+	if _, err := tagFilterFromQuery([]string{"project"}); err == nil {
+		t.Error("expected an error for a filter without a value")
+	}
+}
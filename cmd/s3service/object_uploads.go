@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/labstack/echo/v4"
+)
+
+// uploadBodyKey is the echo context key REST handlers use to hand request bytes
+// (raw bodies, multipart file fields) to the upload action without re-encoding them
+const uploadBodyKey = "s3service.uploadBody"
+
+// maxInlineUploadSize bounds uploads that are held in memory (inline text)
+const maxInlineUploadSize = 256 << 20
+
+// uploadBody is the content of an upload that arrived with the request itself
+type uploadBody struct {
+	Reader io.ReadSeeker
+	// Stream is content read straight from the request (raw bodies, client-pushed
+	// parts), which cannot be rewound; it is used in place of Reader
+	Stream      io.Reader
+	Size        int64
+	ContentType string
+	Name        string
+	// Checksums are the digests the content must match and the algorithms to
+	// compute while it is uploaded
+	Checksums checksumSpec
+	// Attributes are the stored headers and user metadata written with the object
+	Attributes objectAttributes
+}
+
+// requestUploadBody returns content attached to the request by a REST handler, if any
+func requestUploadBody(c echo.Context) *uploadBody {
+	body, _ := c.Get(uploadBodyKey).(*uploadBody)
+	return body
+}
+
+// inlineUploadBody decodes object.text into an upload body. The text is base64
+// when object.encoding (or additionalProperty.encoding) is "base64", otherwise it
+// is uploaded as-is.
+func inlineUploadBody(action *semantic.SemanticAction) (*uploadBody, error) {
+	object := actionNode(action, "object")
+	value, ok := object["text"]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	text := asString(value)
+
+	var data []byte
+	encoding := ""
+	if value, ok := lookupProperty(object, "encoding"); ok {
+		encoding = strings.ToLower(asString(value))
+	}
+	switch encoding {
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 text: %w", err)
+		}
+		data = decoded
+	case "", "text", "utf-8", "utf8":
+		data = []byte(text)
+	default:
+		return nil, fmt.Errorf("unsupported text encoding %q", encoding)
+	}
+
+	if len(data) > maxInlineUploadSize {
+		return nil, fmt.Errorf("inline content exceeds %d bytes", maxInlineUploadSize)
+	}
+
+	return &uploadBody{
+		Reader:      bytes.NewReader(data),
+		Size:        int64(len(data)),
+		ContentType: asString(object["encodingFormat"]),
+		Name:        asString(object["name"]),
+	}, nil
+}
+
+// putObject uploads a body with a single PutObject call
+func putObject(ctx context.Context, client *s3.Client, bucketName, key string, body *uploadBody) (*s3.PutObjectOutput, error) {
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(bucketName),
+		Key:           aws.String(key),
+		Body:          body.Reader,
+		ContentLength: aws.Int64(body.Size),
+	}
+	if body.ContentType != "" {
+		input.ContentType = aws.String(body.ContentType)
+	}
+	body.Attributes.applyToPut(input)
+	applyChecksums(input, body.Checksums)
+
+	return client.PutObject(ctx, input)
+}
+
+// putObjectVerified uploads a body with putObject, hashing it on the way for the
+// algorithms its checksum spec computes. S3 itself rejects a body that does not match
+// a digest sent with the request; any other mismatch deletes the stored version again.
+func putObjectVerified(ctx context.Context, client *s3.Client, bucketName, key string, body *uploadBody) (*uploadResult, error) {
+	reader := newChecksumReader(body.Reader, body.Checksums.Compute)
+	hashed := *body
+	hashed.Reader = reader
+	output, err := putObject(ctx, client, bucketName, key, &hashed)
+	if err != nil {
+		return nil, err
+	}
+	reportProgress(ctx, body.Size, 0)
+
+	result := &uploadResult{ETag: aws.ToString(output.ETag), VersionID: aws.ToString(output.VersionId)}
+	if len(body.Checksums.Compute) == 0 {
+		return result, nil
+	}
+	result.Checksums, err = reader.sums()
+	if err == nil {
+		err = body.Checksums.verify(result.Checksums)
+	}
+	if err != nil {
+		_, _ = client.DeleteObject(context.WithoutCancel(ctx), &s3.DeleteObjectInput{
+			Bucket:    aws.String(bucketName),
+			Key:       aws.String(key),
+			VersionId: output.VersionId,
+		})
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/labstack/echo/v4"
+)
+
+// maxPresignExpiry is the longest validity SigV4 allows for a presigned URL
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+// presignBounds limits how long presigned URLs stay valid
+type presignBounds struct {
+	Default time.Duration
+	Min     time.Duration
+	Max     time.Duration
+}
+
+// presignSettings reads S3_PRESIGN_DEFAULT_EXPIRY (default 15m), S3_PRESIGN_MIN_EXPIRY
+// (default 1m) and S3_PRESIGN_MAX_EXPIRY (default 12h, at most 7 days)
+func presignSettings() presignBounds {
+	bounds := presignBounds{Default: 15 * time.Minute, Min: time.Minute, Max: 12 * time.Hour}
+	for name, setting := range map[string]*time.Duration{
+		"S3_PRESIGN_DEFAULT_EXPIRY": &bounds.Default,
+		"S3_PRESIGN_MIN_EXPIRY":     &bounds.Min,
+		"S3_PRESIGN_MAX_EXPIRY":     &bounds.Max,
+	} {
+		if value := os.Getenv(name); value != "" {
+			if parsed, err := parseTimeout(value); err == nil && parsed > 0 {
+				*setting = parsed
+			}
+		}
+	}
+	if bounds.Max > maxPresignExpiry {
+		bounds.Max = maxPresignExpiry
+	}
+	return bounds
+}
+
+// expiry returns the requested validity (seconds or a Go duration), or the default.
+// Requests outside the bounds are rejected rather than silently shortened.
+func (b presignBounds) expiry(value interface{}, ok bool) (time.Duration, error) {
+	if !ok {
+		return b.Default, nil
+	}
+	expires, err := parseTimeout(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid expiresIn: %w", err)
+	}
+	if expires < b.Min || expires > b.Max {
+		return 0, fmt.Errorf("expiresIn must be between %s and %s", b.Min, b.Max)
+	}
+	return expires, nil
+}
+
+// presignRequest describes the operation a presigned URL grants
+type presignRequest struct {
+	Method    string // GET or PUT
+	Key       string
+	VersionID string // GET only
+
+	// PUT constraints: when set, the uploader must send exactly these headers
+	ContentType   string
+	ContentLength *int64
+
+	// UploadID and PartNumber presign one part of a multipart upload instead of a PUT
+	UploadID   string
+	PartNumber int32
+
+	Expires time.Duration
+}
+
+// presignedURL is a signed request a client can send without credentials
+type presignedURL struct {
+	URL           string
+	Method        string
+	Expires       time.Time
+	SignedHeaders map[string]string
+}
+
+// presignObject signs a GET, PUT or UploadPart request for an object
+func presignObject(ctx context.Context, client *s3.Client, bucket string, req presignRequest) (*presignedURL, error) {
+	presigner := s3.NewPresignClient(client, s3.WithPresignExpires(req.Expires))
+	signedAt := time.Now()
+
+	var (
+		signed *v4.PresignedHTTPRequest
+		err    error
+	)
+	switch {
+	case req.Method == http.MethodGet:
+		signed, err = presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket:    aws.String(bucket),
+			Key:       aws.String(req.Key),
+			VersionId: optionalString(req.VersionID),
+		})
+	case req.Method == http.MethodPut && req.UploadID != "":
+		if req.PartNumber < 1 || req.PartNumber > maxUploadParts {
+			return nil, fmt.Errorf("partNumber must be between 1 and %d", maxUploadParts)
+		}
+		signed, err = presigner.PresignUploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(req.Key),
+			UploadId:      aws.String(req.UploadID),
+			PartNumber:    aws.Int32(req.PartNumber),
+			ContentLength: req.ContentLength,
+		})
+	case req.Method == http.MethodPut:
+		signed, err = presigner.PresignPutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(req.Key),
+			ContentType:   optionalString(req.ContentType),
+			ContentLength: req.ContentLength,
+		})
+	default:
+		return nil, fmt.Errorf("cannot presign method %q (use GET or PUT)", req.Method)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign request: %w", err)
+	}
+
+	// Host is implied by the URL; the client must send every other signed header
+	headers := map[string]string{}
+	for name, values := range signed.SignedHeader {
+		if !strings.EqualFold(name, "Host") && len(values) > 0 {
+			headers[name] = values[0]
+		}
+	}
+	return &presignedURL{
+		URL:           signed.URL,
+		Method:        signed.Method,
+		Expires:       signedAt.Add(req.Expires).UTC(),
+		SignedHeaders: headers,
+	}, nil
+}
+
+// publicTarget returns the target as seen by clients outside the service. SigV4 signs
+// the Host header, so URLs for a public hostname must be signed for it, not rewritten.
+func publicTarget(target *storageTarget) *storageTarget {
+	if target.PublicEndpoint == "" {
+		return target
+	}
+	public := *target
+	public.Endpoint = target.PublicEndpoint
+	return &public
+}
+
+// executePresignActionImpl returns a time-limited URL instead of transferring data.
+//
+// A DownloadAction presigns GET (versionId pins a version); a CreateAction presigns
+// PUT, optionally constrained to the object's encodingFormat and the contentLength
+// option, or one part of a multipart upload when uploadId and partNumber are set.
+// expiresIn (seconds or a Go duration) must lie within the configured bounds.
+func executePresignActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+
+	object, err := semantic.GetS3ObjectFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to extract S3 object", err)
+	}
+	key := object.Identifier
+	if key == "" {
+		key = object.Name
+	}
+	if key == "" {
+		return returnActionError(c, action, "Object identifier (S3 key) is required", nil)
+	}
+
+	expiresIn, ok := actionOption(action, "expiresIn")
+	expires, err := presignSettings().expiry(expiresIn, ok)
+	if err != nil {
+		return returnActionError(c, action, "Invalid presign expiry", err)
+	}
+
+	req := presignRequest{Key: key, Expires: expires}
+	switch actionType(action) {
+	case "DownloadAction":
+		req.Method = http.MethodGet
+		req.VersionID = versionIDOption(action)
+	case "CreateAction":
+		req.Method = http.MethodPut
+		req.ContentType = object.EncodingFormat
+		if _, ok := actionOption(action, "contentLength"); ok {
+			length, err := intOption(action, "contentLength", 0)
+			if err != nil || length < 0 {
+				return returnActionError(c, action, "Invalid presign options", fmt.Errorf("contentLength must be a non-negative number"))
+			}
+			req.ContentLength = &length
+		}
+		req.UploadID = stringOption(action, "uploadId")
+		if req.UploadID != "" {
+			// Checked before narrowing to int32, which would wrap out-of-range values
+			partNumber, err := intOption(action, "partNumber", 0)
+			if err == nil && (partNumber < 1 || partNumber > maxUploadParts) {
+				err = fmt.Errorf("partNumber must be between 1 and %d", maxUploadParts)
+			}
+			if err != nil {
+				return returnActionError(c, action, "Invalid presign options", err)
+			}
+			req.PartNumber = int32(partNumber)
+		}
+	default:
+		return returnActionError(c, action, "Unsupported presign action", fmt.Errorf("%s cannot be presigned", actionType(action)))
+	}
+
+	client, err := createS3Client(ctx, publicTarget(target))
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+	signed, err := presignObject(ctx, client, target.Bucket, req)
+	if err != nil {
+		return returnActionError(c, action, "Failed to presign request", err)
+	}
+
+	value := map[string]interface{}{
+		"url":           signed.URL,
+		"httpMethod":    signed.Method,
+		"expires":       signed.Expires.Format(time.RFC3339),
+		"expiresIn":     int64(expires / time.Second),
+		"signedHeaders": signed.SignedHeaders,
+		"identifier":    key,
+		"contentUrl":    fmt.Sprintf("s3://%s/%s", target.Bucket, key),
+	}
+	if req.VersionID != "" {
+		value["version"] = req.VersionID
+	}
+	if req.UploadID != "" {
+		value["uploadId"] = req.UploadID
+		value["partNumber"] = req.PartNumber
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "EntryPoint",
+		Format: "application/json",
+		Value:  value,
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxPostObjectSize is the largest object S3 accepts in a POST upload
+	maxPostObjectSize = 5 << 30
+
+	// postCallbackGrace is how long after a policy expires its upload may still be
+	// reported, since an upload started just before expiry can finish later
+	postCallbackGrace = time.Hour
+)
+
+// postPolicyRequest describes the browser uploads a POST policy admits
+type postPolicyRequest struct {
+	// Key admits exactly one key; KeyPrefix admits any key under the prefix, with the
+	// form's key set to prefix + "${filename}" so S3 uses the uploaded file's name
+	Key       string
+	KeyPrefix string
+
+	// ContentType requires exactly this type; ContentTypePrefix a type such as "image/"
+	ContentType       string
+	ContentTypePrefix string
+
+	// MinSize and MaxSize bound the object size; MaxSize 0 means up to the S3 limit
+	MinSize int64
+	MaxSize int64
+
+	Expires time.Duration
+}
+
+// postPolicy is a signed form: the client posts Fields plus a "file" field to URL
+type postPolicy struct {
+	URL     string
+	Fields  map[string]string
+	Expires time.Time
+}
+
+// validate checks that the request describes a policy S3 can enforce
+func (r *postPolicyRequest) validate() error {
+	if (r.Key == "") == (r.KeyPrefix == "") {
+		return fmt.Errorf("exactly one of key and keyPrefix is required")
+	}
+	if r.ContentType != "" && r.ContentTypePrefix != "" {
+		return fmt.Errorf("contentType and contentTypePrefix cannot be combined")
+	}
+	if r.MinSize < 0 || r.MaxSize < 0 || r.MaxSize > maxPostObjectSize {
+		return fmt.Errorf("content length limits must be between 0 and %d", int64(maxPostObjectSize))
+	}
+	if r.MaxSize > 0 && r.MinSize > r.MaxSize {
+		return fmt.Errorf("minContentLength exceeds maxContentLength")
+	}
+	return nil
+}
+
+// conditions returns the policy conditions beyond the bucket, key and credentials
+// the SDK always adds
+func (r *postPolicyRequest) conditions() []interface{} {
+	var conditions []interface{}
+	if r.KeyPrefix != "" {
+		conditions = append(conditions, []interface{}{"starts-with", "$key", r.KeyPrefix})
+	}
+	if r.ContentType != "" {
+		conditions = append(conditions, map[string]string{"Content-Type": r.ContentType})
+	}
+	if r.ContentTypePrefix != "" {
+		conditions = append(conditions, []interface{}{"starts-with", "$Content-Type", r.ContentTypePrefix})
+	}
+	if r.MinSize > 0 || r.MaxSize > 0 {
+		maxSize := r.MaxSize
+		if maxSize == 0 {
+			maxSize = maxPostObjectSize
+		}
+		conditions = append(conditions, []interface{}{"content-length-range", r.MinSize, maxSize})
+	}
+	return conditions
+}
+
+// presignPostPolicy signs a POST policy document for browser form uploads
+func presignPostPolicy(ctx context.Context, client *s3.Client, bucket string, req postPolicyRequest) (*postPolicy, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	key := req.Key
+	if key == "" {
+		key = req.KeyPrefix + "${filename}"
+	}
+
+	signedAt := time.Now()
+	signed, err := s3.NewPresignClient(client).PresignPostObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, func(o *s3.PresignPostOptions) {
+		o.Expires = req.Expires
+		o.Conditions = req.conditions()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign POST policy: %w", err)
+	}
+
+	fields := map[string]string{}
+	for name, value := range signed.Values {
+		fields[name] = value
+	}
+	if req.ContentType != "" {
+		fields["Content-Type"] = req.ContentType
+	}
+	return &postPolicy{
+		URL:     signed.URL,
+		Fields:  fields,
+		Expires: signedAt.Add(req.Expires).UTC(),
+	}, nil
+}
+
+// postPolicyDocument is a decoded POST policy
+type postPolicyDocument struct {
+	Expiration time.Time     `json:"expiration"`
+	Conditions []interface{} `json:"conditions"`
+}
+
+// field returns the value of an exact-match condition such as {"bucket": "..."}
+func (d *postPolicyDocument) field(name string) string {
+	for _, condition := range d.Conditions {
+		if match, ok := condition.(map[string]interface{}); ok {
+			for field, value := range match {
+				if strings.EqualFold(field, name) {
+					return asString(value)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// verifyPostPolicy checks that a policy was signed with the target's credentials,
+// so a callback can only report uploads made with a policy this service issued
+func verifyPostPolicy(target *storageTarget, policy, signature string) (*postPolicyDocument, error) {
+	if policy == "" || signature == "" {
+		return nil, fmt.Errorf("policy and signature are required")
+	}
+	data, err := base64.StdEncoding.DecodeString(policy)
+	if err != nil {
+		return nil, fmt.Errorf("policy is not base64: %w", err)
+	}
+	var document postPolicyDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid policy document: %w", err)
+	}
+
+	// The credential is <access key>/<date>/<region>/<service>/aws4_request
+	scope := strings.Split(document.field("x-amz-credential"), "/")
+	if len(scope) != 5 || scope[4] != "aws4_request" {
+		return nil, fmt.Errorf("policy has no valid credential")
+	}
+	if scope[0] != target.AccessKey {
+		return nil, fmt.Errorf("policy was not issued for this storage")
+	}
+
+	signingKey := []byte("AWS4" + target.SecretKey)
+	for _, part := range scope[1:] {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	expected := hex.EncodeToString(hmacSHA256(signingKey, policy))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return nil, fmt.Errorf("policy signature does not match")
+	}
+	return &document, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// admits checks an uploaded object against the policy's conditions. The object must
+// have been written after the policy was signed, so a callback cannot claim an object
+// that was already there.
+func (d *postPolicyDocument) admits(bucket, key string, head *s3.HeadObjectOutput, now time.Time) error {
+	if now.After(d.Expiration.Add(postCallbackGrace)) {
+		return fmt.Errorf("policy expired at %s", d.Expiration.Format(time.RFC3339))
+	}
+	if signedAt, err := time.Parse("20060102T150405Z", d.field("x-amz-date")); err == nil {
+		if modified := aws.ToTime(head.LastModified); modified.Before(signedAt.Truncate(time.Second)) {
+			return fmt.Errorf("object was last modified before the policy was issued")
+		}
+	}
+
+	values := map[string]string{
+		"bucket":       bucket,
+		"key":          key,
+		"content-type": aws.ToString(head.ContentType),
+	}
+	size := aws.ToInt64(head.ContentLength)
+	for _, condition := range d.Conditions {
+		switch c := condition.(type) {
+		case map[string]interface{}:
+			for field, want := range c {
+				if got, ok := values[strings.ToLower(field)]; ok && got != asString(want) {
+					return fmt.Errorf("%s %q does not match the policy", field, got)
+				}
+			}
+		case []interface{}:
+			if len(c) != 3 {
+				continue
+			}
+			operator := strings.ToLower(asString(c[0]))
+			if operator == "content-length-range" {
+				minSize, err1 := asInt(c[1])
+				maxSize, err2 := asInt(c[2])
+				if err1 == nil && err2 == nil && (size < minSize || size > maxSize) {
+					return fmt.Errorf("size %d is outside the policy range %d-%d", size, minSize, maxSize)
+				}
+				continue
+			}
+			field := strings.ToLower(strings.TrimPrefix(asString(c[1]), "$"))
+			got, ok := values[field]
+			if !ok {
+				continue
+			}
+			want := asString(c[2])
+			if (operator == "eq" && got != want) || (operator == "starts-with" && !strings.HasPrefix(got, want)) {
+				return fmt.Errorf("%s %q does not match the policy", field, got)
+			}
+		}
+	}
+	return nil
+}
+
+// executePresignPostActionImpl returns a signed form for browser uploads straight to
+// the bucket (a CreateAction with presign and httpMethod POST).
+//
+// object.identifier admits one key, or the keyPrefix option any key below a prefix.
+// object.encodingFormat (or contentTypePrefix) restricts the content type, and
+// contentLength, or minContentLength and maxContentLength, the size.
+func executePresignPostActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+
+	object := actionNode(action, "object")
+	req := postPolicyRequest{
+		Key:               asString(object["identifier"]),
+		KeyPrefix:         stringOption(action, "keyPrefix"),
+		ContentType:       asString(object["encodingFormat"]),
+		ContentTypePrefix: stringOption(action, "contentTypePrefix"),
+	}
+	if req.Key == "" {
+		req.Key = asString(object["name"])
+	}
+	if _, ok := actionOption(action, "contentLength"); ok {
+		if req.MinSize, err = intOption(action, "contentLength", 0); err != nil {
+			return returnActionError(c, action, "Invalid presign options", err)
+		}
+		req.MaxSize = req.MinSize
+	} else {
+		if req.MinSize, err = intOption(action, "minContentLength", 0); err != nil {
+			return returnActionError(c, action, "Invalid presign options", err)
+		}
+		if req.MaxSize, err = intOption(action, "maxContentLength", 0); err != nil {
+			return returnActionError(c, action, "Invalid presign options", err)
+		}
+	}
+
+	expiresIn, ok := actionOption(action, "expiresIn")
+	if req.Expires, err = presignSettings().expiry(expiresIn, ok); err != nil {
+		return returnActionError(c, action, "Invalid presign expiry", err)
+	}
+
+	client, err := createS3Client(ctx, publicTarget(target))
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+	policy, err := presignPostPolicy(ctx, client, target.Bucket, req)
+	if err != nil {
+		return returnActionError(c, action, "Failed to presign POST policy", err)
+	}
+
+	value := map[string]interface{}{
+		"url":          policy.URL,
+		"httpMethod":   http.MethodPost,
+		"encodingType": "multipart/form-data",
+		"fields":       policy.Fields,
+		"expires":      policy.Expires.Format(time.RFC3339),
+		"expiresIn":    int64(req.Expires / time.Second),
+	}
+	if req.Key != "" {
+		value["identifier"] = req.Key
+		value["contentUrl"] = fmt.Sprintf("s3://%s/%s", target.Bucket, req.Key)
+	} else {
+		value["keyPrefix"] = req.KeyPrefix
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "EntryPoint",
+		Format: "application/json",
+		Value:  value,
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
+
+// executeUploadCallbackActionImpl confirms a browser upload made with a POST policy
+// (a CreateAction with uploadCallback). The policy and signature form fields prove the
+// policy was issued here; the object must exist and satisfy the policy. The upload is
+// then recorded as a completed CreateAction in the state manager.
+func executeUploadCallbackActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+
+	object, err := semantic.GetS3ObjectFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to extract S3 object", err)
+	}
+	key := object.Identifier
+	if key == "" {
+		key = object.Name
+	}
+	if key == "" {
+		return returnActionError(c, action, "Object identifier (S3 key) is required", nil)
+	}
+
+	policy, err := verifyPostPolicy(target, stringOption(action, "policy"), stringOption(action, "signature"))
+	if err != nil {
+		return returnActionError(c, action, "Invalid upload callback", err)
+	}
+	if bucket := policy.field("bucket"); bucket != target.Bucket {
+		return returnActionError(c, action, "Invalid upload callback", fmt.Errorf("policy was issued for bucket %q", bucket))
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(target.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return returnActionError(c, action, "Uploaded object not found", err)
+	}
+	if err := policy.admits(target.Bucket, key, head, time.Now()); err != nil {
+		return returnActionError(c, action, "Upload does not satisfy the policy", err)
+	}
+
+	value := map[string]interface{}{
+		"identifier":     key,
+		"contentUrl":     fmt.Sprintf("s3://%s/%s", target.Bucket, key),
+		"contentSize":    aws.ToInt64(head.ContentLength),
+		"encodingFormat": aws.ToString(head.ContentType),
+		"etag":           aws.ToString(head.ETag),
+		"uploadDate":     aws.ToTime(head.LastModified).UTC().Format(time.RFC3339),
+	}
+	if version := aws.ToString(head.VersionId); version != "" {
+		value["version"] = version
+	}
+
+	recorded := map[string]interface{}{}
+	for name, v := range value {
+		recorded[name] = v
+	}
+	tracked := trackedActions.Record(trackedAction{
+		Type:         "CreateAction",
+		ActionStatus: actionStatusCompleted,
+		Object: map[string]interface{}{
+			"@type":      "DigitalDocument",
+			"identifier": key,
+			"contentUrl": value["contentUrl"],
+		},
+		Result: recorded,
+	})
+	value["trackedAction"] = tracked.Identifier
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "DigitalDocument",
+		Format: aws.ToString(head.ContentType),
+		Value:  value,
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
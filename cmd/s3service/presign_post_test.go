@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestPostPolicyRequest_Validate(t *testing.T) {
+	for name, req := range map[string]postPolicyRequest{
+		"no key":             {},
+		"key and prefix":     {Key: "a", KeyPrefix: "b/"},
+		"two content types":  {Key: "a", ContentType: "image/png", ContentTypePrefix: "image/"},
+		"negative size":      {Key: "a", MinSize: -1},
+		"min above max":      {Key: "a", MinSize: 10, MaxSize: 5},
+		"above the S3 limit": {Key: "a", MaxSize: maxPostObjectSize + 1},
+	} {
+		if err := req.validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// postForm uploads content like a browser submitting the signed form
+func postForm(t *testing.T, policy *postPolicy, contentType, filename string, content []byte) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range policy.Fields {
+		_ = form.WriteField(name, value)
+	}
+	if contentType != "" {
+		_ = form.WriteField("Content-Type", contentType)
+	}
+	file, _ := form.CreateFormFile("file", filename)
+	_, _ = file.Write(content)
+	_ = form.Close()
+
+	response, err := http.Post(policy.URL, form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("form upload failed with %d", response.StatusCode)
+	}
+}
+
+func TestPresignPostPolicy_UploadAndCallback(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "presign-post")
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), target)
+
+	policy, err := presignPostPolicy(context.Background(), client, "bucket", postPolicyRequest{
+		KeyPrefix:         "uploads/",
+		ContentTypePrefix: "image/",
+		MaxSize:           1024,
+		Expires:           10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("presign POST failed: %v", err)
+	}
+	if policy.Fields["key"] != "uploads/${filename}" || policy.Fields["X-Amz-Signature"] == "" {
+		t.Errorf("unexpected form fields %v", policy.Fields)
+	}
+	data, _ := base64.StdEncoding.DecodeString(policy.Fields["policy"])
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("invalid policy: %v", err)
+	}
+	conditions, _ := json.Marshal(document["conditions"])
+	for _, want := range []string{`["starts-with","$key","uploads/"]`, `["starts-with","$Content-Type","image/"]`, `["content-length-range",0,1024]`} {
+		if !bytes.Contains(conditions, []byte(want)) {
+			t.Errorf("expected condition %s in %s", want, conditions)
+		}
+	}
+
+	fake.lastModified = time.Now()
+	postForm(t, policy, "image/png", "cat.png", []byte("png"))
+	if content, _ := fake.object("bucket", "uploads/cat.png"); string(content) != "png" {
+		t.Fatalf("expected the form upload to be stored, got %q", content)
+	}
+
+	verified, err := verifyPostPolicy(target, policy.Fields["policy"], policy.Fields["X-Amz-Signature"])
+	if err != nil {
+		t.Fatalf("expected the policy to verify: %v", err)
+	}
+	head := func(key string) *s3.HeadObjectOutput {
+		t.Helper()
+		output, err := client.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String(key)})
+		if err != nil {
+			t.Fatalf("head %s failed: %v", key, err)
+		}
+		return output
+	}
+	if err := verified.admits("bucket", "uploads/cat.png", head("uploads/cat.png"), time.Now()); err != nil {
+		t.Errorf("expected the upload to satisfy the policy: %v", err)
+	}
+	if err := verified.admits("bucket", "uploads/cat.png", head("uploads/cat.png"), time.Now().Add(3*time.Hour)); err == nil {
+		t.Error("expected callbacks long after expiry to be rejected")
+	}
+
+	fake.objects["bucket/uploads/big.png"] = make([]byte, 2048)
+	fake.stored["bucket/uploads/big.png"] = http.Header{"Content-Type": {"image/png"}}
+	fake.objects["bucket/other/cat.png"] = []byte("png")
+	fake.stored["bucket/other/cat.png"] = http.Header{"Content-Type": {"image/png"}}
+	fake.objects["bucket/uploads/cat.txt"] = []byte("txt")
+	fake.stored["bucket/uploads/cat.txt"] = http.Header{"Content-Type": {"text/plain"}}
+	for _, key := range []string{"uploads/big.png", "other/cat.png", "uploads/cat.txt"} {
+		if err := verified.admits("bucket", key, head(key), time.Now()); err == nil {
+			t.Errorf("%s: expected the policy to reject the object", key)
+		}
+	}
+
+	fake.lastModified = time.Now().Add(-time.Hour)
+	if err := verified.admits("bucket", "uploads/cat.png", head("uploads/cat.png"), time.Now()); err == nil {
+		t.Error("expected an object older than the policy to be rejected")
+	}
+}
+
+func TestVerifyPostPolicy_RejectsForgeries(t *testing.T) {
+	_, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "presign-forged")
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), target)
+	policy, err := presignPostPolicy(context.Background(), client, "bucket", postPolicyRequest{Key: "a.txt", Expires: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := policy.Fields
+
+	if _, err := verifyPostPolicy(target, fields["policy"], strings.Repeat("0", 64)); err == nil {
+		t.Error("expected a wrong signature to be rejected")
+	}
+	other := *target
+	other.SecretKey = "other-secret"
+	if _, err := verifyPostPolicy(&other, fields["policy"], fields["X-Amz-Signature"]); err == nil {
+		t.Error("expected a policy signed with other credentials to be rejected")
+	}
+	tampered := base64.StdEncoding.EncodeToString([]byte(`{"expiration":"2099-01-01T00:00:00Z","conditions":[]}`))
+	if _, err := verifyPostPolicy(target, tampered, fields["X-Amz-Signature"]); err == nil {
+		t.Error("expected a rewritten policy to be rejected")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPresignBounds_Expiry(t *testing.T) {
+	bounds := presignBounds{Default: 15 * time.Minute, Min: time.Minute, Max: time.Hour}
+
+	if expires, err := bounds.expiry(nil, false); err != nil || expires != 15*time.Minute {
+		t.Errorf("expected the default expiry, got %s (%v)", expires, err)
+	}
+	if expires, err := bounds.expiry(float64(600), true); err != nil || expires != 10*time.Minute {
+		t.Errorf("expected 600 seconds, got %s (%v)", expires, err)
+	}
+	if expires, err := bounds.expiry("30m", true); err != nil || expires != 30*time.Minute {
+		t.Errorf("expected 30m, got %s (%v)", expires, err)
+	}
+	for _, value := range []interface{}{"10s", "2h", "soon"} {
+		if _, err := bounds.expiry(value, true); err == nil {
+			t.Errorf("%v: expected an error", value)
+		}
+	}
+}
+
+func TestPresignSettings(t *testing.T) {
+	t.Setenv("S3_PRESIGN_DEFAULT_EXPIRY", "300")
+	t.Setenv("S3_PRESIGN_MAX_EXPIRY", "720h")
+	bounds := presignSettings()
+	if bounds.Default != 5*time.Minute || bounds.Min != time.Minute || bounds.Max != maxPresignExpiry {
+		t.Errorf("unexpected bounds %+v", bounds)
+	}
+}
+
+func TestPresignObject_GetAndPut(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "presign"))
+	fake.objects["bucket/docs/report.pdf"] = []byte("report")
+
+	get, err := presignObject(context.Background(), client, "bucket", presignRequest{Method: http.MethodGet, Key: "docs/report.pdf", Expires: 5 * time.Minute})
+	if err != nil {
+		t.Fatalf("presign GET failed: %v", err)
+	}
+	if query := mustParseURL(t, get.URL).Query(); query.Get("X-Amz-Expires") != "300" || query.Get("X-Amz-Signature") == "" {
+		t.Errorf("expected a signed URL valid for 300 seconds, got %s", get.URL)
+	}
+	if time.Until(get.Expires) > 5*time.Minute || time.Until(get.Expires) < 4*time.Minute {
+		t.Errorf("unexpected expiry %s", get.Expires)
+	}
+	response, err := http.Get(get.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if string(body) != "report" {
+		t.Errorf("expected the object through the presigned URL, got %q", body)
+	}
+
+	length := int64(5)
+	put, err := presignObject(context.Background(), client, "bucket", presignRequest{
+		Method:        http.MethodPut,
+		Key:           "uploads/photo.jpg",
+		ContentType:   "image/jpeg",
+		ContentLength: &length,
+		Expires:       time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("presign PUT failed: %v", err)
+	}
+	if put.Method != http.MethodPut || put.SignedHeaders["Content-Type"] != "image/jpeg" || put.SignedHeaders["Content-Length"] != "5" {
+		t.Errorf("expected content type and length to be signed, got %s %v", put.Method, put.SignedHeaders)
+	}
+	if _, ok := put.SignedHeaders["Host"]; ok {
+		t.Error("the host is implied by the URL and not returned")
+	}
+
+	request, _ := http.NewRequest(http.MethodPut, put.URL, bytes.NewReader([]byte("photo")))
+	request.Header.Set("Content-Type", "image/jpeg")
+	if response, err := http.DefaultClient.Do(request); err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("upload through the presigned URL failed: %v %v", response, err)
+	}
+	if content, _ := fake.object("bucket", "uploads/photo.jpg"); string(content) != "photo" {
+		t.Errorf("expected the uploaded object, got %q", content)
+	}
+}
+
+func TestPresignObject_UploadPart(t *testing.T) {
+	_, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "presign-part"))
+
+	part, err := presignObject(context.Background(), client, "bucket", presignRequest{Method: http.MethodPut, Key: "big.bin", UploadID: "u1", PartNumber: 3, Expires: time.Minute})
+	if err != nil {
+		t.Fatalf("presign part failed: %v", err)
+	}
+	if query := mustParseURL(t, part.URL).Query(); query.Get("uploadId") != "u1" || query.Get("partNumber") != "3" {
+		t.Errorf("expected the upload and part in the URL, got %s", part.URL)
+	}
+
+	if _, err := presignObject(context.Background(), client, "bucket", presignRequest{Method: http.MethodPut, Key: "big.bin", UploadID: "u1", Expires: time.Minute}); err == nil {
+		t.Error("expected an error without a part number")
+	}
+	if _, err := presignObject(context.Background(), client, "bucket", presignRequest{Method: http.MethodDelete, Key: "big.bin", Expires: time.Minute}); err == nil {
+		t.Error("only GET and PUT can be presigned")
+	}
+}
+
+func TestPublicTarget(t *testing.T) {
+	_, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "presign-public")
+	if publicTarget(target) != target {
+		t.Error("a target without a public endpoint is used as-is")
+	}
+
+	target.PublicEndpoint = "https://files.example.com"
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), publicTarget(target))
+	signed, err := presignObject(context.Background(), client, "bucket", presignRequest{Method: http.MethodGet, Key: "a.txt", Expires: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed := mustParseURL(t, signed.URL); parsed.Host != "files.example.com" || parsed.Path != "/bucket/a.txt" {
+		t.Errorf("expected the URL to be signed for the public host, got %s", signed.URL)
+	}
+	if target.Endpoint != server.URL {
+		t.Error("the service keeps using the internal endpoint")
+	}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("invalid URL %q: %v", raw, err)
+	}
+	return parsed
+}
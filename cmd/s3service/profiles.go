@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ============================================================================
+// Storage Profiles
+// ============================================================================
+
+// StorageProfile is a named, server-side S3 connection so actions can reference
+// "target.identifier": "<profile>" instead of embedding endpoint and credentials
+type StorageProfile struct {
+	Name      string `json:"name"`
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region,omitempty"`
+	Bucket    string `json:"bucket,omitempty"`
+	AccessKey string `json:"accessKey,omitempty"`
+	SecretKey string `json:"secretKey,omitempty"`
+
+	// PathStyle selects path-style addressing (endpoint/bucket/key); nil means true,
+	// which is what Hetzner and MinIO expect. Set false for virtual-host addressing.
+	PathStyle *bool `json:"pathStyle,omitempty"`
+
+	// PublicEndpoint is the address clients outside the service use to reach the
+	// storage, when the service itself talks to S3 over an internal address.
+	// Presigned URLs are signed for this host.
+	PublicEndpoint string `json:"publicEndpoint,omitempty"`
+
+	// Operations lists the action types (e.g. "DownloadAction") allowed on this
+	// profile. Empty or "*" allows everything.
+	Operations []string `json:"operations,omitempty"`
+}
+
+// UsePathStyle reports whether the profile uses path-style addressing
+func (p *StorageProfile) UsePathStyle() bool {
+	return p.PathStyle == nil || *p.PathStyle
+}
+
+// expandEnv replaces ${VAR} references in the profile's string fields
+func (p *StorageProfile) expandEnv() {
+	for _, field := range []*string{&p.Name, &p.Endpoint, &p.Region, &p.Bucket, &p.AccessKey, &p.SecretKey, &p.PublicEndpoint} {
+		*field = os.ExpandEnv(*field)
+	}
+	for i, op := range p.Operations {
+		p.Operations[i] = os.ExpandEnv(op)
+	}
+}
+
+// Allows reports whether the profile permits an action type
+func (p *StorageProfile) Allows(actionType string) bool {
+	if len(p.Operations) == 0 {
+		return true
+	}
+	for _, op := range p.Operations {
+		if op == "*" || strings.EqualFold(op, actionType) {
+			return true
+		}
+	}
+	return false
+}
+
+// profileFile is the on-disk format of S3_PROFILES_FILE
+type profileFile struct {
+	Default  string           `json:"default,omitempty"`
+	Profiles []StorageProfile `json:"profiles"`
+}
+
+// profileRegistry holds the configured storage profiles
+type profileRegistry struct {
+	mu          sync.RWMutex
+	profiles    map[string]*StorageProfile
+	defaultName string
+}
+
+// profiles is the service-wide storage profile registry, populated at startup
+var profiles = newProfileRegistry()
+
+func newProfileRegistry() *profileRegistry {
+	return &profileRegistry{profiles: map[string]*StorageProfile{}}
+}
+
+// Add registers a profile, replacing any profile with the same name
+func (r *profileRegistry) Add(profile StorageProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("storage profile name is required")
+	}
+	if profile.Endpoint == "" {
+		return fmt.Errorf("storage profile %q: endpoint is required", profile.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.profiles[profile.Name] = &profile
+	return nil
+}
+
+// Get returns a profile by name
+func (r *profileRegistry) Get(name string) (*StorageProfile, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	profile, ok := r.profiles[name]
+	return profile, ok
+}
+
+// Default returns the default profile: the configured default, or the only profile
+func (r *profileRegistry) Default() (*StorageProfile, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.defaultName != "" {
+		profile, ok := r.profiles[r.defaultName]
+		return profile, ok
+	}
+	if len(r.profiles) == 1 {
+		for _, profile := range r.profiles {
+			return profile, true
+		}
+	}
+	return nil, false
+}
+
+// SetDefault selects the default profile by name
+func (r *profileRegistry) SetDefault(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultName = name
+}
+
+// Names returns the registered profile names, sorted
+func (r *profileRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.profiles))
+	for name := range r.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadFile reads profiles from a JSON file. ${VAR} references in string values are
+// expanded from the environment so secrets can stay out of the file. Expansion runs
+// after parsing, so values may contain characters JSON would need escaped.
+func (r *profileRegistry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read profiles file: %w", err)
+	}
+
+	var file profileFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse profiles file %s: %w", path, err)
+	}
+	file.Default = os.ExpandEnv(file.Default)
+
+	for _, profile := range file.Profiles {
+		profile.expandEnv()
+		if err := r.Add(profile); err != nil {
+			return err
+		}
+	}
+	if file.Default != "" {
+		if _, ok := r.Get(file.Default); !ok {
+			return fmt.Errorf("default profile %q is not defined", file.Default)
+		}
+		r.SetDefault(file.Default)
+	}
+	return nil
+}
+
+// LoadEnv registers profiles from the environment:
+//
+//	S3_PROFILE_<NAME>_URL, _PUBLIC_URL, _REGION, _BUCKET, _ACCESS_KEY, _SECRET_KEY, _PATH_STYLE, _OPERATIONS
+//
+// and a "hetzner" profile from HETZNER_S3_URL, HETZNER_S3_PUBLIC_URL, HETZNER_S3_REGION,
+// HETZNER_S3_BUCKET, HETZNER_S3_ACCESS_KEY and HETZNER_S3_SECRET_KEY. S3_DEFAULT_PROFILE selects the default.
+func (r *profileRegistry) LoadEnv(environ []string) error {
+	env := map[string]string{}
+	for _, entry := range environ {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			env[key] = value
+		}
+	}
+
+	// Collect S3_PROFILE_<NAME>_<FIELD> variables per profile. PUBLIC_URL is matched
+	// before URL so it is not read as the URL of a profile named "<NAME>_PUBLIC".
+	fields := []string{"PUBLIC_URL", "URL", "REGION", "BUCKET", "ACCESS_KEY", "SECRET_KEY", "PATH_STYLE", "OPERATIONS"}
+	named := map[string]map[string]string{}
+	for key, value := range env {
+		rest, ok := strings.CutPrefix(key, "S3_PROFILE_")
+		if !ok {
+			continue
+		}
+		for _, field := range fields {
+			if name, ok := strings.CutSuffix(rest, "_"+field); ok && name != "" {
+				profileName := strings.ToLower(name)
+				if named[profileName] == nil {
+					named[profileName] = map[string]string{}
+				}
+				named[profileName][field] = value
+				break
+			}
+		}
+	}
+
+	if accessKey := env["HETZNER_S3_ACCESS_KEY"]; accessKey != "" {
+		values := map[string]string{
+			"URL":        env["HETZNER_S3_URL"],
+			"PUBLIC_URL": env["HETZNER_S3_PUBLIC_URL"],
+			"REGION":     env["HETZNER_S3_REGION"],
+			"BUCKET":     env["HETZNER_S3_BUCKET"],
+			"ACCESS_KEY": accessKey,
+			"SECRET_KEY": env["HETZNER_S3_SECRET_KEY"],
+		}
+		if values["URL"] == "" {
+			values["URL"] = "https://fsn1.your-objectstorage.com"
+		}
+		if values["REGION"] == "" {
+			values["REGION"] = "fsn1"
+		}
+		if _, exists := named["hetzner"]; !exists {
+			named["hetzner"] = values
+		}
+	}
+
+	for name, values := range named {
+		profile := StorageProfile{
+			Name:      name,
+			Endpoint:  values["URL"],
+			Region:    values["REGION"],
+			Bucket:    values["BUCKET"],
+			AccessKey: values["ACCESS_KEY"],
+			SecretKey: values["SECRET_KEY"],
+
+			PublicEndpoint: values["PUBLIC_URL"],
+		}
+		if value := values["PATH_STYLE"]; value != "" {
+			pathStyle, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("storage profile %q: invalid PATH_STYLE %q", name, value)
+			}
+			profile.PathStyle = &pathStyle
+		}
+		if value := values["OPERATIONS"]; value != "" {
+			for _, op := range strings.Split(value, ",") {
+				if op = strings.TrimSpace(op); op != "" {
+					profile.Operations = append(profile.Operations, op)
+				}
+			}
+		}
+		if err := r.Add(profile); err != nil {
+			return err
+		}
+	}
+
+	if name := env["S3_DEFAULT_PROFILE"]; name != "" {
+		if _, ok := r.Get(name); !ok {
+			return fmt.Errorf("default profile %q is not defined", name)
+		}
+		r.SetDefault(name)
+	}
+	return nil
+}
+
+// loadProfiles populates the service registry from S3_PROFILES_FILE and the environment
+func loadProfiles() error {
+	if path := os.Getenv("S3_PROFILES_FILE"); path != "" {
+		if err := profiles.LoadFile(path); err != nil {
+			return err
+		}
+	}
+	return profiles.LoadEnv(os.Environ())
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProfileRegistry_LoadEnv(t *testing.T) {
+	registry := newProfileRegistry()
+	err := registry.LoadEnv([]string{
+		"S3_PROFILE_BACKUPS_URL=https://nbg1.your-objectstorage.com",
+		"S3_PROFILE_BACKUPS_PUBLIC_URL=https://files.example.com",
+		"S3_PROFILE_BACKUPS_REGION=nbg1",
+		"S3_PROFILE_BACKUPS_BUCKET=nightly",
+		"S3_PROFILE_BACKUPS_ACCESS_KEY=ak",
+		"S3_PROFILE_BACKUPS_SECRET_KEY=sk",
+		"S3_PROFILE_BACKUPS_PATH_STYLE=false",
+		"S3_PROFILE_BACKUPS_OPERATIONS=CreateAction, SearchAction",
+		"HETZNER_S3_ACCESS_KEY=hak",
+		"HETZNER_S3_SECRET_KEY=hsk",
+		"S3_DEFAULT_PROFILE=backups",
+	})
+	if err != nil {
+		t.Fatalf("LoadEnv failed: %v", err)
+	}
+
+	if got := registry.Names(); !reflect.DeepEqual(got, []string{"backups", "hetzner"}) {
+		t.Fatalf("unexpected profiles %v", got)
+	}
+
+	backups, _ := registry.Get("backups")
+	if backups.Endpoint != "https://nbg1.your-objectstorage.com" || backups.Region != "nbg1" || backups.Bucket != "nightly" {
+		t.Errorf("unexpected backups profile %+v", backups)
+	}
+	if backups.PublicEndpoint != "https://files.example.com" {
+		t.Errorf("expected the public endpoint on the backups profile, got %q", backups.PublicEndpoint)
+	}
+	if backups.AccessKey != "ak" || backups.SecretKey != "sk" {
+		t.Errorf("unexpected credentials %q/%q", backups.AccessKey, backups.SecretKey)
+	}
+	if backups.UsePathStyle() {
+		t.Error("expected virtual-host addressing")
+	}
+	if !backups.Allows("SearchAction") || backups.Allows("DeleteAction") {
+		t.Errorf("unexpected allow-list %v", backups.Operations)
+	}
+
+	hetzner, _ := registry.Get("hetzner")
+	if hetzner.Endpoint == "" || hetzner.Region != "fsn1" || !hetzner.UsePathStyle() {
+		t.Errorf("unexpected hetzner profile %+v", hetzner)
+	}
+
+	if def, ok := registry.Default(); !ok || def.Name != "backups" {
+		t.Errorf("expected backups as default, got %v", def)
+	}
+}
+
+func TestProfileRegistry_LoadFile(t *testing.T) {
+	t.Setenv("TEST_PROFILE_SECRET", `from-env "quoted" \ and $`)
+
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	data := `{
+  "default": "archive",
+  "profiles": [
+    {"name": "archive", "endpoint": "http://localhost:9000", "bucket": "archive", "secretKey": "${TEST_PROFILE_SECRET}"},
+    {"name": "media", "endpoint": "https://s3.amazonaws.com", "region": "eu-central-1", "pathStyle": false}
+  ]
+}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := newProfileRegistry()
+	if err := registry.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+
+	archive, ok := registry.Get("archive")
+	if !ok || archive.SecretKey != `from-env "quoted" \ and $` {
+		t.Fatalf("expected expanded secret, got %+v", archive)
+	}
+	media, _ := registry.Get("media")
+	if media.UsePathStyle() {
+		t.Error("expected media to use virtual-host addressing")
+	}
+	if def, ok := registry.Default(); !ok || def.Name != "archive" {
+		t.Errorf("expected archive as default, got %v", def)
+	}
+}
+
+func TestProfileRegistry_Errors(t *testing.T) {
+	registry := newProfileRegistry()
+	if err := registry.Add(StorageProfile{Name: "x"}); err == nil {
+		t.Error("expected error for profile without endpoint")
+	}
+	if err := registry.LoadEnv([]string{"S3_DEFAULT_PROFILE=missing"}); err == nil {
+		t.Error("expected error for undefined default profile")
+	}
+	if err := registry.LoadEnv([]string{"S3_PROFILE_A_URL=http://x", "S3_PROFILE_A_PATH_STYLE=maybe"}); err == nil {
+		t.Error("expected error for invalid PATH_STYLE")
+	}
+}
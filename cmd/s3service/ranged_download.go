@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ============================================================================
+// Parallel Ranged Download
+// ============================================================================
+
+// rangeRetries is how many times a failed range is retried before the download fails
+const rangeRetries = 3
+
+// rangedDownloader fetches an object as concurrent byte ranges. Every range is pinned
+// to the ETag seen when the download started, so a concurrent overwrite fails the
+// download instead of mixing two versions. A non-empty versionID reads that version.
+type rangedDownloader struct {
+	client      *s3.Client
+	bucket      string
+	key         string
+	versionID   string
+	partSize    int64
+	concurrency int
+	size        int64
+	etag        string
+}
+
+// newRangedDownloader prepares a download of an object whose size and ETag are known
+func newRangedDownloader(client *s3.Client, bucketName, key, versionID string, size int64, etag string, opts multipartOptions) *rangedDownloader {
+	return &rangedDownloader{
+		client:      client,
+		bucket:      bucketName,
+		key:         key,
+		versionID:   versionID,
+		partSize:    opts.PartSize,
+		concurrency: opts.Concurrency,
+		size:        size,
+		etag:        etag,
+	}
+}
+
+// ranges splits the object into part-sized ranges
+func (d *rangedDownloader) ranges() []byteRange {
+	var ranges []byteRange
+	for start := int64(0); start < d.size; start += d.partSize {
+		end := start + d.partSize - 1
+		if end > d.size-1 {
+			end = d.size - 1
+		}
+		ranges = append(ranges, byteRange{Start: start, End: end})
+	}
+	return ranges
+}
+
+// fetchRange reads one range, retrying transient failures with backoff
+func (d *rangedDownloader) fetchRange(ctx context.Context, r byteRange, buf *bytes.Buffer) error {
+	var lastErr error
+	for attempt := 0; attempt <= rangeRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(100<<attempt) * time.Millisecond):
+			}
+		}
+
+		buf.Reset()
+		lastErr = d.readRange(ctx, r, buf)
+		if lastErr == nil {
+			return nil
+		}
+		// The object changed or the context ended: retrying cannot help
+		if s3StatusCode(lastErr) == http.StatusPreconditionFailed || ctx.Err() != nil {
+			break
+		}
+	}
+	return fmt.Errorf("range %d-%d: %w", r.Start, r.End, lastErr)
+}
+
+func (d *rangedDownloader) readRange(ctx context.Context, r byteRange, buf *bytes.Buffer) error {
+	input := &s3.GetObjectInput{
+		Bucket:    aws.String(d.bucket),
+		Key:       aws.String(d.key),
+		Range:     aws.String(fmt.Sprintf("bytes=%d-%d", r.Start, r.End)),
+		VersionId: optionalString(d.versionID),
+	}
+	if d.etag != "" {
+		input.IfMatch = aws.String(d.etag)
+	}
+
+	result, err := d.client.GetObject(ctx, input)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = result.Body.Close() }()
+
+	n, err := io.Copy(buf, result.Body)
+	if err != nil {
+		return err
+	}
+	if want := r.End - r.Start + 1; n != want {
+		return fmt.Errorf("short read: got %d of %d bytes", n, want)
+	}
+	reportProgress(ctx, n, 0)
+	return nil
+}
+
+// downloadTo writes all ranges to w concurrently, in any order
+func (d *rangedDownloader) downloadTo(ctx context.Context, w io.WriterAt) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan byteRange)
+	errs := make(chan error, d.concurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < d.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			for r := range jobs {
+				err := d.fetchRange(ctx, r, &buf)
+				if err == nil {
+					_, err = w.WriteAt(buf.Bytes(), r.Start)
+				}
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, r := range d.ranges() {
+		select {
+		case jobs <- r:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// rangeResult is a fetched range waiting to be written in order
+type rangeResult struct {
+	buf *bytes.Buffer
+	err error
+}
+
+// streamTo writes the object to w in order while fetching ahead. At most
+// concurrency+1 ranges are buffered, which bounds memory to about
+// (concurrency+1) * partSize regardless of object size.
+func (d *rangedDownloader) streamTo(ctx context.Context, w io.Writer) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pending := make(chan chan rangeResult, d.concurrency)
+	go func() {
+		defer close(pending)
+		for _, r := range d.ranges() {
+			result := make(chan rangeResult, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return
+			}
+			go func(r byteRange) {
+				buf := &bytes.Buffer{}
+				result <- rangeResult{buf: buf, err: d.fetchRange(ctx, r, buf)}
+			}(r)
+		}
+	}()
+
+	var written int64
+	for result := range pending {
+		res := <-result
+		if res.err != nil {
+			return written, res.err
+		}
+		n, err := w.Write(res.buf.Bytes())
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return written, err
+	}
+	if written != d.size {
+		return written, fmt.Errorf("size mismatch: wrote %d of %d bytes", written, d.size)
+	}
+	return written, nil
+}
+
+// fileDownload describes a completed download to a server-side file
+type fileDownload struct {
+	Size      int64
+	ETag      string
+	VersionID string
+	Parallel  bool
+	Parts     int
+	Checksums map[string][]byte
+}
+
+// downloadObjectToFile writes an object to path. Objects at least opts.Threshold bytes
+// are fetched as parallel ranges; smaller ones with a single GetObject. The written size
+// is checked and the content is verified against the digests in spec plus the checksums
+// S3 stores for the object (including a single-part ETag's MD5). A partial or mismatching
+// file is removed. A non-empty versionID downloads that version instead of the latest.
+func downloadObjectToFile(ctx context.Context, client *s3.Client, bucketName, key, versionID, path string, opts multipartOptions, spec checksumSpec) (result *fileDownload, err error) {
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(bucketName),
+		Key:          aws.String(key),
+		VersionId:    optionalString(versionID),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return nil, err
+	}
+	size := aws.ToInt64(head.ContentLength)
+	etag := aws.ToString(head.ETag)
+	spec.expectStored(head)
+
+	outFile, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := outFile.Close(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+		if err != nil {
+			result = nil
+			_ = os.Remove(path)
+		}
+	}()
+
+	result = &fileDownload{Size: size, ETag: etag, VersionID: aws.ToString(head.VersionId)}
+	hashes := newChecksumWriter(spec.Compute)
+	if size >= opts.Threshold {
+		downloader := newRangedDownloader(client, bucketName, key, versionID, size, etag, opts)
+		result.Parallel = true
+		result.Parts = len(downloader.ranges())
+		if err = downloader.downloadTo(ctx, outFile); err != nil {
+			return nil, err
+		}
+		// Ranges arrive out of order, so hash the finished file in one pass
+		if _, err = outFile.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err = io.Copy(hashes, outFile); err != nil {
+			return nil, err
+		}
+	} else {
+		object, getErr := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket:    aws.String(bucketName),
+			Key:       aws.String(key),
+			VersionId: optionalString(versionID),
+			IfMatch:   head.ETag,
+		})
+		if getErr != nil {
+			return nil, getErr
+		}
+		defer func() { _ = object.Body.Close() }()
+
+		// Hash while writing so small downloads are not read twice
+		if _, err = io.Copy(io.MultiWriter(outFile, hashes), object.Body); err != nil {
+			return nil, err
+		}
+		reportProgress(ctx, size, 0)
+	}
+
+	info, err := outFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() != size {
+		return nil, fmt.Errorf("size mismatch: wrote %d of %d bytes", info.Size(), size)
+	}
+
+	result.Checksums = hashes.sums()
+	if err = spec.verify(result.Checksums); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// etagMD5 returns the hex MD5 carried by a single-part ETag, or "" when the ETag
+// is not a content hash (multipart uploads, SSE-KMS)
+func etagMD5(etag string) string {
+	value := strings.Trim(etag, `"`)
+	if len(value) != 32 || strings.Contains(value, "-") {
+		return ""
+	}
+	if _, err := hex.DecodeString(value); err != nil {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/labstack/echo/v4"
+)
+
+func TestDownloadObjectToFile_Parallel(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "ranged"))
+
+	data := testPayload(3*minPartSize + 777)
+	fake.objects["bucket/big.bin"] = data
+	fake.truncateGets = 1
+
+	path := filepath.Join(t.TempDir(), "big.bin")
+	result, err := downloadObjectToFile(context.Background(), client, "bucket", "big.bin", "", path, testMultipartOptions(), checksumSpec{})
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if !result.Parallel || result.Parts != 4 {
+		t.Errorf("expected a 4-range parallel download, got %+v", result)
+	}
+
+	written, _ := os.ReadFile(path)
+	if !bytes.Equal(written, data) {
+		t.Fatalf("downloaded file does not match object (%d of %d bytes)", len(written), len(data))
+	}
+	if len(result.Checksums[checksumMD5]) == 0 {
+		t.Error("expected the ETag MD5 to be verified")
+	}
+	if got := fake.count("GET /bucket/big.bin"); got != 5 {
+		t.Errorf("expected 4 ranges plus one retry, got %d GETs", got)
+	}
+}
+
+func TestDownloadObjectToFile_Small(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "ranged-small"))
+	fake.objects["bucket/small.txt"] = []byte("hello")
+
+	path := filepath.Join(t.TempDir(), "small.txt")
+	result, err := downloadObjectToFile(context.Background(), client, "bucket", "small.txt", "", path, testMultipartOptions(), checksumSpec{})
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if result.Parallel || result.Size != 5 {
+		t.Errorf("expected a single 5-byte GetObject, got %+v", result)
+	}
+}
+
+func TestDownloadObjectToFile_MissingRemovesFile(t *testing.T) {
+	_, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "ranged-missing"))
+
+	path := filepath.Join(t.TempDir(), "missing.bin")
+	if _, err := downloadObjectToFile(context.Background(), client, "bucket", "missing.bin", "", path, testMultipartOptions(), checksumSpec{}); err == nil {
+		t.Fatal("expected an error for a missing object")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected no local file to be left behind")
+	}
+}
+
+func TestRangedDownloader_StreamTo(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "ranged-stream"))
+
+	data := testPayload(5*minPartSize + 99)
+	fake.objects["bucket/stream.bin"] = data
+
+	downloader := newRangedDownloader(client, "bucket", "stream.bin", "", int64(len(data)), etagOf(data), testMultipartOptions())
+	var out bytes.Buffer
+	n, err := downloader.streamTo(context.Background(), &out)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if n != int64(len(data)) || !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("streamed content does not match object")
+	}
+}
+
+func TestStreamObjectParallel_VerifiesStoredChecksum(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "ranged-stream-checksum"))
+
+	data := testPayload(3*minPartSize + 7)
+	sum := sha256.Sum256(data)
+	wrong := sha256.Sum256([]byte("other content"))
+	e := echo.New()
+
+	for name, stored := range map[string][]byte{"match": sum[:], "mismatch": wrong[:]} {
+		fake.objects["bucket/"+name] = data
+		fake.stored["bucket/"+name] = http.Header{"X-Amz-Checksum-Sha256": {base64.StdEncoding.EncodeToString(stored)}}
+		head, err := client.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String(name)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+		err = streamObjectParallel(context.Background(), c, client, "bucket", name, "", "", "attachment", head, testMultipartOptions(), checksumSpec{})
+		if !bytes.Equal(rec.Body.Bytes(), data) {
+			t.Errorf("%s: streamed content does not match object", name)
+		}
+		if name == "match" && err != nil {
+			t.Errorf("match: unexpected error %v", err)
+		}
+		if name == "mismatch" && (err == nil || !strings.Contains(err.Error(), "sha256 checksum mismatch")) {
+			t.Errorf("mismatch: expected a sha256 mismatch, got %v", err)
+		}
+	}
+}
+
+func TestDownloadObjectToFile_ChecksumMismatchRemovesFile(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "ranged-checksum"))
+	fake.objects["bucket/data.txt"] = []byte("actual content")
+
+	wrong := sha256.Sum256([]byte("expected content"))
+	spec := checksumSpec{Expected: map[string][]byte{checksumSHA256: wrong[:]}, Compute: []string{checksumSHA256}}
+
+	path := filepath.Join(t.TempDir(), "data.txt")
+	_, err := downloadObjectToFile(context.Background(), client, "bucket", "data.txt", "", path, testMultipartOptions(), spec)
+	if err == nil || !strings.Contains(err.Error(), "sha256 checksum mismatch") {
+		t.Fatalf("expected a sha256 mismatch, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected the mismatching file to be removed")
+	}
+}
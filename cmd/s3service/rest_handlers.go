@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 )
@@ -13,35 +15,215 @@ import (
 // REST endpoint request types
 
 type UploadObjectRequest struct {
-	Key     string `json:"key"`
-	Content string `json:"content"` // base64 encoded
-	Bucket  string `json:"bucket,omitempty"`
+	Key                string            `json:"key"`
+	Content            string            `json:"content"` // base64 encoded
+	ContentType        string            `json:"contentType,omitempty"`
+	CacheControl       string            `json:"cacheControl,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	ContentEncoding    string            `json:"contentEncoding,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	Bucket             string            `json:"bucket,omitempty"`
+}
+
+type UpdateObjectRequest struct {
+	ContentType        string            `json:"contentType,omitempty"`
+	CacheControl       string            `json:"cacheControl,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	ContentEncoding    string            `json:"contentEncoding,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	ReplaceMetadata    bool              `json:"replaceMetadata,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	ReplaceTags        bool              `json:"replaceTags,omitempty"`
+}
+
+type ObjectTagsRequest struct {
+	Tags map[string]string `json:"tags"`
 }
 
 type CreateBucketRequest struct {
-	Name string `json:"name"`
+	Name       string `json:"name"`
+	Region     string `json:"region,omitempty"`
+	Versioning bool   `json:"versioning,omitempty"`
+	ObjectLock bool   `json:"objectLock,omitempty"`
+}
+
+type BucketVersioningRequest struct {
+	Status string `json:"status"` // Enabled or Suspended
+}
+
+type LifecycleRequest struct {
+	Rules []interface{} `json:"rules"` // validated by the lifecycle action
+}
+
+type RestoreVersionRequest struct {
+	Key       string `json:"key"`
+	VersionID string `json:"versionId"`
+	Bucket    string `json:"bucket,omitempty"`
+}
+
+type PresignRequest struct {
+	Key               string      `json:"key"`
+	Method            string      `json:"method,omitempty"`    // GET (default), PUT or POST
+	ExpiresIn         interface{} `json:"expiresIn,omitempty"` // seconds or Go duration
+	VersionID         string      `json:"versionId,omitempty"`
+	ContentType       string      `json:"contentType,omitempty"`
+	ContentLength     *int64      `json:"contentLength,omitempty"`
+	UploadID          string      `json:"uploadId,omitempty"`
+	PartNumber        int         `json:"partNumber,omitempty"`
+	KeyPrefix         string      `json:"keyPrefix,omitempty"`         // POST only
+	ContentTypePrefix string      `json:"contentTypePrefix,omitempty"` // POST only
+	MinContentLength  *int64      `json:"minContentLength,omitempty"`  // POST only
+	MaxContentLength  *int64      `json:"maxContentLength,omitempty"`  // POST only
+	Bucket            string      `json:"bucket,omitempty"`
+}
+
+type UploadCallbackRequest struct {
+	Key       string `json:"key"`
+	Policy    string `json:"policy"`    // the policy form field
+	Signature string `json:"signature"` // the X-Amz-Signature form field
+	Bucket    string `json:"bucket,omitempty"`
+}
+
+type SyncRequest struct {
+	Directory   string   `json:"directory"`
+	Prefix      string   `json:"prefix,omitempty"`
+	Direction   string   `json:"direction,omitempty"` // upload (default) or download
+	Compare     string   `json:"compare,omitempty"`   // size, mtime (default), etag or checksum
+	Delete      bool     `json:"delete,omitempty"`
+	MaxDeletes  int      `json:"maxDeletes,omitempty"`
+	DryRun      bool     `json:"dryRun,omitempty"`
+	Include     []string `json:"include,omitempty"`
+	Exclude     []string `json:"exclude,omitempty"`
+	Concurrency int      `json:"concurrency,omitempty"`
+	Bucket      string   `json:"bucket,omitempty"`
+}
+
+type MigrationRequest struct {
+	SourceProfile      string `json:"sourceProfile"`
+	SourceBucket       string `json:"sourceBucket,omitempty"`
+	SourcePrefix       string `json:"sourcePrefix,omitempty"`
+	DestinationProfile string `json:"destinationProfile"`
+	DestinationBucket  string `json:"destinationBucket,omitempty"`
+	DestinationPrefix  string `json:"destinationPrefix,omitempty"` // default: sourcePrefix
+	Verify             string `json:"verify,omitempty"`            // size (default) or checksum
+	Concurrency        int    `json:"concurrency,omitempty"`
+}
+
+type CopyObjectRequest struct {
+	Source             string            `json:"source"`
+	SourceVersionID    string            `json:"sourceVersionId,omitempty"`
+	Destination        string            `json:"destination,omitempty"`
+	SourceBucket       string            `json:"sourceBucket,omitempty"`
+	DestinationBucket  string            `json:"destinationBucket,omitempty"`
+	SourceProfile      string            `json:"sourceProfile,omitempty"`
+	DestinationProfile string            `json:"destinationProfile,omitempty"`
+	ContentType        string            `json:"contentType,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
 }
 
 // registerRESTEndpoints adds REST endpoints that convert to semantic actions
 func registerRESTEndpoints(apiGroup *echo.Group, apiKeyMiddleware echo.MiddlewareFunc) {
+	// GET /v1/api/objects - List objects
+	apiGroup.GET("/objects", listObjectsREST, apiKeyMiddleware)
+
 	// POST /v1/api/objects - Upload object
 	apiGroup.POST("/objects", uploadObjectREST, apiKeyMiddleware)
 
-	// GET /v1/api/objects/:key - Download object
-	apiGroup.GET("/objects/:key", getObjectREST, apiKeyMiddleware)
+	// Client-driven multipart uploads (object key in the "key" query parameter)
+	apiGroup.POST("/uploads", initiateUploadREST, apiKeyMiddleware)
+	apiGroup.PUT("/uploads/:uploadId/parts/:partNumber", uploadPartREST, apiKeyMiddleware)
+	apiGroup.POST("/uploads/:uploadId/complete", completeUploadREST, apiKeyMiddleware)
+	apiGroup.DELETE("/uploads/:uploadId", abortUploadREST, apiKeyMiddleware)
+
+	// GET /v1/api/objects/*key - Download object (keys may contain slashes, ?versionId= pins a version)
+	apiGroup.GET("/objects/*", getObjectREST, apiKeyMiddleware)
+
+	// HEAD /v1/api/objects/*key - Object metadata without a body
+	apiGroup.HEAD("/objects/*", getObjectREST, apiKeyMiddleware)
+
+	// PATCH /v1/api/objects/*key - Change content type, cache headers or user metadata
+	apiGroup.PATCH("/objects/*", updateObjectREST, apiKeyMiddleware)
+
+	// GET, PUT and DELETE /v1/api/tags/*key - Read, replace or remove object tags
+	apiGroup.GET("/tags/*", getObjectTagsREST, apiKeyMiddleware)
+	apiGroup.PUT("/tags/*", putObjectTagsREST, apiKeyMiddleware)
+	apiGroup.DELETE("/tags/*", deleteObjectTagsREST, apiKeyMiddleware)
+
+	// DELETE /v1/api/objects/*key - Delete object (?versionId= deletes that version permanently)
+	apiGroup.DELETE("/objects/*", deleteObjectREST, apiKeyMiddleware)
+
+	// DELETE /v1/api/objects?prefix= - Delete every object under a prefix
+	apiGroup.DELETE("/objects", bulkDeleteREST, apiKeyMiddleware)
+
+	// POST /v1/api/copy and /v1/api/move - Copy or move an object
+	apiGroup.POST("/copy", copyObjectREST, apiKeyMiddleware)
+	apiGroup.POST("/move", copyObjectREST, apiKeyMiddleware)
+
+	// POST /v1/api/presign - Time-limited GET, PUT or multipart part URL, or a POST form
+	apiGroup.POST("/presign", presignREST, apiKeyMiddleware)
+
+	// POST /v1/api/presign/callback - Confirm a browser upload made with a POST form
+	apiGroup.POST("/presign/callback", uploadCallbackREST, apiKeyMiddleware)
 
-	// DELETE /v1/api/objects/:key - Delete object
-	apiGroup.DELETE("/objects/:key", deleteObjectREST, apiKeyMiddleware)
+	// POST /v1/api/sync - Mirror a server directory to a prefix or a prefix to a directory
+	apiGroup.POST("/sync", syncREST, apiKeyMiddleware)
+
+	// POST /v1/api/migrations - Start an async migration of a prefix between profiles
+	// DELETE /v1/api/migrations/:id - Cancel a running migration
+	apiGroup.POST("/migrations", startMigrationREST, apiKeyMiddleware)
+	apiGroup.DELETE("/migrations/:id", cancelMigrationREST, apiKeyMiddleware)
+
+	// GET /v1/api/actions and /v1/api/actions/:id - Actions recorded in the state manager
+	apiGroup.GET("/actions", listTrackedActionsREST, apiKeyMiddleware)
+	apiGroup.GET("/actions/:id", getTrackedActionREST, apiKeyMiddleware)
 
 	// GET /v1/api/buckets - List buckets
 	apiGroup.GET("/buckets", listBucketsREST, apiKeyMiddleware)
 
 	// POST /v1/api/buckets - Create bucket
 	apiGroup.POST("/buckets", createBucketREST, apiKeyMiddleware)
+
+	// DELETE /v1/api/buckets/:name - Delete bucket (?force=true empties it first)
+	apiGroup.DELETE("/buckets/:name", deleteBucketREST, apiKeyMiddleware)
+
+	// PUT /v1/api/buckets/:name/versioning - Enable or suspend versioning
+	apiGroup.PUT("/buckets/:name/versioning", bucketVersioningREST, apiKeyMiddleware)
+
+	// GET, PUT and DELETE /v1/api/buckets/:name/lifecycle - Read, replace (?dryRun=true
+	// reports affected objects instead) or remove lifecycle rules
+	apiGroup.GET("/buckets/:name/lifecycle", getLifecycleREST, apiKeyMiddleware)
+	apiGroup.PUT("/buckets/:name/lifecycle", putLifecycleREST, apiKeyMiddleware)
+	apiGroup.DELETE("/buckets/:name/lifecycle", deleteLifecycleREST, apiKeyMiddleware)
+
+	// GET /v1/api/versions - List object versions and delete markers
+	apiGroup.GET("/versions", listVersionsREST, apiKeyMiddleware)
+
+	// POST /v1/api/versions/restore - Make an earlier version current again
+	apiGroup.POST("/versions/restore", restoreVersionREST, apiKeyMiddleware)
+
+	// GET /v1/api/profiles - List storage profiles
+	apiGroup.GET("/profiles", listProfilesREST, apiKeyMiddleware)
 }
 
-// uploadObjectREST handles REST POST /v1/api/objects
+// uploadObjectREST handles REST POST /v1/api/objects.
+// Accepts JSON with base64 content, multipart/form-data with a "file" field,
+// or a raw body with the key in the "key" query parameter.
 func uploadObjectREST(c echo.Context) error {
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	switch {
+	case strings.HasPrefix(contentType, echo.MIMEApplicationJSON):
+		return uploadObjectJSON(c)
+	case strings.HasPrefix(contentType, echo.MIMEMultipartForm):
+		return uploadObjectMultipart(c)
+	default:
+		return uploadObjectRaw(c)
+	}
+}
+
+// uploadObjectJSON handles uploads sent as JSON with base64 content
+func uploadObjectJSON(c echo.Context) error {
 	var req UploadObjectRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
@@ -62,87 +244,829 @@ func uploadObjectREST(c echo.Context) error {
 			"@type":      "DigitalDocument",
 			"identifier": req.Key,
 			"text":       req.Content,
+			"encoding":   "base64",
 		},
 	}
+	if req.ContentType != "" {
+		action["object"].(map[string]interface{})["encodingFormat"] = req.ContentType
+	}
+	properties := metadataProperties(req.CacheControl, req.ContentDisposition, req.ContentEncoding, req.Metadata)
+	if len(req.Tags) > 0 {
+		properties["tags"] = req.Tags
+	}
+	if len(properties) > 0 {
+		action["additionalProperty"] = properties
+	}
 
 	if req.Bucket != "" {
-		action["instrument"] = map[string]interface{}{
-			"@type": "PropertyValue",
-			"name":  "bucket",
-			"value": req.Bucket,
+		action["instrument"] = bucketInstrument(req.Bucket)
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// uploadObjectMultipart handles uploads sent as multipart/form-data
+func uploadObjectMultipart(c echo.Context) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file field is required"})
+	}
+
+	key := c.FormValue("key")
+	if key == "" {
+		key = file.Filename
+	}
+	if key == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "key is required"})
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Failed to read file: %v", err)})
+	}
+	defer func() { _ = src.Close() }()
+
+	contentType := c.FormValue("contentType")
+	if contentType == "" {
+		contentType = file.Header.Get(echo.HeaderContentType)
+	}
+
+	c.Set(uploadBodyKey, &uploadBody{
+		Reader:      src,
+		Size:        file.Size,
+		ContentType: contentType,
+		Name:        file.Filename,
+	})
+
+	action := uploadAction(key, file.Filename, contentType, c.FormValue("bucket"))
+	if err := addHeaderMetadata(action, c.Request().Header); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return callSemanticHandler(c, action)
+}
+
+// uploadObjectRaw handles uploads sent as a raw request body
+func uploadObjectRaw(c echo.Context) error {
+	key := c.QueryParam("key")
+	if key == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "key query parameter is required"})
+	}
+
+	// The body is uploaded as it arrives, so its length must be known upfront
+	size := c.Request().ContentLength
+	if size < 0 {
+		return c.JSON(http.StatusLengthRequired, map[string]string{"error": "Content-Length is required"})
+	}
+	if size > maxObjectSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("body exceeds %d bytes", int64(maxObjectSize))})
+	}
+
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	c.Set(uploadBodyKey, &uploadBody{
+		Stream:      c.Request().Body,
+		Size:        size,
+		ContentType: contentType,
+	})
+
+	action := uploadAction(key, "", contentType, c.QueryParam("bucket"))
+	if err := addHeaderMetadata(action, c.Request().Header); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return callSemanticHandler(c, action)
+}
+
+// CompleteUploadRequest optionally lists the parts to assemble
+type CompleteUploadRequest struct {
+	Parts []completedPart `json:"parts,omitempty"`
+}
+
+// initiateUploadREST handles REST POST /v1/api/uploads?key=
+func initiateUploadREST(c echo.Context) error {
+	return multipartREST(c, "initiate", map[string]interface{}{})
+}
+
+// uploadPartREST handles REST PUT /v1/api/uploads/:uploadId/parts/:partNumber?key=
+func uploadPartREST(c echo.Context) error {
+	// The part is passed on to S3 as it arrives, so its length must be known upfront
+	size := c.Request().ContentLength
+	if size < 0 {
+		return c.JSON(http.StatusLengthRequired, map[string]string{"error": "Content-Length is required"})
+	}
+	if size > maxPartSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("part exceeds %d bytes", int64(maxPartSize))})
+	}
+
+	c.Set(uploadBodyKey, &uploadBody{
+		Stream: c.Request().Body,
+		Size:   size,
+	})
+
+	return multipartREST(c, "uploadPart", map[string]interface{}{
+		"partNumber": c.Param("partNumber"),
+	})
+}
+
+// completeUploadREST handles REST POST /v1/api/uploads/:uploadId/complete?key=
+func completeUploadREST(c echo.Context) error {
+	var req CompleteUploadRequest
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
 		}
 	}
 
+	properties := map[string]interface{}{}
+	if len(req.Parts) > 0 {
+		properties["parts"] = req.Parts
+	}
+	return multipartREST(c, "complete", properties)
+}
+
+// abortUploadREST handles REST DELETE /v1/api/uploads/:uploadId?key=
+func abortUploadREST(c echo.Context) error {
+	return multipartREST(c, "abort", map[string]interface{}{})
+}
+
+// multipartREST converts a multipart step to a CreateAction with the "multipart" option
+func multipartREST(c echo.Context, operation string, properties map[string]interface{}) error {
+	key := c.QueryParam("key")
+	if key == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "key query parameter is required"})
+	}
+
+	properties["multipart"] = operation
+	if uploadID := c.Param("uploadId"); uploadID != "" {
+		properties["uploadId"] = uploadID
+	}
+
+	object := map[string]interface{}{
+		"@type":      "DigitalDocument",
+		"identifier": key,
+	}
+	if contentType := c.QueryParam("contentType"); contentType != "" {
+		object["encodingFormat"] = contentType
+	}
+
+	action := map[string]interface{}{
+		"@context":           "https://schema.org",
+		"@type":              "CreateAction",
+		"object":             object,
+		"additionalProperty": properties,
+	}
+	if bucket := c.QueryParam("bucket"); bucket != "" {
+		action["instrument"] = bucketInstrument(bucket)
+	}
+
 	return callSemanticHandler(c, action)
 }
 
-// getObjectREST handles REST GET /v1/api/objects/:key
+// uploadAction builds a CreateAction for content attached to the request context
+func uploadAction(key, name, contentType, bucket string) map[string]interface{} {
+	object := map[string]interface{}{
+		"@type":      "DigitalDocument",
+		"identifier": key,
+	}
+	if name != "" {
+		object["name"] = name
+	}
+	if contentType != "" {
+		object["encodingFormat"] = contentType
+	}
+
+	action := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "CreateAction",
+		"object":   object,
+	}
+	if bucket != "" {
+		action["instrument"] = bucketInstrument(bucket)
+	}
+	return action
+}
+
+// metadataProperties collects stored headers and user metadata as action options
+func metadataProperties(cacheControl, contentDisposition, contentEncoding string, metadata map[string]string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	if cacheControl != "" {
+		properties["cacheControl"] = cacheControl
+	}
+	if contentDisposition != "" {
+		properties["contentDisposition"] = contentDisposition
+	}
+	if contentEncoding != "" {
+		properties["contentEncoding"] = contentEncoding
+	}
+	if len(metadata) > 0 {
+		properties["metadata"] = metadata
+	}
+	return properties
+}
+
+// addHeaderMetadata copies Cache-Control, Content-Disposition, Content-Encoding,
+// X-Amz-Meta-* and X-Amz-Tagging request headers of a raw or form upload to the action
+func addHeaderMetadata(action map[string]interface{}, header http.Header) error {
+	metadata := map[string]string{}
+	for name, values := range header {
+		if suffix, ok := strings.CutPrefix(strings.ToLower(name), metadataHeaderPrefix); ok && suffix != "" && len(values) > 0 {
+			metadata[suffix] = values[0]
+		}
+	}
+
+	// A multipart/form-data request's Content-Disposition belongs to its parts, not the file
+	disposition := ""
+	if !strings.HasPrefix(header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		disposition = header.Get(echo.HeaderContentDisposition)
+	}
+	properties := metadataProperties(header.Get(echo.HeaderCacheControl), disposition, header.Get(echo.HeaderContentEncoding), metadata)
+
+	// X-Amz-Tagging is URL query encoded, as in S3 ("project=apollo&retention=1y")
+	if tagging := header.Get("X-Amz-Tagging"); tagging != "" {
+		values, err := url.ParseQuery(tagging)
+		if err != nil {
+			return fmt.Errorf("invalid X-Amz-Tagging header: %w", err)
+		}
+		tags := map[string]string{}
+		for key := range values {
+			tags[key] = values.Get(key)
+		}
+		properties["tags"] = tags
+	}
+
+	if len(properties) > 0 {
+		action["additionalProperty"] = properties
+	}
+	return nil
+}
+
+// bucketInstrument describes a bucket override as a PropertyValue instrument
+func bucketInstrument(bucket string) map[string]interface{} {
+	return map[string]interface{}{
+		"@type": "PropertyValue",
+		"name":  "bucket",
+		"value": bucket,
+	}
+}
+
+// listObjectsREST handles REST GET /v1/api/objects?prefix=&delimiter=&cursor=&maxKeys=&metadata=&tags=&tag=key=value
+func listObjectsREST(c echo.Context) error {
+	properties := map[string]interface{}{}
+	if delimiter := c.QueryParam("delimiter"); delimiter != "" {
+		properties["delimiter"] = delimiter
+	}
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		properties["continuationToken"] = cursor
+	}
+	if startAfter := c.QueryParam("startAfter"); startAfter != "" {
+		properties["startAfter"] = startAfter
+	}
+	if maxKeys := c.QueryParam("maxKeys"); maxKeys != "" {
+		properties["maxKeys"] = maxKeys
+	}
+	if c.QueryParam("all") == "true" {
+		properties["allPages"] = true
+	}
+	if c.QueryParam("metadata") == "true" {
+		properties["includeMetadata"] = true
+	}
+	if c.QueryParam("tags") == "true" {
+		properties["includeTags"] = true
+	}
+	if values := c.QueryParams()["tag"]; len(values) > 0 {
+		filter, err := tagFilterFromQuery(values)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		properties["tags"] = filter
+	}
+
+	// Convert to JSON-LD SearchAction
+	action := map[string]interface{}{
+		"@context":           "https://schema.org",
+		"@type":              "SearchAction",
+		"query":              c.QueryParam("prefix"),
+		"additionalProperty": properties,
+	}
+
+	if bucket := c.QueryParam("bucket"); bucket != "" {
+		action["instrument"] = bucketInstrument(bucket)
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// getObjectREST handles REST GET and HEAD /v1/api/objects/*key?versionId=
 func getObjectREST(c echo.Context) error {
-	key := c.Param("key")
+	key := objectKeyParam(c)
 	if key == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "key is required"})
 	}
 
 	bucket := c.QueryParam("bucket")
 
-	// Convert to JSON-LD SearchAction
+	// Convert to JSON-LD DownloadAction streamed back to the client
+	properties := map[string]interface{}{
+		"stream": true,
+	}
+	if disposition := c.QueryParam("disposition"); disposition != "" {
+		properties["disposition"] = disposition
+	}
+	if versionID := c.QueryParam("versionId"); versionID != "" {
+		properties["versionId"] = versionID
+	}
+
 	action := map[string]interface{}{
 		"@context": "https://schema.org",
-		"@type":    "SearchAction",
+		"@type":    "DownloadAction",
 		"object": map[string]interface{}{
 			"@type":      "DigitalDocument",
 			"identifier": key,
 		},
+		"additionalProperty": properties,
 	}
 
 	if bucket != "" {
-		action["instrument"] = map[string]interface{}{
-			"@type": "PropertyValue",
-			"name":  "bucket",
-			"value": bucket,
-		}
+		action["instrument"] = bucketInstrument(bucket)
 	}
 
 	return callSemanticHandler(c, action)
 }
 
-// deleteObjectREST handles REST DELETE /v1/api/objects/:key
+// updateObjectREST handles REST PATCH /v1/api/objects/*key
+func updateObjectREST(c echo.Context) error {
+	key := objectKeyParam(c)
+	if key == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "key is required"})
+	}
+
+	var req UpdateObjectRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
+	}
+
+	properties := metadataProperties(req.CacheControl, req.ContentDisposition, req.ContentEncoding, req.Metadata)
+	if req.ReplaceMetadata {
+		properties["metadataDirective"] = "REPLACE"
+	}
+	if req.Tags != nil {
+		properties["tags"] = req.Tags
+	}
+	if req.ReplaceTags {
+		properties["taggingDirective"] = "REPLACE"
+	}
+
+	object := map[string]interface{}{
+		"@type":      "DigitalDocument",
+		"identifier": key,
+	}
+	if req.ContentType != "" {
+		object["encodingFormat"] = req.ContentType
+	}
+
+	// Convert to JSON-LD UpdateAction
+	action := map[string]interface{}{
+		"@context":           "https://schema.org",
+		"@type":              "UpdateAction",
+		"object":             object,
+		"additionalProperty": properties,
+	}
+
+	if bucket := c.QueryParam("bucket"); bucket != "" {
+		action["instrument"] = bucketInstrument(bucket)
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// getObjectTagsREST handles REST GET /v1/api/tags/*key
+func getObjectTagsREST(c echo.Context) error {
+	return objectTagsREST(c, "DownloadAction", nil)
+}
+
+// putObjectTagsREST handles REST PUT /v1/api/tags/*key, replacing the object's tags
+func putObjectTagsREST(c echo.Context) error {
+	var req ObjectTagsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
+	}
+	if req.Tags == nil {
+		req.Tags = map[string]string{}
+	}
+	return objectTagsREST(c, "UpdateAction", map[string]interface{}{
+		"tags":             req.Tags,
+		"taggingDirective": "REPLACE",
+	})
+}
+
+// deleteObjectTagsREST handles REST DELETE /v1/api/tags/*key
+func deleteObjectTagsREST(c echo.Context) error {
+	return objectTagsREST(c, "DeleteAction", nil)
+}
+
+// objectTagsREST converts a tag request to an action on the object's tags. Reads and
+// deletes set the "tagging" option; writes are an UpdateAction with "tags".
+func objectTagsREST(c echo.Context, actionType string, properties map[string]interface{}) error {
+	key := objectKeyParam(c)
+	if key == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "key is required"})
+	}
+	if properties == nil {
+		properties = map[string]interface{}{"tagging": true}
+	}
+
+	action := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    actionType,
+		"object": map[string]interface{}{
+			"@type":      "DigitalDocument",
+			"identifier": key,
+		},
+		"additionalProperty": properties,
+	}
+
+	if bucket := c.QueryParam("bucket"); bucket != "" {
+		action["instrument"] = bucketInstrument(bucket)
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// deleteObjectREST handles REST DELETE /v1/api/objects/*key?versionId=
 func deleteObjectREST(c echo.Context) error {
-	key := c.Param("key")
+	key := objectKeyParam(c)
 	if key == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "key is required"})
 	}
 
 	bucket := c.QueryParam("bucket")
 
+	object := map[string]interface{}{
+		"@type":      "DigitalDocument",
+		"identifier": key,
+	}
+	if versionID := c.QueryParam("versionId"); versionID != "" {
+		object["version"] = versionID
+	}
+
 	// Convert to JSON-LD DeleteAction
 	action := map[string]interface{}{
 		"@context": "https://schema.org",
 		"@type":    "DeleteAction",
+		"object":   object,
+	}
+
+	if bucket != "" {
+		action["instrument"] = bucketInstrument(bucket)
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// bulkDeleteREST handles REST DELETE /v1/api/objects?prefix=...&dryRun=true&maxObjects=n
+func bulkDeleteREST(c echo.Context) error {
+	prefix := c.QueryParam("prefix")
+	if prefix == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "prefix is required"})
+	}
+
+	properties := map[string]interface{}{
+		"prefix": prefix,
+		"dryRun": c.QueryParam("dryRun") == "true",
+	}
+	if maxObjects := c.QueryParam("maxObjects"); maxObjects != "" {
+		properties["maxObjects"] = maxObjects
+	}
+
+	// Convert to JSON-LD DeleteAction
+	action := map[string]interface{}{
+		"@context":           "https://schema.org",
+		"@type":              "DeleteAction",
+		"additionalProperty": properties,
+	}
+
+	if bucket := c.QueryParam("bucket"); bucket != "" {
+		action["instrument"] = bucketInstrument(bucket)
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// copyObjectREST handles REST POST /v1/api/copy and /v1/api/move
+func copyObjectREST(c echo.Context) error {
+	var req CopyObjectRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
+	}
+
+	if req.Source == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "source is required"})
+	}
+
+	actionType := "TransferAction"
+	if strings.HasSuffix(c.Path(), "/move") {
+		actionType = "MoveAction"
+	}
+
+	properties := map[string]interface{}{}
+	if req.ContentType != "" {
+		properties["contentType"] = req.ContentType
+	}
+	if req.Metadata != nil {
+		properties["metadata"] = req.Metadata
+	}
+	if req.Tags != nil {
+		properties["tags"] = req.Tags
+	}
+	if req.SourceVersionID != "" {
+		properties["versionId"] = req.SourceVersionID
+	}
+
+	// Convert to JSON-LD TransferAction/MoveAction
+	action := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    actionType,
 		"object": map[string]interface{}{
-			"@type":      "DigitalDocument",
-			"identifier": key,
+			"@type":      "MediaObject",
+			"identifier": req.Source,
 		},
+		"additionalProperty": properties,
+	}
+	if req.Destination != "" {
+		action["targetUrl"] = req.Destination
+	}
+	if location := copyLocation(req.SourceProfile, req.SourceBucket); location != nil {
+		action["fromLocation"] = location
+	}
+	if location := copyLocation(req.DestinationProfile, req.DestinationBucket); location != nil {
+		action["toLocation"] = location
 	}
 
-	if bucket != "" {
-		action["instrument"] = map[string]interface{}{
-			"@type": "PropertyValue",
-			"name":  "bucket",
-			"value": bucket,
+	return callSemanticHandler(c, action)
+}
+
+// presignREST handles REST POST /v1/api/presign
+func presignREST(c echo.Context) error {
+	var req PresignRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
+	}
+	method := strings.ToUpper(req.Method)
+	if req.Key == "" && (method != http.MethodPost || req.KeyPrefix == "") {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "key is required"})
+	}
+
+	object := map[string]interface{}{
+		"@type": "DigitalDocument",
+	}
+	if req.Key != "" {
+		object["identifier"] = req.Key
+	}
+	properties := map[string]interface{}{
+		"presign": true,
+	}
+	if req.ExpiresIn != nil {
+		properties["expiresIn"] = req.ExpiresIn
+	}
+
+	// GET converts to a DownloadAction and PUT and POST to a CreateAction, so profile
+	// operation limits apply to presigned URLs as they do to direct transfers
+	var actionType string
+	switch method {
+	case "", http.MethodGet:
+		actionType = "DownloadAction"
+		if req.VersionID != "" {
+			properties["versionId"] = req.VersionID
+		}
+	case http.MethodPut:
+		actionType = "CreateAction"
+		if req.ContentType != "" {
+			object["encodingFormat"] = req.ContentType
+		}
+		if req.ContentLength != nil {
+			properties["contentLength"] = *req.ContentLength
+		}
+		if req.UploadID != "" {
+			properties["uploadId"] = req.UploadID
+			properties["partNumber"] = req.PartNumber
+		}
+	case http.MethodPost:
+		actionType = "CreateAction"
+		properties["httpMethod"] = http.MethodPost
+		if req.ContentType != "" {
+			object["encodingFormat"] = req.ContentType
 		}
+		if req.ContentLength != nil {
+			properties["contentLength"] = *req.ContentLength
+		}
+		if req.MinContentLength != nil {
+			properties["minContentLength"] = *req.MinContentLength
+		}
+		if req.MaxContentLength != nil {
+			properties["maxContentLength"] = *req.MaxContentLength
+		}
+		if req.KeyPrefix != "" {
+			properties["keyPrefix"] = req.KeyPrefix
+		}
+		if req.ContentTypePrefix != "" {
+			properties["contentTypePrefix"] = req.ContentTypePrefix
+		}
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "method must be GET, PUT or POST"})
+	}
+
+	// Convert to JSON-LD DownloadAction/CreateAction with presign
+	action := map[string]interface{}{
+		"@context":           "https://schema.org",
+		"@type":              actionType,
+		"object":             object,
+		"additionalProperty": properties,
+	}
+	if req.Bucket != "" {
+		action["instrument"] = bucketInstrument(req.Bucket)
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// uploadCallbackREST handles REST POST /v1/api/presign/callback
+func uploadCallbackREST(c echo.Context) error {
+	var req UploadCallbackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
+	}
+	if req.Key == "" || req.Policy == "" || req.Signature == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "key, policy and signature are required"})
+	}
+
+	// Convert to JSON-LD CreateAction with uploadCallback
+	action := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "CreateAction",
+		"object": map[string]interface{}{
+			"@type":      "DigitalDocument",
+			"identifier": req.Key,
+		},
+		"additionalProperty": map[string]interface{}{
+			"uploadCallback": true,
+			"policy":         req.Policy,
+			"signature":      req.Signature,
+		},
+	}
+	if req.Bucket != "" {
+		action["instrument"] = bucketInstrument(req.Bucket)
 	}
 
 	return callSemanticHandler(c, action)
 }
 
+// syncREST handles REST POST /v1/api/sync
+func syncREST(c echo.Context) error {
+	var req SyncRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
+	}
+	if req.Directory == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "directory is required"})
+	}
+
+	// Uploads convert to a CreateAction and downloads to a DownloadAction, so profile
+	// operation limits apply as they do to single transfers
+	var actionType string
+	switch strings.ToLower(req.Direction) {
+	case "", "upload":
+		actionType = "CreateAction"
+	case "download":
+		actionType = "DownloadAction"
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "direction must be upload or download"})
+	}
+
+	properties := map[string]interface{}{
+		"sync":   true,
+		"delete": req.Delete,
+		"dryRun": req.DryRun,
+	}
+	if req.Compare != "" {
+		properties["compare"] = req.Compare
+	}
+	if len(req.Include) > 0 {
+		properties["include"] = req.Include
+	}
+	if len(req.Exclude) > 0 {
+		properties["exclude"] = req.Exclude
+	}
+	if req.Concurrency > 0 {
+		properties["concurrency"] = req.Concurrency
+	}
+	if req.MaxDeletes > 0 {
+		properties["maxDeletes"] = req.MaxDeletes
+	}
+
+	// Convert to JSON-LD CreateAction/DownloadAction with sync
+	action := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    actionType,
+		"object": map[string]interface{}{
+			"@type":      "DigitalDocument",
+			"identifier": req.Prefix,
+			"contentUrl": req.Directory,
+		},
+		"additionalProperty": properties,
+	}
+	if req.Bucket != "" {
+		action["instrument"] = bucketInstrument(req.Bucket)
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// startMigrationREST handles REST POST /v1/api/migrations
+func startMigrationREST(c echo.Context) error {
+	var req MigrationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
+	}
+	if req.SourceProfile == "" || req.DestinationProfile == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "sourceProfile and destinationProfile are required"})
+	}
+
+	properties := map[string]interface{}{
+		"migrate": true,
+	}
+	if req.Verify != "" {
+		properties["verify"] = req.Verify
+	}
+	if req.Concurrency > 0 {
+		properties["concurrency"] = req.Concurrency
+	}
+
+	// Convert to JSON-LD TransferAction with migrate
+	action := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "TransferAction",
+		"object": map[string]interface{}{
+			"@type":      "Dataset",
+			"identifier": req.SourcePrefix,
+		},
+		"fromLocation":       copyLocation(req.SourceProfile, req.SourceBucket),
+		"toLocation":         copyLocation(req.DestinationProfile, req.DestinationBucket),
+		"additionalProperty": properties,
+	}
+	if req.DestinationPrefix != "" {
+		action["targetUrl"] = req.DestinationPrefix
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// cancelMigrationREST handles REST DELETE /v1/api/migrations/:id
+func cancelMigrationREST(c echo.Context) error {
+	id := c.Param("id")
+	if !actions.Cancel(id) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "no running migration " + id})
+	}
+	return c.JSON(http.StatusAccepted, map[string]string{"identifier": id, "actionStatus": actionStatusActive})
+}
+
+// listTrackedActionsREST handles REST GET /v1/api/actions, newest first
+func listTrackedActionsREST(c echo.Context) error {
+	return c.JSON(http.StatusOK, trackedActions.List())
+}
+
+// getTrackedActionREST handles REST GET /v1/api/actions/:id
+func getTrackedActionREST(c echo.Context) error {
+	action, ok := trackedActions.Get(c.Param("id"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "action not found"})
+	}
+	return c.JSON(http.StatusOK, action)
+}
+
+// copyLocation builds a DataCatalog naming a profile and/or bucket, or nil for the default
+func copyLocation(profile, bucket string) map[string]interface{} {
+	if profile == "" && bucket == "" {
+		return nil
+	}
+	location := map[string]interface{}{"@type": "DataCatalog"}
+	if profile != "" {
+		location["identifier"] = profile
+		if bucket != "" {
+			location["additionalProperty"] = map[string]interface{}{"bucket": bucket}
+		}
+	} else {
+		location["identifier"] = bucket
+	}
+	return location
+}
+
 // listBucketsREST handles REST GET /v1/api/buckets
 func listBucketsREST(c echo.Context) error {
-	// Convert to JSON-LD SearchAction
+	// Convert to JSON-LD SearchAction over DataCatalogs
 	action := map[string]interface{}{
 		"@context": "https://schema.org",
 		"@type":    "SearchAction",
-		"query":    "list-buckets",
+		"object": map[string]interface{}{
+			"@type": "DataCatalog",
+		},
 	}
 
 	return callSemanticHandler(c, action)
@@ -159,22 +1083,231 @@ func createBucketREST(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
 	}
 
-	// Convert to JSON-LD CreateAction
+	properties := map[string]interface{}{
+		"versioning": req.Versioning,
+		"objectLock": req.ObjectLock,
+	}
+	if req.Region != "" {
+		properties["region"] = req.Region
+	}
+
+	// Convert to JSON-LD CreateAction with a DataCatalog object
 	action := map[string]interface{}{
 		"@context": "https://schema.org",
 		"@type":    "CreateAction",
 		"object": map[string]interface{}{
-			"@type":      "Thing",
-			"name":       req.Name,
-			"identifier": "bucket",
+			"@type":              "DataCatalog",
+			"identifier":         req.Name,
+			"name":               req.Name,
+			"additionalProperty": properties,
 		},
 	}
 
 	return callSemanticHandler(c, action)
 }
 
-// callSemanticHandler converts action to JSON and calls the semantic action handler
+// deleteBucketREST handles REST DELETE /v1/api/buckets/:name
+func deleteBucketREST(c echo.Context) error {
+	name := c.Param("name")
+
+	// Convert to JSON-LD DeleteAction with a DataCatalog object
+	action := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "DeleteAction",
+		"object": map[string]interface{}{
+			"@type":      "DataCatalog",
+			"identifier": name,
+			"name":       name,
+			"additionalProperty": map[string]interface{}{
+				"force": c.QueryParam("force") == "true",
+			},
+		},
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// bucketVersioningREST handles REST PUT /v1/api/buckets/:name/versioning
+func bucketVersioningREST(c echo.Context) error {
+	var req BucketVersioningRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
+	}
+	if req.Status == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "status is required (Enabled or Suspended)"})
+	}
+
+	name := c.Param("name")
+
+	// Convert to JSON-LD UpdateAction with a DataCatalog object
+	action := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "UpdateAction",
+		"object": map[string]interface{}{
+			"@type":      "DataCatalog",
+			"identifier": name,
+			"name":       name,
+			"additionalProperty": map[string]interface{}{
+				"versioning": req.Status,
+			},
+		},
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// getLifecycleREST handles REST GET /v1/api/buckets/:name/lifecycle
+func getLifecycleREST(c echo.Context) error {
+	return lifecycleREST(c, "DownloadAction", map[string]interface{}{"lifecycle": true})
+}
+
+// putLifecycleREST handles REST PUT /v1/api/buckets/:name/lifecycle?dryRun=true&maxObjects=n
+func putLifecycleREST(c echo.Context) error {
+	var req LifecycleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
+	}
+	if len(req.Rules) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "rules are required; use DELETE to remove the configuration"})
+	}
+
+	properties := map[string]interface{}{
+		"lifecycleRules": req.Rules,
+		"dryRun":         c.QueryParam("dryRun") == "true",
+	}
+	if maxObjects := c.QueryParam("maxObjects"); maxObjects != "" {
+		properties["maxObjects"] = maxObjects
+	}
+	return lifecycleREST(c, "UpdateAction", properties)
+}
+
+// deleteLifecycleREST handles REST DELETE /v1/api/buckets/:name/lifecycle
+func deleteLifecycleREST(c echo.Context) error {
+	return lifecycleREST(c, "DeleteAction", map[string]interface{}{"lifecycle": true})
+}
+
+// lifecycleREST converts a lifecycle request to an action on a DataCatalog bucket
+func lifecycleREST(c echo.Context, actionType string, properties map[string]interface{}) error {
+	name := c.Param("name")
+
+	action := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    actionType,
+		"object": map[string]interface{}{
+			"@type":              "DataCatalog",
+			"identifier":         name,
+			"name":               name,
+			"additionalProperty": properties,
+		},
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// listVersionsREST handles REST GET /v1/api/versions?prefix=&delimiter=&keyMarker=&versionIdMarker=&maxKeys=
+func listVersionsREST(c echo.Context) error {
+	properties := map[string]interface{}{
+		"versions": true,
+	}
+	for _, name := range []string{"delimiter", "keyMarker", "versionIdMarker", "maxKeys"} {
+		if value := c.QueryParam(name); value != "" {
+			properties[name] = value
+		}
+	}
+
+	// Convert to JSON-LD SearchAction over versions
+	action := map[string]interface{}{
+		"@context":           "https://schema.org",
+		"@type":              "SearchAction",
+		"query":              c.QueryParam("prefix"),
+		"additionalProperty": properties,
+	}
+
+	if bucket := c.QueryParam("bucket"); bucket != "" {
+		action["instrument"] = bucketInstrument(bucket)
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// restoreVersionREST handles REST POST /v1/api/versions/restore
+func restoreVersionREST(c echo.Context) error {
+	var req RestoreVersionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid request: %v", err)})
+	}
+	if req.Key == "" || req.VersionID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "key and versionId are required"})
+	}
+
+	// Convert to JSON-LD ReplaceAction
+	action := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "ReplaceAction",
+		"object": map[string]interface{}{
+			"@type":      "DigitalDocument",
+			"identifier": req.Key,
+			"version":    req.VersionID,
+		},
+	}
+
+	if req.Bucket != "" {
+		action["instrument"] = bucketInstrument(req.Bucket)
+	}
+
+	return callSemanticHandler(c, action)
+}
+
+// listProfilesREST handles REST GET /v1/api/profiles. Credentials are never returned.
+func listProfilesREST(c echo.Context) error {
+	defaultName := ""
+	if profile, ok := profiles.Default(); ok {
+		defaultName = profile.Name
+	}
+
+	result := make([]map[string]interface{}, 0)
+	for _, name := range profiles.Names() {
+		profile, _ := profiles.Get(name)
+		result = append(result, map[string]interface{}{
+			"name":           profile.Name,
+			"endpoint":       profile.Endpoint,
+			"publicEndpoint": profile.PublicEndpoint,
+			"region":         profile.Region,
+			"bucket":         profile.Bucket,
+			"pathStyle":      profile.UsePathStyle(),
+			"operations":     profile.Operations,
+			"default":        profile.Name == defaultName,
+		})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// objectKeyParam returns the object key from the wildcard path parameter. Echo routes
+// on the raw path only when it differs from the decoded one (an escaped "/" in a key),
+// and only then is the parameter still escaped.
+func objectKeyParam(c echo.Context) string {
+	key := c.Param("*")
+	if c.Request().URL.RawPath != "" {
+		if unescaped, err := url.PathUnescape(key); err == nil {
+			key = unescaped
+		}
+	}
+	return strings.TrimPrefix(key, "/")
+}
+
+// callSemanticHandler converts action to JSON and calls the semantic action handler.
+// The "profile" query parameter selects a storage profile; without it the default profile is used.
 func callSemanticHandler(c echo.Context, action map[string]interface{}) error {
+	if profile := c.QueryParam("profile"); profile != "" {
+		if _, ok := action["target"]; !ok {
+			action["target"] = map[string]interface{}{
+				"@type":      "DataCatalog",
+				"identifier": profile,
+			}
+		}
+	}
+
 	// Marshal action to JSON
 	actionJSON, err := json.Marshal(action)
 	if err != nil {
@@ -192,6 +1325,11 @@ func callSemanticHandler(c echo.Context, action map[string]interface{}) error {
 	newCtx.SetParamNames(c.ParamNames()...)
 	newCtx.SetParamValues(c.ParamValues()...)
 
+	// Forward request content attached by the REST adapter
+	if body := c.Get(uploadBodyKey); body != nil {
+		newCtx.Set(uploadBodyKey, body)
+	}
+
 	// Call the existing semantic action handler
 	return handleSemanticAction(newCtx)
 }
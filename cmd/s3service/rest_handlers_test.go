@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestObjectKeyParam(t *testing.T) {
+	e := echo.New()
+	e.GET("/v1/api/objects/*", func(c echo.Context) error {
+		return c.String(http.StatusOK, objectKeyParam(c))
+	})
+
+	for path, want := range map[string]string{
+		"/v1/api/objects/docs/report.pdf":      "docs/report.pdf",
+		"/v1/api/objects/docs/hello%20world":   "docs/hello world",
+		"/v1/api/objects/a%2541":               "a%41", // a literal "%" is decoded once
+		"/v1/api/objects/docs%2Fnested%2Ffile": "docs/nested/file",
+		"/v1/api/objects/docs%2F100%2541":      "docs/100%41",
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := rec.Body.String(); got != want {
+			t.Errorf("%s: expected key %q, got %q", path, want, got)
+		}
+	}
+}
@@ -2,18 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"eve.evalgo.org/semantic"
-	"eve.evalgo.org/storage"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/labstack/echo/v4"
 )
@@ -26,6 +25,11 @@ func handleSemanticAction(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Failed to read request body: %v", err))
 	}
 
+	// An ItemList runs its actions as a batch
+	if list := batchList(body); list != nil {
+		return handleBatchAction(c, body, list)
+	}
+
 	// Parse as SemanticAction
 	action, err := semantic.ParseSemanticAction(body)
 	if err != nil {
@@ -34,35 +38,102 @@ func handleSemanticAction(c echo.Context) error {
 
 	// Dispatch to registered handler using the ActionRegistry
 	// No switch statement needed - handlers are registered at startup
-	return semantic.Handle(c, action)
+	handle := func(c echo.Context) error {
+		return semantic.Handle(c, action)
+	}
+
+	// Every action is tracked; async ones, and migrations, answer 202 and run in
+	// the worker pool
+	if !boolOption(action, "async") && !isMigrationAction(action) {
+		return actions.Run(c, actions.Begin(action), handle)
+	}
+	retry, _ := actionOption(action, "retry")
+	policy, err := parseRetryPolicy(retry)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Invalid retry policy: %v", err))
+	}
+	return acceptAsyncAction(c, newTrackedAction(action), body, handle, policy)
+}
+
+// acceptAsyncAction queues an async action and answers 202 with its tracked state,
+// or 200 with the earlier action when its identifier was already accepted
+func acceptAsyncAction(c echo.Context, tracked trackedAction, body []byte, handle actionFunc, policy retryPolicy) error {
+	tracked, duplicate, err := actions.Accept(tracked, body, handle, policy)
+	switch {
+	case errors.Is(err, errAsyncQueueFull):
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error(), "identifier": tracked.Identifier})
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Failed to accept action: %v", err))
+	}
+	c.Response().Header().Set(echo.HeaderLocation, "/v1/api/actions/"+tracked.Identifier)
+	if duplicate {
+		return c.JSON(http.StatusOK, tracked)
+	}
+	return c.JSON(http.StatusAccepted, tracked)
 }
 
-// executeUploadAction handles file upload to S3 operations
+// executeUploadAction handles file upload to S3 operations.
+// Content comes from the request body (REST), inline object.text, or a server-local contentUrl.
+// Large content is uploaded in parallel parts; server-local files resume after interruption.
 func executeUploadActionImpl(c echo.Context, action *semantic.SemanticAction) error {
-	ctx := context.Background()
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
 
-	// Extract S3 bucket and object using helpers
-	bucket, err := semantic.GetS3BucketFromAction(action)
+	// Resolve the storage profile (or inline target credentials) and bucket
+	target, err := resolveStorage(action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to extract S3 bucket", err)
+		return returnActionError(c, action, "Failed to resolve storage target", err)
 	}
+	bucketName := target.Bucket
 
 	object, err := semantic.GetS3ObjectFromAction(action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to extract S3 object", err)
+		return returnActionError(c, action, "Failed to extract S3 object", err)
 	}
 
-	// Extract S3 credentials
-	url, region, accessKey, secretKey, bucketName, err := semantic.ExtractS3Credentials(bucket)
-	_ = region // May be used for multi-region support
+	opts, err := multipartOptionsFromAction(action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to extract S3 credentials", err)
+		return returnActionError(c, action, "Invalid multipart options", err)
+	}
+
+	// Content sent with the request takes precedence over a server-local file
+	body := requestUploadBody(c)
+	if body == nil {
+		body, err = inlineUploadBody(action)
+		if err != nil {
+			return returnActionError(c, action, "Failed to decode object text", err)
+		}
 	}
 
-	// Get file path from object
+	// Server-local files are uploaded resumably: progress is keyed by path, size and mtime
+	var sourceInfo os.FileInfo
 	filePath := object.ContentUrl
-	if filePath == "" {
-		return semantic.ReturnActionError(c, action, "Object contentUrl (file path) is required", nil)
+	if body == nil {
+		if filePath == "" {
+			return returnActionError(c, action, "Object text or contentUrl (file path) is required", nil)
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return returnActionError(c, action, "Failed to open file", err)
+		}
+		defer func() { _ = file.Close() }()
+
+		sourceInfo, err = file.Stat()
+		if err != nil {
+			return returnActionError(c, action, "Failed to stat file", err)
+		}
+		body = &uploadBody{
+			Reader: file,
+			Size:   sourceInfo.Size(),
+			Name:   filepath.Base(filePath),
+		}
+	}
+	if body.ContentType == "" {
+		body.ContentType = object.EncodingFormat
 	}
 
 	// Determine S3 key
@@ -71,55 +142,111 @@ func executeUploadActionImpl(c echo.Context, action *semantic.SemanticAction) er
 		s3Key = object.Identifier
 	}
 	if s3Key == "" {
-		s3Key = filepath.Base(filePath)
-	}
-
-	// Use EVE's HetznerUploadFile function
-	if err := storage.HetznerUploadFile(ctx, url, accessKey, secretKey, bucketName, filePath, s3Key); err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to upload file", err)
-	}
-
-	// Get file info for result
-	fileInfo, err := os.Stat(filePath)
-	if err == nil {
-		// Use semantic Result structure
-		action.Result = &semantic.SemanticResult{
-			Type:   "DigitalDocument",
-			Format: object.EncodingFormat,
-			Value: map[string]interface{}{
-				"contentUrl":     fmt.Sprintf("s3://%s/%s", bucketName, s3Key),
-				"name":           filepath.Base(filePath),
-				"contentSize":    fileInfo.Size(),
-				"encodingFormat": object.EncodingFormat,
-				"uploadDate":     time.Now().Format(time.RFC3339),
-			},
+		s3Key = body.Name
+	}
+	if s3Key == "" {
+		return returnActionError(c, action, "Object identifier (S3 key) is required", nil)
+	}
+
+	// Stored headers and user metadata; without a content type, detect one
+	body.Attributes, err = objectMetadataFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid object metadata", err)
+	}
+	if body.ContentType == "" {
+		body.ContentType, err = detectContentType(body, s3Key)
+		if err != nil {
+			return returnActionError(c, action, "Failed to read upload content", err)
+		}
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	stateKey := ""
+	if sourceInfo != nil {
+		if absPath, err := filepath.Abs(filePath); err == nil {
+			stateKey = multipartStateKey(target, s3Key, absPath, sourceInfo.Size(), sourceInfo.ModTime())
 		}
 	}
 
+	// Expected digests and checksumAlgorithm are hashed while the content is sent;
+	// an object that does not match them is not kept
+	body.Checksums, err = checksumSpecFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid checksum", err)
+	}
+
+	upload, err := uploadObject(ctx, client, bucketName, s3Key, body, stateKey, opts)
+	if errors.Is(err, errChecksumMismatch) {
+		return returnActionError(c, action, "Checksum mismatch", err)
+	}
+	if err != nil {
+		return returnActionError(c, action, "Failed to upload file", err)
+	}
+
+	name := body.Name
+	if name == "" {
+		name = filepath.Base(s3Key)
+	}
+	value := map[string]interface{}{
+		"contentUrl":     fmt.Sprintf("s3://%s/%s", bucketName, s3Key),
+		"name":           name,
+		"contentSize":    body.Size,
+		"encodingFormat": body.ContentType,
+		"uploadDate":     time.Now().Format(time.RFC3339),
+	}
+	if upload.ETag != "" {
+		value["etag"] = upload.ETag
+	}
+	if upload.VersionID != "" {
+		value["version"] = upload.VersionID
+	}
+	body.Attributes.describe(value)
+	if tags, _, _ := tagsOption(action); len(tags) > 0 {
+		value["tags"] = propertyValues(tags)
+	}
+	for algorithm, digest := range checksumValues(upload.Checksums) {
+		value[algorithm] = digest
+	}
+	if upload.Multipart {
+		value["uploadId"] = upload.UploadID
+		value["parts"] = upload.Parts
+		value["resumedParts"] = upload.ResumedParts
+	}
+
+	// Use semantic Result structure
+	action.Result = &semantic.SemanticResult{
+		Type:   "DigitalDocument",
+		Format: body.ContentType,
+		Value:  value,
+	}
+
 	semantic.SetSuccessOnAction(action)
 	return c.JSON(http.StatusOK, action)
 }
 
-// executeDownloadAction handles file download from S3 operations
+// executeDownloadAction handles file download from S3 operations.
+// With the "stream" option the object is written to the response, otherwise to a server-local file.
 func executeDownloadActionImpl(c echo.Context, action *semantic.SemanticAction) error {
-	ctx := context.Background()
-
-	// Extract S3 bucket and object using helpers
-	bucket, err := semantic.GetS3BucketFromAction(action)
+	ctx, cancel, err := actionContext(c, action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to extract S3 bucket", err)
+		return returnActionError(c, action, "Invalid action options", err)
 	}
+	defer cancel()
 
-	object, err := semantic.GetS3ObjectFromAction(action)
+	// Resolve the storage profile (or inline target credentials) and bucket
+	target, err := resolveStorage(action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to extract S3 object", err)
+		return returnActionError(c, action, "Failed to resolve storage target", err)
 	}
+	bucketName := target.Bucket
 
-	// Extract S3 credentials
-	url, region, accessKey, secretKey, bucketName, err := semantic.ExtractS3Credentials(bucket)
-	_ = region // May be used for multi-region support
+	object, err := semantic.GetS3ObjectFromAction(action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to extract S3 credentials", err)
+		return returnActionError(c, action, "Failed to extract S3 object", err)
 	}
 
 	// Get S3 key from object
@@ -128,7 +255,18 @@ func executeDownloadActionImpl(c echo.Context, action *semantic.SemanticAction)
 		s3Key = object.Name
 	}
 	if s3Key == "" {
-		return semantic.ReturnActionError(c, action, "Object identifier (S3 key) is required", nil)
+		return returnActionError(c, action, "Object identifier (S3 key) is required", nil)
+	}
+
+	// Create S3 client
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	// Stream the object body to the caller instead of writing a server-side file
+	if boolOption(action, "stream") {
+		return streamObject(ctx, c, action, client, bucketName, s3Key, object.EncodingFormat)
 	}
 
 	// Determine local download path
@@ -137,44 +275,43 @@ func executeDownloadActionImpl(c echo.Context, action *semantic.SemanticAction)
 		downloadPath = filepath.Join("/tmp", filepath.Base(s3Key))
 	}
 
-	// Create S3 client
-	client, err := createS3Client(ctx, url, region, accessKey, secretKey)
+	opts, err := multipartOptionsFromAction(action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to create S3 client", err)
+		return returnActionError(c, action, "Invalid download options", err)
 	}
-
-	// Download file
-	result, err := client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(s3Key),
-	})
+	checksums, err := checksumSpecFromAction(action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to download file", err)
+		return returnActionError(c, action, "Invalid checksum", err)
 	}
-	defer func() { _ = result.Body.Close() }()
 
-	// Write to local file
-	outFile, err := os.Create(downloadPath)
+	// Download file (large objects as parallel ranges), verifying size and checksums
+	download, err := downloadObjectToFile(ctx, client, bucketName, s3Key, versionIDOption(action), downloadPath, opts, checksums)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to create local file", err)
+		return returnActionError(c, action, "Failed to download file", err)
 	}
-	defer func() { _ = outFile.Close() }()
 
-	size, err := io.Copy(outFile, result.Body)
-	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to write file", err)
+	value := map[string]interface{}{
+		"contentUrl":     downloadPath,
+		"name":           filepath.Base(s3Key),
+		"contentSize":    download.Size,
+		"encodingFormat": object.EncodingFormat,
+		"etag":           download.ETag,
+	}
+	if download.VersionID != "" {
+		value["version"] = download.VersionID
+	}
+	if download.Parallel {
+		value["parts"] = download.Parts
+	}
+	for algorithm, digest := range checksumValues(download.Checksums) {
+		value[algorithm] = digest
 	}
 
 	// Use semantic Result structure
 	action.Result = &semantic.SemanticResult{
 		Type:   "DigitalDocument",
 		Format: object.EncodingFormat,
-		Value: map[string]interface{}{
-			"contentUrl":     downloadPath,
-			"name":           filepath.Base(s3Key),
-			"contentSize":    size,
-			"encodingFormat": object.EncodingFormat,
-		},
+		Value:  value,
 	}
 
 	semantic.SetSuccessOnAction(action)
@@ -183,24 +320,22 @@ func executeDownloadActionImpl(c echo.Context, action *semantic.SemanticAction)
 
 // executeDeleteAction handles file deletion from S3 operations
 func executeDeleteActionImpl(c echo.Context, action *semantic.SemanticAction) error {
-	ctx := context.Background()
-
-	// Extract S3 bucket and object using helpers
-	bucket, err := semantic.GetS3BucketFromAction(action)
+	ctx, cancel, err := actionContext(c, action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to extract S3 bucket", err)
+		return returnActionError(c, action, "Invalid action options", err)
 	}
+	defer cancel()
 
-	object, err := semantic.GetS3ObjectFromAction(action)
+	// Resolve the storage profile (or inline target credentials) and bucket
+	target, err := resolveStorage(action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to extract S3 object", err)
+		return returnActionError(c, action, "Failed to resolve storage target", err)
 	}
+	bucketName := target.Bucket
 
-	// Extract S3 credentials
-	url, region, accessKey, secretKey, bucketName, err := semantic.ExtractS3Credentials(bucket)
-	_ = region // May be used for multi-region support
+	object, err := semantic.GetS3ObjectFromAction(action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to extract S3 credentials", err)
+		return returnActionError(c, action, "Failed to extract S3 object", err)
 	}
 
 	// Get S3 key from object
@@ -209,81 +344,116 @@ func executeDeleteActionImpl(c echo.Context, action *semantic.SemanticAction) er
 		s3Key = object.Name
 	}
 	if s3Key == "" {
-		return semantic.ReturnActionError(c, action, "Object identifier (S3 key) is required", nil)
+		return returnActionError(c, action, "Object identifier (S3 key) is required", nil)
 	}
 
 	// Create S3 client
-	client, err := createS3Client(ctx, url, region, accessKey, secretKey)
+	client, err := createS3Client(ctx, target)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to create S3 client", err)
+		return returnActionError(c, action, "Failed to create S3 client", err)
 	}
 
-	// Delete object
-	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(s3Key),
+	// Delete the object, or permanently remove one version when versionId is set.
+	// In a versioned bucket a plain delete only adds a delete marker.
+	versionID := versionIDOption(action)
+	output, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(s3Key),
+		VersionId: optionalString(versionID),
 	})
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to delete file", err)
+		return returnActionError(c, action, "Failed to delete file", err)
+	}
+
+	value := map[string]interface{}{
+		"@type":        "DigitalDocument",
+		"identifier":   s3Key,
+		"contentUrl":   fmt.Sprintf("s3://%s/%s", bucketName, s3Key),
+		"deleteMarker": aws.ToBool(output.DeleteMarker),
+	}
+	if version := aws.ToString(output.VersionId); version != "" {
+		value["version"] = version
+	}
+	action.Result = &semantic.SemanticResult{
+		Type:   "DigitalDocument",
+		Format: "application/json",
+		Value:  value,
 	}
 
 	semantic.SetSuccessOnAction(action)
 	return c.JSON(http.StatusOK, action)
 }
 
-// executeListAction handles listing objects in S3 bucket
+// executeListAction handles listing objects in S3 bucket.
+// Supports maxKeys, continuationToken, startAfter, delimiter, allPages, includeMetadata,
+// includeTags and tags (filter) options.
 func executeListActionImpl(c echo.Context, action *semantic.SemanticAction) error {
-	ctx := context.Background()
-
-	// Extract S3 bucket using helper
-	bucket, err := semantic.GetS3BucketFromAction(action)
+	ctx, cancel, err := actionContext(c, action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to extract S3 bucket", err)
+		return returnActionError(c, action, "Invalid action options", err)
 	}
+	defer cancel()
 
-	// Extract S3 credentials
-	url, region, accessKey, secretKey, bucketName, err := semantic.ExtractS3Credentials(bucket)
-	_ = region // May be used for multi-region support
+	// Resolve the storage profile (or inline target credentials) and bucket
+	target, err := resolveStorage(action)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to extract S3 credentials", err)
+		return returnActionError(c, action, "Failed to resolve storage target", err)
 	}
+	bucketName := target.Bucket
 
 	// Create S3 client
-	client, err := createS3Client(ctx, url, region, accessKey, secretKey)
+	client, err := createS3Client(ctx, target)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to create S3 client", err)
+		return returnActionError(c, action, "Failed to create S3 client", err)
 	}
 
-	// List objects with optional prefix from query
-	input := &s3.ListObjectsV2Input{
-		Bucket: aws.String(bucketName),
+	// List objects with optional prefix from query and paging options
+	opts := listOptions{
+		Delimiter:         stringOption(action, "delimiter"),
+		StartAfter:        stringOption(action, "startAfter"),
+		ContinuationToken: stringOption(action, "continuationToken"),
+		AllPages:          boolOption(action, "allPages"),
 	}
 	if query, ok := action.Properties["query"].(string); ok && query != "" {
-		input.Prefix = aws.String(query)
+		opts.Prefix = query
+	}
+	maxKeys, err := intOption(action, "maxKeys", 1000)
+	if err != nil {
+		return returnActionError(c, action, "Invalid listing options", err)
 	}
+	if maxKeys < 1 || maxKeys > 1000 {
+		return returnActionError(c, action, "maxKeys must be between 1 and 1000", nil)
+	}
+	opts.MaxKeys = int32(maxKeys)
 
-	result, err := client.ListObjectsV2(ctx, input)
+	page, err := listObjects(ctx, client, bucketName, opts)
 	if err != nil {
-		return semantic.ReturnActionError(c, action, "Failed to list objects", err)
+		return returnActionError(c, action, "Failed to list objects", err)
 	}
 
-	// Build result list
-	objects := make([]interface{}, 0, len(result.Contents))
-	for _, obj := range result.Contents {
-		objects = append(objects, map[string]interface{}{
-			"contentUrl":     fmt.Sprintf("s3://%s/%s", bucketName, *obj.Key),
-			"name":           filepath.Base(*obj.Key),
-			"contentSize":    *obj.Size,
-			"encodingFormat": "application/octet-stream",
-			"uploadDate":     obj.LastModified.Format(time.RFC3339),
-		})
+	// Listings carry no content type, metadata or tags; includeMetadata and
+	// includeTags read them per object, and a tags filter drops non-matching objects
+	if boolOption(action, "includeMetadata") {
+		if err := describeObjects(ctx, client, bucketName, page.Objects); err != nil {
+			return returnActionError(c, action, "Failed to read object metadata", err)
+		}
+	}
+	tagFilter, _, err := stringMapOption(action, "tags")
+	if err != nil {
+		return returnActionError(c, action, "Invalid tag filter", err)
+	}
+	if len(tagFilter) > 0 || boolOption(action, "includeTags") {
+		page.Objects, err = tagObjects(ctx, client, bucketName, page.Objects, tagFilter)
+		if err != nil {
+			return returnActionError(c, action, "Failed to read object tags", err)
+		}
 	}
 
 	// Use semantic Result structure for list results
 	action.Result = &semantic.SemanticResult{
 		Type:   "Dataset",
 		Format: "application/json",
-		Value:  objects,
+		Value:  page.datasetValue(bucketName, opts),
 	}
 
 	semantic.SetSuccessOnAction(action)
@@ -294,28 +464,41 @@ func executeListActionImpl(c echo.Context, action *semantic.SemanticAction) erro
 // Helper Functions
 // ============================================================================
 
-// createS3Client creates an AWS S3 client configured for Hetzner or other S3-compatible storage
-func createS3Client(ctx context.Context, endpoint, region, accessKey, secretKey string) (*s3.Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx,
-		config.WithRegion(region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
-	}
-
-	return s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(endpoint)
-		o.UsePathStyle = true
-	}), nil
+// createS3Client returns an S3 client for Hetzner or other S3-compatible storage.
+// Clients are cached in s3Clients and share one HTTP transport.
+func createS3Client(ctx context.Context, target *storageTarget) (*s3.Client, error) {
+	return s3Clients.Get(ctx, target)
 }
 
-// executeUploadAction wraps the implementation to match ActionHandler signature
-func executeUploadAction(c echo.Context, actionInterface interface{}) error {
+// executeCreateAction wraps the implementation to match ActionHandler signature.
+// CreateAction creates a bucket for DataCatalog objects, presigns an upload URL (or a
+// POST form with httpMethod POST) when the "presign" option is set, confirms browser
+// uploads with "uploadCallback", mirrors a directory to a prefix with "sync", runs
+// client-driven multipart steps when the "multipart" option is set, and uploads
+// anything else.
+func executeCreateAction(c echo.Context, actionInterface interface{}) error {
 	action, ok := actionInterface.(*semantic.SemanticAction)
 	if !ok {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action type")
 	}
+	if isBucketObject(action) {
+		return executeCreateBucketActionImpl(c, action)
+	}
+	if boolOption(action, "presign") {
+		if strings.EqualFold(stringOption(action, "httpMethod"), http.MethodPost) {
+			return executePresignPostActionImpl(c, action)
+		}
+		return executePresignActionImpl(c, action)
+	}
+	if boolOption(action, "uploadCallback") {
+		return executeUploadCallbackActionImpl(c, action)
+	}
+	if boolOption(action, "sync") {
+		return executeSyncActionImpl(c, action)
+	}
+	if stringOption(action, "multipart") != "" {
+		return executeMultipartActionImpl(c, action)
+	}
 	return executeUploadActionImpl(c, action)
 }
 
@@ -325,6 +508,18 @@ func executeDownloadAction(c echo.Context, actionInterface interface{}) error {
 	if !ok {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action type")
 	}
+	if isBucketObject(action) && boolOption(action, "lifecycle") {
+		return executeLifecycleActionImpl(c, action)
+	}
+	if boolOption(action, "presign") {
+		return executePresignActionImpl(c, action)
+	}
+	if boolOption(action, "tagging") {
+		return executeTaggingActionImpl(c, action)
+	}
+	if boolOption(action, "sync") {
+		return executeSyncActionImpl(c, action)
+	}
 	return executeDownloadActionImpl(c, action)
 }
 
@@ -334,6 +529,19 @@ func executeDeleteAction(c echo.Context, actionInterface interface{}) error {
 	if !ok {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action type")
 	}
+	if isBucketObject(action) {
+		// Removing the lifecycle configuration must never fall through to deleting the bucket
+		if boolOption(action, "lifecycle") {
+			return executeLifecycleActionImpl(c, action)
+		}
+		return executeDeleteBucketActionImpl(c, action)
+	}
+	if boolOption(action, "tagging") {
+		return executeTaggingActionImpl(c, action)
+	}
+	if isBulkDelete(action) {
+		return executeBulkDeleteActionImpl(c, action)
+	}
 	return executeDeleteActionImpl(c, action)
 }
 
@@ -343,5 +551,55 @@ func executeListAction(c echo.Context, actionInterface interface{}) error {
 	if !ok {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action type")
 	}
+	if isBucketObject(action) {
+		return executeListBucketsActionImpl(c, action)
+	}
+	if boolOption(action, "versions") {
+		return executeListVersionsActionImpl(c, action)
+	}
 	return executeListActionImpl(c, action)
 }
+
+// isMigrationAction reports whether an action is a TransferAction with the
+// "migrate" option, which migrates a whole prefix instead of copying one object
+func isMigrationAction(action *semantic.SemanticAction) bool {
+	return actionType(action) == "TransferAction" && boolOption(action, "migrate")
+}
+
+// executeCopyAction wraps the implementation to match ActionHandler signature.
+// A migration copies a whole prefix instead of one object.
+func executeCopyAction(c echo.Context, actionInterface interface{}) error {
+	action, ok := actionInterface.(*semantic.SemanticAction)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action type")
+	}
+	if isMigrationAction(action) {
+		return executeMigrationActionImpl(c, action)
+	}
+	return executeCopyActionImpl(c, action)
+}
+
+// executeUpdateAction wraps the implementation to match ActionHandler signature
+func executeUpdateAction(c echo.Context, actionInterface interface{}) error {
+	action, ok := actionInterface.(*semantic.SemanticAction)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action type")
+	}
+	if isBucketObject(action) {
+		if _, ok := actionOption(action, "lifecycleRules"); ok {
+			return executeLifecycleActionImpl(c, action)
+		}
+		return executeUpdateBucketActionImpl(c, action)
+	}
+	return executeUpdateActionImpl(c, action)
+}
+
+// executeReplaceAction wraps the implementation to match ActionHandler signature.
+// ReplaceAction restores an earlier version of an object as its current version.
+func executeReplaceAction(c echo.Context, actionInterface interface{}) error {
+	action, ok := actionInterface.(*semantic.SemanticAction)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action type")
+	}
+	return executeRestoreVersionActionImpl(c, action)
+}
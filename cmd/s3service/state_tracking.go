@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"eve.evalgo.org/statemanager"
+	"github.com/google/uuid"
+)
+
+// Action statuses, as Schema.org names them
+const (
+	actionStatusActive    = "ActiveActionStatus"
+	actionStatusCompleted = "CompletedActionStatus"
+	actionStatusFailed    = "FailedActionStatus"
+)
+
+// maxTrackedActions bounds how many operations the state manager keeps
+const maxTrackedActions = 100
+
+// trackedAction is the state the service keeps about an action it ran or observed,
+// such as an upload a browser made directly to the bucket
+type trackedAction struct {
+	Identifier   string      `json:"identifier"`
+	Type         string      `json:"@type"`
+	ActionStatus string      `json:"actionStatus"`
+	Object       interface{} `json:"object,omitempty"`
+	Target       interface{} `json:"target,omitempty"`
+	Result       interface{} `json:"result,omitempty"`
+	Error        string      `json:"error,omitempty"`
+	StartTime    time.Time   `json:"startTime"`
+	EndTime      *time.Time  `json:"endTime,omitempty"`
+
+	// Attempts counts the runs of an async action retried by its retry policy
+	Attempts int `json:"attempts,omitempty"`
+
+	// BytesTransferred and ItemsProcessed are the progress reported while it runs
+	BytesTransferred int64 `json:"bytesTransferred,omitempty"`
+	ItemsProcessed   int64 `json:"itemsProcessed,omitempty"`
+}
+
+// stateTracker records actions as operations of the service's state manager, so
+// they are served by its state endpoints. The action itself is kept in the
+// operation's "action" metadata; /v1/api/actions reads it back from there.
+type stateTracker struct {
+	mu      sync.Mutex // serializes read-modify-write of an operation
+	manager *statemanager.Manager
+	now     func() time.Time
+}
+
+// trackedActions records the service's actions; main hands it the state manager
+// whose routes it registers
+var trackedActions = newStateTracker(statemanager.New(statemanager.Config{
+	ServiceName:   "s3service",
+	MaxOperations: maxTrackedActions,
+}))
+
+func newStateTracker(manager *statemanager.Manager) *stateTracker {
+	return &stateTracker{manager: manager, now: time.Now}
+}
+
+// Use records further actions in manager
+func (t *stateTracker) Use(manager *statemanager.Manager) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.manager = manager
+}
+
+// Record stores an action, assigning an identifier and start time when it has none,
+// and returns the stored copy. Progress already reported for the action is kept.
+func (t *stateTracker) Record(action trackedAction) trackedAction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	existing, exists := t.get(action.Identifier)
+	if exists && action.BytesTransferred == 0 && action.ItemsProcessed == 0 {
+		action.BytesTransferred = existing.BytesTransferred
+		action.ItemsProcessed = existing.ItemsProcessed
+	}
+	if action.Identifier == "" {
+		action.Identifier = uuid.NewString()
+	}
+	if action.StartTime.IsZero() {
+		action.StartTime = t.now().UTC()
+	}
+	if action.EndTime == nil && action.ActionStatus != actionStatusActive {
+		end := t.now().UTC()
+		action.EndTime = &end
+	}
+
+	metadata := map[string]interface{}{"action": action}
+	if exists && existing.ActionStatus == actionStatusActive {
+		t.manager.UpdateOperation(action.Identifier, metadata)
+	} else {
+		t.manager.StartOperation(action.Identifier, action.Type, metadata)
+	}
+	switch action.ActionStatus {
+	case actionStatusCompleted:
+		t.manager.CompleteOperation(action.Identifier, action.Result)
+	case actionStatusFailed:
+		t.manager.FailOperation(action.Identifier, errors.New(action.Error))
+	}
+	return action
+}
+
+// AddProgress adds transferred bytes and processed items to a tracked action
+func (t *stateTracker) AddProgress(id string, bytes, items int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if action, ok := t.get(id); ok {
+		action.BytesTransferred += bytes
+		action.ItemsProcessed += items
+		t.manager.UpdateOperation(id, map[string]interface{}{"action": action})
+	}
+}
+
+// Get returns a tracked action by identifier
+func (t *stateTracker) Get(id string) (trackedAction, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.get(id)
+}
+
+func (t *stateTracker) get(id string) (trackedAction, bool) {
+	if id == "" {
+		return trackedAction{}, false
+	}
+	operation, ok := t.manager.GetOperation(id)
+	if !ok {
+		return trackedAction{}, false
+	}
+	action, ok := operation.Metadata["action"].(trackedAction)
+	return action, ok
+}
+
+// List returns the tracked actions, newest first. Operations the state manager
+// holds for other reasons are left out.
+func (t *stateTracker) List() []trackedAction {
+	t.mu.Lock()
+	operations := t.manager.ListOperations()
+	t.mu.Unlock()
+
+	list := make([]trackedAction, 0, len(operations))
+	for _, operation := range operations {
+		if action, ok := operation.Metadata["action"].(trackedAction); ok {
+			list = append(list, action)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].StartTime.After(list[j].StartTime)
+	})
+	return list
+}
+
+// progressKey carries the tracked action that progress is reported to
+type progressKey struct{}
+
+type progressTarget struct {
+	tracker *stateTracker
+	id      string
+}
+
+// withProgress makes reportProgress calls under ctx count towards a tracked action
+func withProgress(ctx context.Context, tracker *stateTracker, id string) context.Context {
+	return context.WithValue(ctx, progressKey{}, progressTarget{tracker: tracker, id: id})
+}
+
+// withoutProgress keeps reportProgress calls under ctx from counting towards the
+// tracked action, for work whose caller reports the progress itself
+func withoutProgress(ctx context.Context) context.Context {
+	return context.WithValue(ctx, progressKey{}, nil)
+}
+
+// reportProgress adds transferred bytes and processed items to the action tracked
+// for ctx, if any
+func reportProgress(ctx context.Context, bytes, items int64) {
+	if target, ok := ctx.Value(progressKey{}).(progressTarget); ok {
+		target.tracker.AddProgress(target.id, bytes, items)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"eve.evalgo.org/statemanager"
+)
+
+func newTestStateTracker() *stateTracker {
+	return newStateTracker(statemanager.New(statemanager.Config{ServiceName: "s3service", MaxOperations: 10}))
+}
+
+func TestStateTracker_RecordsOperations(t *testing.T) {
+	manager := statemanager.New(statemanager.Config{ServiceName: "s3service", MaxOperations: 10})
+	tracker := newStateTracker(manager)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	first := tracker.Record(trackedAction{Type: "CreateAction", ActionStatus: actionStatusActive})
+	if first.Identifier == "" || !first.StartTime.Equal(now) || first.EndTime != nil {
+		t.Errorf("expected an identifier and start time, got %+v", first)
+	}
+	tracker.AddProgress(first.Identifier, 512, 2)
+	first.ActionStatus, first.Result = actionStatusCompleted, "uploaded"
+	tracker.Record(first)
+
+	now = now.Add(time.Minute)
+	second := tracker.Record(trackedAction{Type: "TransferAction", ActionStatus: actionStatusFailed, Error: "AccessDenied"})
+
+	operation, ok := manager.GetOperation(first.Identifier)
+	if !ok || operation.Result != "uploaded" {
+		t.Errorf("expected the state manager to hold the completed operation, got %+v", operation)
+	}
+	if operation, ok := manager.GetOperation(second.Identifier); !ok || operation.Error != "AccessDenied" {
+		t.Errorf("expected the state manager to hold the failed operation, got %+v", operation)
+	}
+	if got, ok := tracker.Get(first.Identifier); !ok || got.BytesTransferred != 512 || got.ItemsProcessed != 2 || got.EndTime == nil {
+		t.Errorf("expected the progress to be kept when the action finished, got %+v", got)
+	}
+	if list := tracker.List(); len(list) != 2 || list[0].Identifier != second.Identifier {
+		t.Errorf("expected the newest action first, got %+v", list)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+
+	"eve.evalgo.org/semantic"
+)
+
+// storageTarget is the resolved S3 connection and bucket an action operates on
+type storageTarget struct {
+	Profile   string
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	PathStyle bool
+
+	// PublicEndpoint is the profile's externally reachable endpoint, if it has one
+	PublicEndpoint string
+}
+
+// actionType returns the Schema.org @type of the action
+func actionType(action *semantic.SemanticAction) string {
+	return asString(actionDocument(action)["@type"])
+}
+
+// instrumentBucket returns the bucket override sent by REST adapters as a
+// PropertyValue instrument ({"name": "bucket", "value": "..."})
+func instrumentBucket(action *semantic.SemanticAction) string {
+	instrument := actionNode(action, "instrument")
+	if asString(instrument["name"]) != "bucket" {
+		return ""
+	}
+	return asString(instrument["value"])
+}
+
+// resolveStorage determines the endpoint, credentials and bucket for an action.
+//
+// A target carrying accessKey/secretKey is used as-is (inline credentials). Otherwise
+// target.identifier (or target.additionalProperty.profile) names a storage profile;
+// when it names no profile, it is taken as a bucket in the default profile.
+func resolveStorage(action *semantic.SemanticAction) (*storageTarget, error) {
+	return resolveStorageNode(action, "target")
+}
+
+// resolveStorageNode resolves a storage location given by another node of the action,
+// such as the fromLocation and toLocation of a copy, with the same rules as the target
+func resolveStorageNode(action *semantic.SemanticAction, name string) (*storageTarget, error) {
+	target := actionNode(action, name)
+
+	if _, inline := lookupProperty(target, "accessKey"); inline {
+		if name != "target" {
+			return inlineStorageTarget(target), nil
+		}
+		bucket, err := semantic.GetS3BucketFromAction(action)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract S3 bucket: %w", err)
+		}
+		url, region, accessKey, secretKey, bucketName, err := semantic.ExtractS3Credentials(bucket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract S3 credentials: %w", err)
+		}
+		if override := instrumentBucket(action); override != "" {
+			bucketName = override
+		}
+		return &storageTarget{
+			Endpoint:  url,
+			Region:    region,
+			AccessKey: accessKey,
+			SecretKey: secretKey,
+			Bucket:    bucketName,
+			PathStyle: true,
+		}, nil
+	}
+
+	identifier := asString(target["identifier"])
+	profileName := ""
+	if value, ok := lookupProperty(target, "profile"); ok {
+		profileName = asString(value)
+	}
+
+	var profile *StorageProfile
+	bucketName := ""
+	switch {
+	case profileName != "":
+		found, ok := profiles.Get(profileName)
+		if !ok {
+			return nil, fmt.Errorf("unknown storage profile %q", profileName)
+		}
+		profile = found
+		bucketName = identifier
+	case identifier != "":
+		if found, ok := profiles.Get(identifier); ok {
+			profile = found
+		} else if found, ok := profiles.Default(); ok {
+			profile = found
+			bucketName = identifier
+		} else {
+			return nil, fmt.Errorf("unknown storage profile %q and no default profile configured", identifier)
+		}
+	default:
+		found, ok := profiles.Default()
+		if !ok {
+			return nil, fmt.Errorf("action has no target and no default storage profile is configured")
+		}
+		profile = found
+	}
+
+	if !profile.Allows(actionType(action)) {
+		return nil, fmt.Errorf("%s is not allowed on storage profile %q", actionType(action), profile.Name)
+	}
+
+	if value, ok := lookupProperty(target, "bucket"); ok && asString(value) != "" {
+		bucketName = asString(value)
+	}
+	if override := instrumentBucket(action); override != "" && name == "target" {
+		bucketName = override
+	}
+	if bucketName == "" {
+		bucketName = profile.Bucket
+	}
+
+	return profile.target(bucketName), nil
+}
+
+// target returns the profile's connection for a bucket
+func (p *StorageProfile) target(bucketName string) *storageTarget {
+	return &storageTarget{
+		Profile:   p.Name,
+		Endpoint:  p.Endpoint,
+		Region:    p.Region,
+		AccessKey: p.AccessKey,
+		SecretKey: p.SecretKey,
+		Bucket:    bucketName,
+		PathStyle: p.UsePathStyle(),
+
+		PublicEndpoint: p.PublicEndpoint,
+	}
+}
+
+// inlineStorageTarget reads a DataCatalog node that carries its own url and credentials
+func inlineStorageTarget(node map[string]interface{}) *storageTarget {
+	property := func(name string) string {
+		value, _ := lookupProperty(node, name)
+		return asString(value)
+	}
+	bucketName := property("bucket")
+	if bucketName == "" {
+		bucketName = asString(node["identifier"])
+	}
+	return &storageTarget{
+		Endpoint:  asString(node["url"]),
+		Region:    property("region"),
+		AccessKey: property("accessKey"),
+		SecretKey: property("secretKey"),
+		Bucket:    bucketName,
+		PathStyle: true,
+	}
+}
+
+// sameService reports whether two targets use the same endpoint and credentials,
+// so S3 can copy between them server-side
+func (t *storageTarget) sameService(other *storageTarget) bool {
+	return t.Endpoint == other.Endpoint &&
+		t.AccessKey == other.AccessKey &&
+		t.SecretKey == other.SecretKey
+}
@@ -0,0 +1,776 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/labstack/echo/v4"
+)
+
+// ============================================================================
+// Directory Synchronization
+// ============================================================================
+
+const (
+	defaultSyncConcurrency = 4
+	defaultSyncReportLimit = 1000 // items listed in the result; the counts cover all of them
+)
+
+// Ways to decide whether a file and an object differ. Every mode treats a size
+// difference as a change.
+const (
+	syncCompareSize     = "size"     // size only
+	syncCompareMtime    = "mtime"    // and the source is newer than the destination
+	syncCompareETag     = "etag"     // and the MD5 differs from a plain ETag (else mtime)
+	syncCompareChecksum = "checksum" // and the stored SHA-256 or ETag MD5 differs
+)
+
+// Outcomes of a synchronized item
+const (
+	syncCreated = "created"
+	syncUpdated = "updated"
+	syncSkipped = "skipped"
+	syncDeleted = "deleted"
+	syncFailed  = "failed"
+)
+
+// syncOptions describes one synchronization between a directory and a bucket prefix
+type syncOptions struct {
+	Dir         string
+	Prefix      string // "" or ending in "/"
+	Download    bool   // prefix to directory instead of directory to prefix
+	Compare     string
+	Delete      bool  // remove destination items missing from the source
+	MaxDeletes  int64 // refuse to delete more items than this (0: defaultBulkDeleteLimit)
+	DryRun      bool
+	Filter      *syncFilter
+	Concurrency int
+	Parts       multipartOptions
+}
+
+// syncEntry is a file or object, keyed by its slash-separated path below the root
+type syncEntry struct {
+	Path     string
+	Size     int64
+	Modified time.Time
+	ETag     string // objects only
+}
+
+// syncItem is the outcome for one path
+type syncItem struct {
+	Path   string
+	Status string
+	Size   int64
+	Error  string
+}
+
+// syncSummary collects the outcomes of a synchronization
+type syncSummary struct {
+	mu    sync.Mutex
+	Items []syncItem
+	Bytes int64
+}
+
+func (s *syncSummary) add(item syncItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Items = append(s.Items, item)
+	if item.Status == syncCreated || item.Status == syncUpdated {
+		s.Bytes += item.Size
+	}
+}
+
+// count returns how many items ended with a status
+func (s *syncSummary) count(status string) int {
+	n := 0
+	for _, item := range s.Items {
+		if item.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// syncFilter selects paths by include and exclude globs
+type syncFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// newSyncFilter compiles include and exclude globs. * and ? match within one path
+// segment and ** across segments; a pattern without a slash matches the file name at
+// any depth, and a trailing slash matches everything below a directory.
+func newSyncFilter(include, exclude []string) (*syncFilter, error) {
+	filter := &syncFilter{}
+	for _, list := range []struct {
+		patterns []string
+		into     *[]*regexp.Regexp
+	}{{include, &filter.include}, {exclude, &filter.exclude}} {
+		for _, pattern := range list.patterns {
+			re, err := compileGlob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			*list.into = append(*list.into, re)
+		}
+	}
+	return filter, nil
+}
+
+// matches reports whether a path is included and not excluded
+func (f *syncFilter) matches(rel string) bool {
+	if f == nil {
+		return true
+	}
+	included := len(f.include) == 0
+	for _, re := range f.include {
+		if re.MatchString(rel) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, re := range f.exclude {
+		if re.MatchString(rel) {
+			return false
+		}
+	}
+	return true
+}
+
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	// Like .gitignore, only a slash before the end anchors a pattern at the root
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**/") {
+				b.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(pattern[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// scanDirectory lists the regular files below dir. A missing dir is an error
+// matching fs.ErrNotExist.
+func scanDirectory(dir string, filter *syncFilter) (map[string]syncEntry, error) {
+	entries := map[string]syncEntry{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !filter.matches(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries[rel] = syncEntry{Path: rel, Size: info.Size(), Modified: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return entries, nil
+}
+
+// scanPrefix lists the objects below a prefix, skipping folder markers
+func scanPrefix(ctx context.Context, client *s3.Client, bucketName, prefix string, filter *syncFilter) (map[string]syncEntry, error) {
+	entries := map[string]syncEntry{}
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			rel := strings.TrimPrefix(aws.ToString(obj.Key), prefix)
+			if rel == "" || strings.HasSuffix(rel, "/") || !filter.matches(rel) {
+				continue
+			}
+			entries[rel] = syncEntry{
+				Path:     rel,
+				Size:     aws.ToInt64(obj.Size),
+				Modified: aws.ToTime(obj.LastModified),
+				ETag:     aws.ToString(obj.ETag),
+			}
+		}
+	}
+	return entries, nil
+}
+
+// synchronizer runs one synchronization
+type synchronizer struct {
+	client *s3.Client
+	target *storageTarget
+	opts   syncOptions
+}
+
+func (s *synchronizer) key(rel string) string {
+	return s.opts.Prefix + rel
+}
+
+func (s *synchronizer) localPath(rel string) string {
+	return filepath.Join(s.opts.Dir, filepath.FromSlash(rel))
+}
+
+// run compares source and destination, transfers new and changed items in parallel
+// and removes extraneous destination items when opts.Delete is set. Failures of
+// single items are reported in the summary; only listing errors stop the run.
+func (s *synchronizer) run(ctx context.Context) (*syncSummary, error) {
+	// A missing source directory is an error, not an empty source: with delete set
+	// a mistyped or unmounted path would otherwise remove every object under the prefix
+	local, err := scanDirectory(s.opts.Dir, s.opts.Filter)
+	switch {
+	case s.opts.Download && errors.Is(err, fs.ErrNotExist):
+		local = map[string]syncEntry{}
+	case err != nil:
+		return nil, err
+	}
+	remote, err := scanPrefix(ctx, s.client, s.target.Bucket, s.opts.Prefix, s.opts.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", s.opts.Prefix, err)
+	}
+	src, dst := local, remote
+	if s.opts.Download {
+		src, dst = remote, local
+	}
+	if err := s.checkDeleteLimit(src, dst); err != nil {
+		return nil, err
+	}
+
+	summary := &syncSummary{}
+	paths := make([]string, 0, len(src))
+	for rel := range src {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	s.parallel(ctx, paths, func(ctx context.Context, rel string) {
+		source := src[rel]
+		item := syncItem{Path: rel, Size: source.Size, Status: syncCreated}
+		if existing, ok := dst[rel]; ok {
+			changed, err := s.changed(ctx, source, existing)
+			if err != nil {
+				summary.add(syncItem{Path: rel, Size: source.Size, Status: syncFailed, Error: err.Error()})
+				reportProgress(ctx, 0, 1)
+				return
+			}
+			if !changed {
+				item.Status = syncSkipped
+				summary.add(item)
+				reportProgress(ctx, 0, 1)
+				return
+			}
+			item.Status = syncUpdated
+		}
+		if !s.opts.DryRun {
+			if err := s.transfer(ctx, source); err != nil {
+				item.Status, item.Error = syncFailed, err.Error()
+			}
+		}
+		summary.add(item)
+		reportProgress(ctx, 0, 1)
+	})
+
+	if s.opts.Delete {
+		s.deleteExtraneous(ctx, src, dst, summary)
+	}
+	if err := ctx.Err(); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// parallel runs fn for every path with opts.Concurrency workers
+func (s *synchronizer) parallel(ctx context.Context, paths []string, fn func(ctx context.Context, rel string)) {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				fn(ctx, rel)
+			}
+		}()
+	}
+
+feed:
+	for _, rel := range paths {
+		select {
+		case jobs <- rel:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// changed reports whether the destination differs from the source
+func (s *synchronizer) changed(ctx context.Context, source, existing syncEntry) (bool, error) {
+	if source.Size != existing.Size {
+		return true, nil
+	}
+	newer := source.Modified.Truncate(time.Second).After(existing.Modified.Truncate(time.Second))
+
+	switch s.opts.Compare {
+	case syncCompareSize:
+		return false, nil
+	case syncCompareETag:
+		object := existing
+		if s.opts.Download {
+			object = source
+		}
+		md5Hex := etagMD5(object.ETag)
+		if md5Hex == "" {
+			return newer, nil
+		}
+		digest, _ := hex.DecodeString(md5Hex)
+		return s.localDiffers(existing.Path, checksumSpec{Expected: map[string][]byte{checksumMD5: digest}, Compute: []string{checksumMD5}})
+	case syncCompareChecksum:
+		head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:       aws.String(s.target.Bucket),
+			Key:          aws.String(s.key(source.Path)),
+			ChecksumMode: types.ChecksumModeEnabled,
+		})
+		if err != nil {
+			return false, err
+		}
+		var spec checksumSpec
+		spec.expectStored(head)
+		if len(spec.Expected) == 0 {
+			// Nothing to compare against: transfer to be sure
+			return true, nil
+		}
+		return s.localDiffers(source.Path, spec)
+	default:
+		return newer, nil
+	}
+}
+
+// localDiffers hashes the local file and compares it with the expected digests
+func (s *synchronizer) localDiffers(rel string, spec checksumSpec) (bool, error) {
+	file, err := os.Open(s.localPath(rel))
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = file.Close() }()
+	sums, err := computeChecksums(file, spec.Compute)
+	if err != nil {
+		return false, err
+	}
+	return spec.verify(sums) != nil, nil
+}
+
+// transfer copies one item from the source to the destination
+func (s *synchronizer) transfer(ctx context.Context, source syncEntry) error {
+	if s.opts.Download {
+		return s.download(ctx, source)
+	}
+	return s.upload(ctx, source)
+}
+
+func (s *synchronizer) upload(ctx context.Context, source syncEntry) error {
+	localPath := s.localPath(source.Path)
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	key := s.key(source.Path)
+	body := &uploadBody{Reader: file, Size: info.Size(), Name: path.Base(source.Path)}
+	if body.ContentType, err = detectContentType(body, key); err != nil {
+		return err
+	}
+
+	stateKey := ""
+	if absPath, err := filepath.Abs(localPath); err == nil {
+		stateKey = multipartStateKey(s.target, key, absPath, info.Size(), info.ModTime())
+	}
+	_, err = uploadObject(ctx, s.client, s.target.Bucket, key, body, stateKey, s.opts.Parts)
+	return err
+}
+
+// download writes an object next to its destination and renames it into place, so a
+// failed transfer never leaves a truncated file. The file takes the object's
+// modification time, which makes the next mtime comparison see it as unchanged.
+func (s *synchronizer) download(ctx context.Context, source syncEntry) error {
+	if !filepath.IsLocal(filepath.FromSlash(source.Path)) {
+		return fmt.Errorf("key %q leaves the destination directory", s.key(source.Path))
+	}
+	localPath := s.localPath(source.Path)
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		return err
+	}
+	partial := filepath.Join(filepath.Dir(localPath), "."+filepath.Base(localPath)+".sync")
+	if _, err := downloadObjectToFile(ctx, s.client, s.target.Bucket, s.key(source.Path), "", partial, s.opts.Parts, checksumSpec{}); err != nil {
+		return err
+	}
+	if err := os.Rename(partial, localPath); err != nil {
+		_ = os.Remove(partial)
+		return err
+	}
+	return os.Chtimes(localPath, source.Modified, source.Modified)
+}
+
+// checkDeleteLimit refuses a run that would delete more than opts.MaxDeletes items,
+// before anything is transferred or deleted
+func (s *synchronizer) checkDeleteLimit(src, dst map[string]syncEntry) error {
+	if !s.opts.Delete || s.opts.DryRun {
+		return nil
+	}
+	limit := s.opts.MaxDeletes
+	if limit == 0 {
+		limit = defaultBulkDeleteLimit
+	}
+	extraneous := 0
+	for rel := range dst {
+		if _, ok := src[rel]; !ok {
+			extraneous++
+		}
+	}
+	if int64(extraneous) > limit {
+		return fmt.Errorf("sync would delete %d items, more than the limit of %d; set maxDeletes to override", extraneous, limit)
+	}
+	return nil
+}
+
+// deleteExtraneous removes destination items that are not in the source. Items
+// outside the include/exclude selection were never listed, so they are kept.
+func (s *synchronizer) deleteExtraneous(ctx context.Context, src, dst map[string]syncEntry, summary *syncSummary) {
+	var extraneous []string
+	for rel := range dst {
+		if _, ok := src[rel]; !ok {
+			extraneous = append(extraneous, rel)
+		}
+	}
+	sort.Strings(extraneous)
+	if s.opts.DryRun || ctx.Err() != nil {
+		if ctx.Err() == nil {
+			for _, rel := range extraneous {
+				summary.add(syncItem{Path: rel, Size: dst[rel].Size, Status: syncDeleted})
+			}
+		}
+		return
+	}
+
+	if s.opts.Download {
+		for _, rel := range extraneous {
+			item := syncItem{Path: rel, Size: dst[rel].Size, Status: syncDeleted}
+			if err := os.Remove(s.localPath(rel)); err != nil {
+				item.Status, item.Error = syncFailed, err.Error()
+			}
+			summary.add(item)
+			reportProgress(ctx, 0, 1)
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(extraneous))
+	for _, rel := range extraneous {
+		keys = append(keys, s.key(rel))
+	}
+	for i, outcome := range deleteKeys(ctx, s.client, s.target.Bucket, keys) {
+		item := syncItem{Path: extraneous[i], Size: dst[extraneous[i]].Size, Status: syncDeleted}
+		if outcome.Error != "" {
+			item.Status, item.Error = syncFailed, outcome.Error
+		}
+		summary.add(item)
+	}
+}
+
+// resolveSyncDir returns the absolute directory of a sync. With S3_SYNC_ROOT set the
+// directory, symlinks resolved, must lie inside it. Without a root any directory can
+// be synced but never have local files deleted, since a download sync with delete
+// removes every file the prefix lacks.
+func resolveSyncDir(dir string, deletesLocal bool) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	root := os.Getenv("S3_SYNC_ROOT")
+	if root == "" {
+		if deletesLocal {
+			return "", fmt.Errorf("deleting local files requires S3_SYNC_ROOT to be set")
+		}
+		return abs, nil
+	}
+
+	rootPath, err := resolveExisting(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid S3_SYNC_ROOT: %w", err)
+	}
+	resolved, err := resolveExisting(abs)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(rootPath, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("directory %s is outside S3_SYNC_ROOT %s", dir, root)
+	}
+	return abs, nil
+}
+
+// resolveExisting resolves the symlinks of the longest existing prefix of an absolute
+// path, so a directory a download would still create is checked where it would land
+func resolveExisting(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved, nil
+	}
+	parent := filepath.Dir(path)
+	if !os.IsNotExist(err) || parent == path {
+		return "", err
+	}
+	resolvedParent, err := resolveExisting(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolvedParent, filepath.Base(path)), nil
+}
+
+// syncOptionsFromAction reads the directory (object.contentUrl), prefix
+// (object.identifier) and the compare, delete, dryRun, include, exclude and
+// concurrency options
+func syncOptionsFromAction(action *semantic.SemanticAction) (syncOptions, error) {
+	object := actionNode(action, "object")
+	opts := syncOptions{
+		Dir:      asString(object["contentUrl"]),
+		Prefix:   asString(object["identifier"]),
+		Download: actionType(action) == "DownloadAction",
+		Compare:  strings.ToLower(stringOption(action, "compare")),
+		Delete:   boolOption(action, "delete"),
+		DryRun:   boolOption(action, "dryRun"),
+	}
+	maxDeletes, err := intOption(action, "maxDeletes", defaultBulkDeleteLimit)
+	if err != nil {
+		return syncOptions{}, err
+	}
+	if maxDeletes < 1 {
+		return syncOptions{}, fmt.Errorf("maxDeletes must be positive")
+	}
+	opts.MaxDeletes = maxDeletes
+	if opts.Dir == "" {
+		return syncOptions{}, fmt.Errorf("object.contentUrl (local directory) is required")
+	}
+	if opts.Dir, err = resolveSyncDir(opts.Dir, opts.Download && opts.Delete); err != nil {
+		return syncOptions{}, err
+	}
+	if opts.Prefix != "" && !strings.HasSuffix(opts.Prefix, "/") {
+		opts.Prefix += "/"
+	}
+	switch opts.Compare {
+	case "":
+		opts.Compare = syncCompareMtime
+	case syncCompareSize, syncCompareMtime, syncCompareETag, syncCompareChecksum:
+	default:
+		return syncOptions{}, fmt.Errorf("compare must be size, mtime, etag or checksum")
+	}
+
+	include, err := stringListOption(action, "include")
+	if err != nil {
+		return syncOptions{}, err
+	}
+	exclude, err := stringListOption(action, "exclude")
+	if err != nil {
+		return syncOptions{}, err
+	}
+	if opts.Filter, err = newSyncFilter(include, exclude); err != nil {
+		return syncOptions{}, err
+	}
+
+	concurrency, err := intOption(action, "concurrency", defaultSyncConcurrency)
+	if err != nil {
+		return syncOptions{}, err
+	}
+	if concurrency < 1 || concurrency > maxPartConcurrency {
+		return syncOptions{}, fmt.Errorf("concurrency must be between 1 and %d", maxPartConcurrency)
+	}
+	opts.Concurrency = int(concurrency)
+	if opts.Parts, err = multipartOptionsFromAction(action); err != nil {
+		return syncOptions{}, err
+	}
+	return opts, nil
+}
+
+// executeSyncActionImpl mirrors a local directory to a bucket prefix (CreateAction
+// with sync) or a prefix to a directory (DownloadAction with sync), transferring only
+// new and changed files. The result is a Dataset with every item's status and counts.
+func executeSyncActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+	opts, err := syncOptionsFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid sync options", err)
+	}
+	// Deleting extraneous objects is a delete, so the profile must allow it
+	if opts.Delete && !opts.Download && target.Profile != "" {
+		if profile, ok := profiles.Get(target.Profile); ok && !profile.Allows("DeleteAction") {
+			return returnActionError(c, action, "Invalid sync options", fmt.Errorf("DeleteAction is not allowed on storage profile %q", profile.Name))
+		}
+	}
+	limit, err := intOption(action, "maxObjects", defaultSyncReportLimit)
+	if err != nil {
+		return returnActionError(c, action, "Invalid sync options", err)
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	syncer := &synchronizer{client: client, target: target, opts: opts}
+	summary, err := syncer.run(ctx)
+	if summary == nil {
+		return returnActionError(c, action, "Failed to synchronize", err)
+	}
+
+	// Failures first, then changes, then unchanged items
+	rank := map[string]int{syncFailed: 0, syncCreated: 1, syncUpdated: 2, syncDeleted: 3, syncSkipped: 4}
+	sort.SliceStable(summary.Items, func(i, j int) bool {
+		if rank[summary.Items[i].Status] != rank[summary.Items[j].Status] {
+			return rank[summary.Items[i].Status] < rank[summary.Items[j].Status]
+		}
+		return summary.Items[i].Path < summary.Items[j].Path
+	})
+	entries := make([]interface{}, 0, len(summary.Items))
+	for _, item := range summary.Items {
+		if int64(len(entries)) >= limit {
+			break
+		}
+		entry := map[string]interface{}{
+			"@type":       "DigitalDocument",
+			"identifier":  syncer.key(item.Path),
+			"contentUrl":  syncer.localPath(item.Path),
+			"contentSize": item.Size,
+			"status":      item.Status,
+		}
+		if item.Error != "" {
+			entry["error"] = item.Error
+		}
+		entries = append(entries, entry)
+	}
+
+	direction := "upload"
+	if opts.Download {
+		direction = "download"
+	}
+	failed := summary.count(syncFailed)
+	value := map[string]interface{}{
+		"@type":            "Dataset",
+		"name":             target.Bucket,
+		"url":              fmt.Sprintf("s3://%s/%s", target.Bucket, opts.Prefix),
+		"contentUrl":       opts.Dir,
+		"direction":        direction,
+		"compare":          opts.Compare,
+		"dryRun":           opts.DryRun,
+		"hasPart":          entries,
+		"isTruncated":      len(entries) < len(summary.Items),
+		"createdCount":     summary.count(syncCreated),
+		"updatedCount":     summary.count(syncUpdated),
+		"skippedCount":     summary.count(syncSkipped),
+		"deletedCount":     summary.count(syncDeleted),
+		"failedCount":      failed,
+		"transferredBytes": summary.Bytes,
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "Dataset",
+		Format: "application/json",
+		Value:  value,
+	}
+
+	if err != nil {
+		return returnActionError(c, action, "Synchronization stopped", err)
+	}
+	if failed > 0 {
+		return returnActionError(c, action, fmt.Sprintf("%d of %d items could not be synchronized", failed, len(summary.Items)), nil)
+	}
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncFilter_Globs(t *testing.T) {
+	filter, err := newSyncFilter([]string{"*.txt", "docs/**", "logs/"}, []string{"**/tmp/*", "secret?.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rel, want := range map[string]bool{
+		"a.txt":             true,
+		"deep/nested/b.txt": true,
+		"docs/x/y.md":       true,
+		"logs/2026/app.log": true,
+		"image.png":         false,
+		"other/docs/x.md":   false,
+		"a/tmp/c.txt":       false,
+		"tmp/c.txt":         false,
+		"secret1.txt":       false,
+		"secret10.txt":      true,
+	} {
+		if got := filter.matches(rel); got != want {
+			t.Errorf("%s: expected %t, got %t", rel, want, got)
+		}
+	}
+
+	if _, err := newSyncFilter([]string{"[a-"}, nil); err == nil {
+		t.Error("expected an unterminated class to be rejected")
+	}
+}
+
+// writeSyncFile writes a file with a modification time
+func writeSyncFile(t *testing.T, dir, rel, content string, modified time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// statuses maps each synchronized path to its status
+func statuses(summary *syncSummary) map[string]string {
+	result := map[string]string{}
+	for _, item := range summary.Items {
+		result[item.Path] = item.Status
+	}
+	return result
+}
+
+func TestSynchronizer_Upload(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "sync-upload")
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), target)
+
+	remoteTime := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake.lastModified = remoteTime
+	fake.objects["bucket/site/same.txt"] = []byte("same")
+	fake.objects["bucket/site/newer.txt"] = []byte("old!")
+	fake.objects["bucket/site/stale.txt"] = []byte("gone")
+	fake.objects["bucket/site/keep.tmp"] = []byte("filtered")
+	fake.objects["bucket/other.txt"] = []byte("outside")
+
+	dir := t.TempDir()
+	writeSyncFile(t, dir, "same.txt", "same", remoteTime.Add(-time.Hour))
+	writeSyncFile(t, dir, "newer.txt", "new!", remoteTime.Add(time.Hour))
+	writeSyncFile(t, dir, "sub/new.txt", "fresh", remoteTime)
+	writeSyncFile(t, dir, "scratch.tmp", "ignored", remoteTime)
+
+	filter, _ := newSyncFilter(nil, []string{"*.tmp"})
+	opts := syncOptions{Dir: dir, Prefix: "site/", Compare: syncCompareMtime, Delete: true, Filter: filter, Concurrency: 2, Parts: multipartOptions{PartSize: minPartSize, Concurrency: 1, Threshold: minPartSize}}
+
+	dry := opts
+	dry.DryRun = true
+	summary, err := (&synchronizer{client: client, target: target, opts: dry}).run(context.Background())
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if content, _ := fake.object("bucket", "site/newer.txt"); string(content) != "old!" || fake.count("PUT ") != 0 {
+		t.Error("a dry run must not write")
+	}
+	if _, ok := fake.object("bucket", "site/stale.txt"); !ok {
+		t.Error("a dry run must not delete")
+	}
+
+	summary, err = (&synchronizer{client: client, target: target, opts: opts}).run(context.Background())
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	want := map[string]string{"same.txt": syncSkipped, "newer.txt": syncUpdated, "sub/new.txt": syncCreated, "stale.txt": syncDeleted}
+	got := statuses(summary)
+	if len(got) != len(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	for rel, status := range want {
+		if got[rel] != status {
+			t.Errorf("%s: expected %s, got %s", rel, status, got[rel])
+		}
+	}
+	if summary.Bytes != int64(len("new!")+len("fresh")) {
+		t.Errorf("expected the transferred bytes of the changed files, got %d", summary.Bytes)
+	}
+	if content, _ := fake.object("bucket", "site/sub/new.txt"); string(content) != "fresh" {
+		t.Errorf("expected the new file to be uploaded, got %q", content)
+	}
+	if _, ok := fake.object("bucket", "site/stale.txt"); ok {
+		t.Error("expected the extraneous object to be deleted")
+	}
+	for _, key := range []string{"site/keep.tmp", "other.txt"} {
+		if _, ok := fake.object("bucket", key); !ok {
+			t.Errorf("%s is outside the sync and must be kept", key)
+		}
+	}
+}
+
+func TestSynchronizer_RefusesUnsafeDeletes(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "sync-unsafe")
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), target)
+	fake.objects["bucket/site/a.txt"] = []byte("a")
+	fake.objects["bucket/site/b.txt"] = []byte("b")
+
+	// A mistyped or unmounted source must not look like an empty one
+	missing := syncOptions{Dir: filepath.Join(t.TempDir(), "unmounted"), Prefix: "site/", Compare: syncCompareMtime, Delete: true, Concurrency: 2}
+	if _, err := (&synchronizer{client: client, target: target, opts: missing}).run(context.Background()); err == nil {
+		t.Error("expected a missing source directory to fail the sync")
+	}
+
+	capped := missing
+	capped.Dir, capped.MaxDeletes = t.TempDir(), 1
+	if _, err := (&synchronizer{client: client, target: target, opts: capped}).run(context.Background()); err == nil {
+		t.Error("expected deleting more than maxDeletes objects to fail the sync")
+	}
+	for _, key := range []string{"site/a.txt", "site/b.txt"} {
+		if _, ok := fake.object("bucket", key); !ok {
+			t.Errorf("%s must not be deleted", key)
+		}
+	}
+}
+
+func TestSynchronizer_DownloadComparesChecksums(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "sync-download")
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), target)
+
+	remoteTime := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake.lastModified = remoteTime
+	fake.objects["bucket/data/a.txt"] = []byte("abcd")
+	fake.objects["bucket/data/b.txt"] = []byte("wxyz")
+	fake.objects["bucket/data/c/d.txt"] = []byte("new")
+	fake.objects["bucket/data/escape/../../x"] = []byte("evil")
+
+	dir := t.TempDir()
+	// Same size and newer, but different content: only checksum mode notices
+	writeSyncFile(t, dir, "a.txt", "abce", remoteTime.Add(time.Hour))
+	writeSyncFile(t, dir, "b.txt", "wxyz", remoteTime.Add(time.Hour))
+	writeSyncFile(t, dir, "extra.txt", "local only", remoteTime)
+
+	opts := syncOptions{Dir: dir, Prefix: "data/", Download: true, Compare: syncCompareChecksum, Delete: true, Concurrency: 4, Parts: multipartOptions{PartSize: minPartSize, Concurrency: 1, Threshold: minPartSize}}
+	summary, err := (&synchronizer{client: client, target: target, opts: opts}).run(context.Background())
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	want := map[string]string{"a.txt": syncUpdated, "b.txt": syncSkipped, "c/d.txt": syncCreated, "extra.txt": syncDeleted, "escape/../../x": syncFailed}
+	got := statuses(summary)
+	for rel, status := range want {
+		if got[rel] != status {
+			t.Errorf("%s: expected %s, got %s", rel, status, got[rel])
+		}
+	}
+
+	if content, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(content) != "abcd" {
+		t.Errorf("expected the changed file to be replaced, got %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "c", "d.txt")); string(content) != "new" {
+		t.Errorf("expected the new file to be downloaded, got %q", content)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "c", "d.txt")); err != nil || !info.ModTime().Equal(remoteTime) {
+		t.Errorf("expected the object's modification time on the file, got %v", info)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "extra.txt")); !os.IsNotExist(err) {
+		t.Error("expected the extraneous file to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "x")); !os.IsNotExist(err) {
+		t.Error("a key must not write outside the directory")
+	}
+
+	// A second mtime run finds nothing to do
+	opts.Compare = syncCompareMtime
+	delete(fake.objects, "bucket/data/escape/../../x")
+	summary, err = (&synchronizer{client: client, target: target, opts: opts}).run(context.Background())
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	if got := summary.count(syncSkipped); got != 3 || len(summary.Items) != 3 {
+		t.Errorf("expected every file to be skipped, got %v", statuses(summary))
+	}
+}
+
+func TestResolveSyncDir(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("S3_SYNC_ROOT", "")
+	if _, err := resolveSyncDir(outside, false); err != nil {
+		t.Errorf("without a root any directory can be synced: %v", err)
+	}
+	if _, err := resolveSyncDir(outside, true); err == nil {
+		t.Error("without a root local deletes must be refused")
+	}
+
+	t.Setenv("S3_SYNC_ROOT", root)
+	for dir, ok := range map[string]bool{
+		root:                                   true,
+		filepath.Join(root, "site"):            true,
+		filepath.Join(root, "new", "deep"):     true,
+		filepath.Join(root, "..", "elsewhere"): false,
+		filepath.Join(root, "escape"):          false,
+		filepath.Join(root, "escape", "new"):   false,
+		"/etc":                                 false,
+	} {
+		if _, err := resolveSyncDir(dir, true); (err == nil) != ok {
+			t.Errorf("%s: expected allowed=%v, got %v", dir, ok, err)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"eve.evalgo.org/semantic"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/labstack/echo/v4"
+)
+
+// ============================================================================
+// Object Versioning
+// ============================================================================
+
+// versionIDOption returns the object version an action targets: the "versionId"
+// option, falling back to object.version. Empty means the current version.
+func versionIDOption(action *semantic.SemanticAction) string {
+	if versionID := stringOption(action, "versionId"); versionID != "" {
+		return versionID
+	}
+	value, _ := lookupProperty(actionNode(action, "object"), "version")
+	return asString(value)
+}
+
+// versioningStatus parses a requested bucket versioning state: "Enabled" or
+// "Suspended", or a boolean
+func versioningStatus(value string) (types.BucketVersioningStatus, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "enabled", "enable", "true":
+		return types.BucketVersioningStatusEnabled, nil
+	case "suspended", "suspend", "false":
+		return types.BucketVersioningStatusSuspended, nil
+	default:
+		return "", fmt.Errorf("versioning must be Enabled or Suspended, got %q", value)
+	}
+}
+
+// executeUpdateBucketActionImpl enables or suspends versioning on a bucket. Versioning
+// can never be switched off again once enabled, only suspended: existing versions are
+// kept and new writes replace the "null" version.
+func executeUpdateBucketActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	// Resolve the storage profile (or inline target credentials)
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+	bucketName := namedBucket(action)
+	if bucketName == "" {
+		return returnActionError(c, action, "Bucket name is required: set the DataCatalog name or identifier", nil)
+	}
+
+	requested := stringOption(action, "versioning")
+	if requested == "" {
+		return returnActionError(c, action, "Nothing to update: set versioning to Enabled or Suspended", nil)
+	}
+	status, err := versioningStatus(requested)
+	if err != nil {
+		return returnActionError(c, action, "Invalid versioning state", err)
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	_, err = client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: status,
+		},
+	})
+	if err != nil {
+		return returnActionError(c, action, "Failed to update bucket versioning", err)
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "DataCatalog",
+		Format: "application/json",
+		Value: map[string]interface{}{
+			"@type":      "DataCatalog",
+			"identifier": bucketName,
+			"name":       bucketName,
+			"url":        fmt.Sprintf("s3://%s", bucketName),
+			"additionalProperty": map[string]interface{}{
+				"versioning": string(status),
+			},
+		},
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
+
+// versionListOptions are the SearchAction paging options for ListObjectVersions
+type versionListOptions struct {
+	Prefix          string
+	Delimiter       string
+	KeyMarker       string
+	VersionIDMarker string
+	MaxKeys         int32
+}
+
+// versionPage is one page of a version listing
+type versionPage struct {
+	Entries             []interface{}
+	IsTruncated         bool
+	NextKeyMarker       string
+	NextVersionIDMarker string
+}
+
+// listObjectVersions lists one page of object versions and delete markers. Entries are
+// ordered by key, newest version first, with folders (common prefixes) ahead.
+func listObjectVersions(ctx context.Context, client *s3.Client, bucketName string, opts versionListOptions) (*versionPage, error) {
+	input := &s3.ListObjectVersionsInput{
+		Bucket:          aws.String(bucketName),
+		Prefix:          optionalString(opts.Prefix),
+		Delimiter:       optionalString(opts.Delimiter),
+		KeyMarker:       optionalString(opts.KeyMarker),
+		VersionIdMarker: optionalString(opts.VersionIDMarker),
+	}
+	if opts.MaxKeys > 0 {
+		input.MaxKeys = aws.Int32(opts.MaxKeys)
+	}
+	result, err := client.ListObjectVersions(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &versionPage{
+		Entries:             []interface{}{},
+		IsTruncated:         aws.ToBool(result.IsTruncated),
+		NextKeyMarker:       aws.ToString(result.NextKeyMarker),
+		NextVersionIDMarker: aws.ToString(result.NextVersionIdMarker),
+	}
+	for _, prefix := range result.CommonPrefixes {
+		page.Entries = append(page.Entries, folderEntry(bucketName, aws.ToString(prefix.Prefix)))
+	}
+
+	versions := make([]map[string]interface{}, 0, len(result.Versions)+len(result.DeleteMarkers))
+	for _, version := range result.Versions {
+		entry := versionEntry(bucketName, aws.ToString(version.Key), aws.ToString(version.VersionId), aws.ToBool(version.IsLatest), version.LastModified)
+		entry["contentSize"] = aws.ToInt64(version.Size)
+		if version.ETag != nil {
+			entry["etag"] = aws.ToString(version.ETag)
+		}
+		versions = append(versions, entry)
+	}
+	for _, marker := range result.DeleteMarkers {
+		entry := versionEntry(bucketName, aws.ToString(marker.Key), aws.ToString(marker.VersionId), aws.ToBool(marker.IsLatest), marker.LastModified)
+		entry["deleteMarker"] = true
+		versions = append(versions, entry)
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := versions[i], versions[j]
+		if a["identifier"] != b["identifier"] {
+			return asString(a["identifier"]) < asString(b["identifier"])
+		}
+		if a["isLatest"] != b["isLatest"] {
+			return a["isLatest"] == true
+		}
+		return asString(a["uploadDate"]) > asString(b["uploadDate"])
+	})
+	for _, entry := range versions {
+		page.Entries = append(page.Entries, entry)
+	}
+	return page, nil
+}
+
+// versionEntry describes one object version or delete marker as a DigitalDocument
+func versionEntry(bucketName, key, versionID string, isLatest bool, modified *time.Time) map[string]interface{} {
+	entry := map[string]interface{}{
+		"@type":        "DigitalDocument",
+		"identifier":   key,
+		"contentUrl":   fmt.Sprintf("s3://%s/%s", bucketName, key),
+		"name":         path.Base(key),
+		"version":      versionID,
+		"isLatest":     isLatest,
+		"deleteMarker": false,
+	}
+	if modified != nil {
+		entry["uploadDate"] = modified.Format(time.RFC3339)
+	}
+	return entry
+}
+
+// datasetValue presents a version listing page as a Schema.org Dataset
+func (p *versionPage) datasetValue(bucketName string, opts versionListOptions) map[string]interface{} {
+	value := map[string]interface{}{
+		"@type":       "Dataset",
+		"name":        bucketName,
+		"hasPart":     p.Entries,
+		"keyCount":    len(p.Entries),
+		"isTruncated": p.IsTruncated,
+	}
+	if opts.Prefix != "" {
+		value["prefix"] = opts.Prefix
+	}
+	if opts.Delimiter != "" {
+		value["delimiter"] = opts.Delimiter
+	}
+	if p.NextKeyMarker != "" {
+		value["nextKeyMarker"] = p.NextKeyMarker
+	}
+	if p.NextVersionIDMarker != "" {
+		value["nextVersionIdMarker"] = p.NextVersionIDMarker
+	}
+	return value
+}
+
+// executeListVersionsActionImpl lists object versions and delete markers for a
+// SearchAction with the "versions" option. query is the key prefix; delimiter,
+// maxKeys, keyMarker and versionIdMarker page through the listing.
+func executeListVersionsActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	// Resolve the storage profile (or inline target credentials) and bucket
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+	bucketName := target.Bucket
+
+	opts := versionListOptions{
+		Delimiter:       stringOption(action, "delimiter"),
+		KeyMarker:       stringOption(action, "keyMarker"),
+		VersionIDMarker: stringOption(action, "versionIdMarker"),
+	}
+	if query, ok := action.Properties["query"].(string); ok && query != "" {
+		opts.Prefix = query
+	}
+	if opts.VersionIDMarker != "" && opts.KeyMarker == "" {
+		return returnActionError(c, action, "versionIdMarker requires keyMarker", nil)
+	}
+	maxKeys, err := intOption(action, "maxKeys", 1000)
+	if err != nil {
+		return returnActionError(c, action, "Invalid listing options", err)
+	}
+	if maxKeys < 1 || maxKeys > 1000 {
+		return returnActionError(c, action, "maxKeys must be between 1 and 1000", nil)
+	}
+	opts.MaxKeys = int32(maxKeys)
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	page, err := listObjectVersions(ctx, client, bucketName, opts)
+	if err != nil {
+		return returnActionError(c, action, "Failed to list object versions", err)
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "Dataset",
+		Format: "application/json",
+		Value:  page.datasetValue(bucketName, opts),
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
+
+// executeRestoreVersionActionImpl makes an earlier version of object.identifier the
+// current one by copying it onto the same key. The restored version is kept, so the
+// restore itself can be undone by restoring the version it replaced.
+func executeRestoreVersionActionImpl(c echo.Context, action *semantic.SemanticAction) error {
+	ctx, cancel, err := actionContext(c, action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid action options", err)
+	}
+	defer cancel()
+
+	// Resolve the storage profile (or inline target credentials) and bucket
+	target, err := resolveStorage(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to resolve storage target", err)
+	}
+	bucketName := target.Bucket
+
+	object, err := semantic.GetS3ObjectFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Failed to extract S3 object", err)
+	}
+	s3Key := object.Identifier
+	if s3Key == "" {
+		s3Key = object.Name
+	}
+	if s3Key == "" {
+		return returnActionError(c, action, "Object identifier (S3 key) is required", nil)
+	}
+	versionID := versionIDOption(action)
+	if versionID == "" {
+		return returnActionError(c, action, "versionId of the version to restore is required", nil)
+	}
+
+	partOpts, err := multipartOptionsFromAction(action)
+	if err != nil {
+		return returnActionError(c, action, "Invalid multipart options", err)
+	}
+
+	client, err := createS3Client(ctx, target)
+	if err != nil {
+		return returnActionError(c, action, "Failed to create S3 client", err)
+	}
+
+	// Copying keeps the version's content type, metadata and tags
+	copier := &objectCopy{
+		src:        target,
+		dst:        target,
+		srcClient:  client,
+		dstClient:  client,
+		srcKey:     s3Key,
+		dstKey:     s3Key,
+		srcVersion: versionID,
+		parts:      partOpts,
+	}
+	result, err := copier.run(ctx)
+	if err != nil {
+		return returnActionError(c, action, "Failed to restore version", err)
+	}
+
+	value := map[string]interface{}{
+		"@type":           "DigitalDocument",
+		"identifier":      s3Key,
+		"contentUrl":      fmt.Sprintf("s3://%s/%s", bucketName, s3Key),
+		"contentSize":     result.Size,
+		"etag":            result.ETag,
+		"restoredVersion": versionID,
+	}
+	if result.VersionID != "" {
+		value["version"] = result.VersionID
+	}
+
+	action.Result = &semantic.SemanticResult{
+		Type:   "DigitalDocument",
+		Format: "application/json",
+		Value:  value,
+	}
+
+	semantic.SetSuccessOnAction(action)
+	return c.JSON(http.StatusOK, action)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func putVersion(t *testing.T, client *s3.Client, key, content string) string {
+	t.Helper()
+	body := &uploadBody{Reader: bytes.NewReader([]byte(content)), Size: int64(len(content))}
+	result, err := putObject(context.Background(), client, "bucket", key, body)
+	if err != nil {
+		t.Fatalf("put %s failed: %v", key, err)
+	}
+	if aws.ToString(result.VersionId) == "" {
+		t.Fatalf("put %s returned no version", key)
+	}
+	return aws.ToString(result.VersionId)
+}
+
+func TestVersioningStatus(t *testing.T) {
+	for value, want := range map[string]types.BucketVersioningStatus{
+		"Enabled":   types.BucketVersioningStatusEnabled,
+		"true":      types.BucketVersioningStatusEnabled,
+		"suspended": types.BucketVersioningStatusSuspended,
+		"false":     types.BucketVersioningStatusSuspended,
+	} {
+		got, err := versioningStatus(value)
+		if err != nil || got != want {
+			t.Errorf("%s: expected %s, got %s (%v)", value, want, got, err)
+		}
+	}
+	if _, err := versioningStatus("Disabled"); err == nil {
+		t.Error("versioning can only be enabled or suspended")
+	}
+}
+
+func TestListObjectVersions(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), fakeTarget(server.URL, "versions"))
+	fake.versioned = true
+
+	first := putVersion(t, client, "doc.txt", "one")
+	second := putVersion(t, client, "doc.txt", "two")
+	putVersion(t, client, "a.txt", "a")
+	if _, err := client.DeleteObject(context.Background(), &s3.DeleteObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a.txt")}); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := listObjectVersions(context.Background(), client, "bucket", versionListOptions{})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	want := []struct {
+		key, version string
+		latest       bool
+		marker       bool
+	}{
+		{"a.txt", "m1", true, true},
+		{"a.txt", "", false, false},
+		{"doc.txt", second, true, false},
+		{"doc.txt", first, false, false},
+	}
+	if len(page.Entries) != len(want) {
+		t.Fatalf("expected %d entries, got %v", len(want), page.Entries)
+	}
+	for i, w := range want {
+		entry := page.Entries[i].(map[string]interface{})
+		if entry["identifier"] != w.key || entry["isLatest"] != w.latest || entry["deleteMarker"] != w.marker {
+			t.Errorf("entry %d: unexpected %v", i, entry)
+		}
+		if w.version != "" && entry["version"] != w.version {
+			t.Errorf("entry %d: expected version %s, got %v", i, w.version, entry["version"])
+		}
+	}
+}
+
+func TestVersionedDownloadAndRestore(t *testing.T) {
+	fake, server := newFakeS3Server(t)
+	target := fakeTarget(server.URL, "restore")
+	client, _ := newClientPool(defaultClientPoolConfig).Get(context.Background(), target)
+	fake.versioned = true
+
+	first := putVersion(t, client, "doc.txt", "original")
+	putVersion(t, client, "doc.txt", "overwritten")
+
+	path := filepath.Join(t.TempDir(), "doc.txt")
+	download, err := downloadObjectToFile(context.Background(), client, "bucket", "doc.txt", first, path, testMultipartOptions(), checksumSpec{})
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "original" || download.VersionID != first {
+		t.Errorf("expected version %s with the original content, got %s %q", first, download.VersionID, content)
+	}
+
+	restore := newTestCopy(t, target, target, "doc.txt", "doc.txt")
+	restore.srcVersion = first
+	result, err := restore.run(context.Background())
+	if err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if content, _ := fake.object("bucket", "doc.txt"); string(content) != "original" {
+		t.Errorf("expected the original content to be current, got %q", content)
+	}
+	if result.VersionID == "" || result.VersionID == first {
+		t.Errorf("a restore creates a new version, got %q", result.VersionID)
+	}
+
+	// Deleting a specific version removes it permanently
+	if _, err := client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+		Bucket:    aws.String("bucket"),
+		Key:       aws.String("doc.txt"),
+		VersionId: aws.String(first),
+	}); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := downloadObjectToFile(context.Background(), client, "bucket", "doc.txt", first, path, testMultipartOptions(), checksumSpec{}); err == nil {
+		t.Error("expected the deleted version to be gone")
+	}
+}